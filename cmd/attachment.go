@@ -1,8 +1,20 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/nerveband/mochi-cli/internal/media"
+	"github.com/nerveband/mochi-cli/internal/textdiff"
+	"github.com/nerveband/mochi-cli/pkg/mochi"
+	"github.com/nerveband/mochi-cli/pkg/mochi/models"
 	"github.com/spf13/cobra"
 )
 
@@ -10,34 +22,86 @@ import (
 var attachmentCmd = &cobra.Command{
 	Use:   "attachment",
 	Short: "Manage card attachments",
-	Long:  `Add and delete attachments on cards.`,
+	Long: `List, download, add and delete attachments on cards.
+
+Cards link their attachments as @media/<filename> in their content.`,
 }
 
 // attachmentAddCmd adds an attachment
 var attachmentAddCmd = &cobra.Command{
-	Use:   "add <card-id> <filepath>",
+	Use:   "add <card-id> <filepath|->",
 	Short: "Add an attachment to a card",
-	Args:  cobra.ExactArgs(2),
+	Long: `Add a file to a card as an attachment named after the file, or after
+--name. A path of - reads the file from stdin, which requires --name.
+
+The content type is detected from the file. PNG and JPEG images can be
+downscaled with --max-dim and JPEGs re-encoded with --jpeg-quality before
+upload, and --max-size rejects files that are still too large. Profile
+settings (see mochi config set) supply defaults for all three.
+
+Files are streamed to the API, with progress shown on a terminal.`,
+	Example: `  mochi attachment add CARD_ID screenshot.png --max-dim 1600
+  mochi attachment add CARD_ID photo.jpg --max-dim 1600 --jpeg-quality 85 --max-size 2MB
+  say -o - --data-format=mp3 "hola" | mochi attachment add CARD_ID - --name hola.mp3`,
+	Args: cobra.ExactArgs(2),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		cardID := args[0]
 		filePath := args[1]
+		name, _ := cmd.Flags().GetString("name")
+
+		opts, err := attachmentPrepareOptions(cmd)
+		if err != nil {
+			return err
+		}
+
+		var prepared *media.Prepared
+		if filePath == "-" {
+			if name == "" {
+				return fmt.Errorf("--name is required when reading the attachment from stdin")
+			}
+			if stdinIsTerminal() {
+				return fmt.Errorf("no input on stdin (pipe a file into the command)")
+			}
+			prepared, err = media.Prepare(os.Stdin, -1, name, opts)
+		} else {
+			prepared, err = media.PrepareFile(filePath, opts)
+		}
+		if err != nil {
+			return err
+		}
+		defer prepared.Close()
+		if name != "" {
+			if !media.SafeName(name) {
+				return fmt.Errorf("invalid attachment name %q", name)
+			}
+			prepared.Filename = name
+		}
 
 		if dryRun {
-			printInfo(fmt.Sprintf("Dry run - would add attachment %s to card %s", filePath, cardID))
+			printInfo(fmt.Sprintf("Dry run - would add attachment %s to card %s (%s)", prepared.Filename, cardID, prepared.Describe()))
 			return nil
 		}
 
-		client, err := getClient()
+		client, err := getClient(cmd)
 		if err != nil {
 			return err
 		}
 
-		if err := client.AddAttachmentFromFile(cardID, filePath); err != nil {
+		upload, done := attachmentUpload(prepared, prepared.Filename)
+		err = client.UploadAttachment(cardID, upload)
+		done()
+		if err != nil {
+			// A stream over the size limit fails mid-request; report it as
+			// the local problem it is
+			var sizeErr *media.SizeError
+			if errors.As(err, &sizeErr) {
+				return fmt.Errorf("%s: %w", prepared.Filename, sizeErr)
+			}
 			return err
 		}
 
 		if !quiet {
-			printSuccess(fmt.Sprintf("Attachment added to card %s", cardID))
+			printSuccess(fmt.Sprintf("Attachment %s added to card %s (%s)", prepared.Filename, cardID, prepared.Describe()))
 		}
 
 		return nil
@@ -58,7 +122,7 @@ var attachmentDeleteCmd = &cobra.Command{
 			return nil
 		}
 
-		client, err := getClient()
+		client, err := getClient(cmd)
 		if err != nil {
 			return err
 		}
@@ -75,8 +139,862 @@ var attachmentDeleteCmd = &cobra.Command{
 	},
 }
 
+// attachmentListCmd lists the attachments of a card
+var attachmentListCmd = &cobra.Command{
+	Use:   "list <card-id>",
+	Short: "List the attachments of a card",
+	Long: `List the attachments of a card: those the card payload reports and those
+its content and fields reference as @media/<filename>. Referenced files the
+card doesn't report are listed too, so broken links show up.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := getClient(cmd)
+		if err != nil {
+			return err
+		}
+
+		card, err := client.GetCard(args[0])
+		if err != nil {
+			return err
+		}
+
+		entries := cardAttachments(card)
+
+		if idOnly || outputOnly == "id" || outputOnly == "filename" {
+			for _, e := range entries {
+				fmt.Println(e.Filename)
+			}
+			return nil
+		}
+
+		switch format {
+		case "json":
+			printJSON(map[string]interface{}{
+				"card-id":     card.ID,
+				"attachments": entries,
+			})
+		case "compact":
+			printCompactJSON(entries)
+		case "table":
+			rows := make([][]string, len(entries))
+			for i, e := range entries {
+				rows[i] = []string{e.Filename, e.ContentType, formatSize(e.Size), e.source()}
+			}
+			printTable([]string{"FILENAME", "TYPE", "SIZE", "SOURCE"}, rows)
+		default:
+			if len(entries) == 0 {
+				printInfo(fmt.Sprintf("Card %s has no attachments", card.ID))
+			}
+			for _, e := range entries {
+				fmt.Printf("%s (%s)\n", e.Filename, e.source())
+			}
+		}
+
+		return nil
+	},
+}
+
+// attachmentGetCmd downloads an attachment
+var attachmentGetCmd = &cobra.Command{
+	Use:   "get <card-id> <filename>",
+	Short: "Download an attachment of a card",
+	Long: `Download an attachment of a card. The file is streamed to the path given
+with -o (- for stdout), or to <filename> in the current directory.`,
+	Example: `  mochi attachment get CARD_ID diagram.png -o ./diagram.png`,
+	Args:    cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cardID := args[0]
+		filename := args[1]
+		output, _ := cmd.Flags().GetString("output")
+
+		if output == "" {
+			if !media.SafeName(filename) {
+				return fmt.Errorf("attachment name %q is not a valid file name; use -o to choose one", filename)
+			}
+			output = filename
+		}
+
+		client, err := getClient(cmd)
+		if err != nil {
+			return err
+		}
+
+		body, info, err := client.GetAttachment(cardID, filename)
+		if err != nil {
+			return err
+		}
+		defer body.Close()
+
+		if output == "-" {
+			_, err := io.Copy(os.Stdout, body)
+			return err
+		}
+
+		size, _, err := media.WriteFile(output, body)
+		if err != nil {
+			return fmt.Errorf("failed to download %s: %w", filename, err)
+		}
+
+		if format == "json" {
+			printJSON(map[string]interface{}{
+				"card-id":      cardID,
+				"filename":     filename,
+				"path":         output,
+				"size":         size,
+				"content-type": info.ContentType,
+			})
+			return nil
+		}
+		if !quiet {
+			printSuccess(fmt.Sprintf("Downloaded %s to %s (%s)", filename, output, formatSize(size)))
+		}
+
+		return nil
+	},
+}
+
+// attachmentPullCmd downloads the attachments of many cards
+var attachmentPullCmd = &cobra.Command{
+	Use:   "pull",
+	Short: "Download the attachments of a deck",
+	Long: `Download the attachments of every card in a deck, or of all cards without
+--deck, into <dir>/<card-id>/<filename>. The card list filters (--tag,
+--archived, --created-after and so on) narrow the cards.
+
+A manifest in the directory records what was downloaded. Files are skipped
+when their card hasn't been updated since and the local copy is intact; use
+--force to download everything again.`,
+	Example: `  mochi attachment pull --deck DECK_ID --dir ./media`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		deckID, _ := cmd.Flags().GetString("deck")
+		dir, _ := cmd.Flags().GetString("dir")
+		concurrency, _ := cmd.Flags().GetInt("concurrency")
+		force, _ := cmd.Flags().GetBool("force")
+
+		if concurrency < 1 {
+			return fmt.Errorf("--concurrency must be at least 1")
+		}
+		sel, err := parseCardSelection(cmd)
+		if err != nil {
+			return err
+		}
+
+		client, err := getClient(cmd)
+		if err != nil {
+			return err
+		}
+
+		match, err := sel.matcher(client)
+		if err != nil {
+			return err
+		}
+
+		manifest, err := media.LoadManifest(dir)
+		if err != nil {
+			return err
+		}
+
+		type pullResult struct {
+			CardID   string `json:"card-id"`
+			Filename string `json:"filename"`
+			Path     string `json:"path,omitempty"`
+			Status   string `json:"status"`
+			Size     int64  `json:"size,omitempty"`
+			Error    string `json:"error,omitempty"`
+		}
+		type pullJob struct {
+			card      models.Card
+			entry     attachmentEntry
+			key       string
+			updatedAt string
+			result    *pullResult
+		}
+
+		// Work out what to download before starting, so the listing isn't
+		// interleaved with downloads
+		var jobs []*pullJob
+		var results []*pullResult
+		it := client.Cards(deckID, mochi.IterOptions{PageSize: 100}).Where(match)
+		for it.Next() {
+			card := it.Item()
+			updatedAt := ""
+			if card.UpdatedAt != nil {
+				updatedAt = card.UpdatedAt.Format(time.RFC3339Nano)
+			}
+
+			for _, entry := range cardAttachments(&card) {
+				result := &pullResult{CardID: card.ID, Filename: entry.Filename}
+				results = append(results, result)
+
+				if !media.SafeName(entry.Filename) || !media.SafeName(card.ID) {
+					result.Status = "failed"
+					result.Error = "not a valid file name"
+					continue
+				}
+				key := card.ID + "/" + entry.Filename
+				result.Path = filepath.Join(dir, card.ID, entry.Filename)
+
+				if !force && manifest.Unchanged(dir, key, updatedAt, entry.Size) {
+					result.Status = "unchanged"
+					result.Size = manifest.Files[key].Size
+					continue
+				}
+				if dryRun {
+					result.Status = "would-download"
+					continue
+				}
+				jobs = append(jobs, &pullJob{card: card, entry: entry, key: key, updatedAt: updatedAt, result: result})
+			}
+		}
+		if err := it.Err(); err != nil {
+			return err
+		}
+
+		var (
+			mu   sync.Mutex
+			wg   sync.WaitGroup
+			work = make(chan *pullJob)
+		)
+		for i := 0; i < concurrency; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for job := range work {
+					entry, err := pullAttachment(client, dir, job.card.ID, job.entry.Filename)
+					mu.Lock()
+					if err != nil {
+						job.result.Status = "failed"
+						job.result.Error = err.Error()
+					} else {
+						entry.CardUpdatedAt = job.updatedAt
+						manifest.Files[job.key] = entry
+						job.result.Status = "downloaded"
+						job.result.Size = entry.Size
+					}
+					mu.Unlock()
+				}
+			}()
+		}
+		for _, job := range jobs {
+			if cmd.Context().Err() != nil {
+				break
+			}
+			work <- job
+		}
+		close(work)
+		wg.Wait()
+
+		if len(jobs) > 0 {
+			if err := manifest.Save(dir); err != nil {
+				return fmt.Errorf("failed to save media manifest: %w", err)
+			}
+		}
+
+		counts := map[string]int{}
+		for _, r := range results {
+			if r.Status == "" {
+				r.Status = "skipped"
+			}
+			counts[r.Status]++
+		}
+
+		switch format {
+		case "json":
+			printJSON(map[string]interface{}{
+				"dir":        dir,
+				"total":      len(results),
+				"downloaded": counts["downloaded"],
+				"unchanged":  counts["unchanged"],
+				"failed":     counts["failed"],
+				"dry-run":    dryRun,
+				"results":    results,
+			})
+		case "compact":
+			printCompactJSON(results)
+		case "table":
+			rows := make([][]string, len(results))
+			for i, r := range results {
+				rows[i] = []string{r.CardID, r.Filename, r.Status, formatSize(r.Size), r.Error}
+			}
+			printTable([]string{"CARD ID", "FILENAME", "STATUS", "SIZE", "ERROR"}, rows)
+		default:
+			for _, r := range results {
+				if r.Error != "" {
+					fmt.Printf("%s/%s: %s (%s)\n", r.CardID, r.Filename, r.Status, r.Error)
+				} else if verbose || r.Status != "unchanged" {
+					fmt.Printf("%s/%s: %s\n", r.CardID, r.Filename, r.Status)
+				}
+			}
+		}
+
+		if err := cmd.Context().Err(); err != nil {
+			return err
+		}
+		if counts["failed"] > 0 {
+			return fmt.Errorf("%d of %d attachments failed to download", counts["failed"], len(results))
+		}
+		if dryRun && format != "json" {
+			printInfo(fmt.Sprintf("Dry run - would download %d attachments to %s, %d unchanged", counts["would-download"], dir, counts["unchanged"]))
+			return nil
+		}
+		if !quiet && format != "json" {
+			printSuccess(fmt.Sprintf("Downloaded %d attachments to %s, %d unchanged", counts["downloaded"], dir, counts["unchanged"]))
+		}
+		return nil
+	},
+}
+
+// attachmentAuditCmd finds broken attachment links and orphaned attachments
+var attachmentAuditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Find broken attachment links and orphaned attachments",
+	Long: `Cross-reference the @media/ links in card content and fields against the
+attachments each card has, in one deck or all decks. The card list filters
+(--tag, --archived, --created-after and so on) narrow the cards.
+
+Broken references are links to attachments the card doesn't have. Orphans
+are attachments nothing on the card links to. When the API doesn't list a
+card's attachments, use --check-remote to download-probe each referenced
+name before calling it broken.
+
+Fixes:
+  delete-orphans  Delete orphaned attachments
+  strip-broken    Remove broken images and unlink broken links, keeping
+                  their text (implies --check-remote)
+
+With --dry-run, fixes print a diff of the content changes and the
+attachments that would be deleted instead of applying them.`,
+	Example: `  mochi attachment audit --deck DECK_ID
+  mochi attachment audit --fix strip-broken --dry-run
+  mochi attachment audit --fix delete-orphans,strip-broken --force`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		deckID, _ := cmd.Flags().GetString("deck")
+		fixes, _ := cmd.Flags().GetStringSlice("fix")
+		checkRemote, _ := cmd.Flags().GetBool("check-remote")
+		force, _ := cmd.Flags().GetBool("force")
+
+		var deleteOrphans, stripBroken bool
+		for _, fix := range fixes {
+			switch fix {
+			case "delete-orphans":
+				deleteOrphans = true
+			case "strip-broken":
+				stripBroken = true
+			default:
+				return fmt.Errorf("unknown fix %q (expected delete-orphans or strip-broken)", fix)
+			}
+		}
+		if stripBroken {
+			checkRemote = true
+		}
+		sel, err := parseCardSelection(cmd)
+		if err != nil {
+			return err
+		}
+
+		client, err := getClient(cmd)
+		if err != nil {
+			return err
+		}
+
+		match, err := sel.matcher(client)
+		if err != nil {
+			return err
+		}
+
+		scanned := 0
+		cards, err := client.FilterCards(deckID, func(card *models.Card) bool {
+			if !match(card) {
+				return false
+			}
+			scanned++
+			return len(card.Attachments) > 0 || len(media.CardReferences(card)) > 0
+		})
+		if err != nil {
+			return err
+		}
+
+		type auditResult struct {
+			CardID  string   `json:"card-id"`
+			Name    string   `json:"name,omitempty"`
+			Broken  []string `json:"broken,omitempty"`
+			Orphans []string `json:"orphans,omitempty"`
+			// Deleted and Stripped record the fixes applied (or that would be)
+			Deleted  []string `json:"deleted,omitempty"`
+			Stripped []string `json:"stripped,omitempty"`
+			Diff     string   `json:"diff,omitempty"`
+			// Errors lists every check or fix that failed on the card
+			Errors []string `json:"errors,omitempty"`
+
+			card *models.Card
+		}
+
+		results := []*auditResult{}
+		var brokenCount, orphanCount int
+		for i := range cards {
+			card := &cards[i]
+			result := &auditResult{CardID: card.ID, Name: card.Name, card: card}
+
+			for _, entry := range cardAttachments(card) {
+				switch {
+				case entry.Referenced && !entry.Attached:
+					if checkRemote {
+						exists, err := attachmentExists(client, card.ID, entry.Filename)
+						if err != nil {
+							result.Errors = append(result.Errors, err.Error())
+							continue
+						}
+						if exists {
+							continue
+						}
+					}
+					result.Broken = append(result.Broken, entry.Filename)
+				case entry.Attached && !entry.Referenced:
+					result.Orphans = append(result.Orphans, entry.Filename)
+				}
+			}
+
+			if len(result.Broken) > 0 || len(result.Orphans) > 0 || len(result.Errors) > 0 {
+				brokenCount += len(result.Broken)
+				orphanCount += len(result.Orphans)
+				results = append(results, result)
+			}
+		}
+
+		toDelete, toStrip := 0, 0
+		for _, r := range results {
+			if deleteOrphans {
+				toDelete += len(r.Orphans)
+			}
+			if stripBroken && len(r.Errors) == 0 {
+				toStrip += len(r.Broken)
+			}
+		}
+
+		apply := (toDelete > 0 || toStrip > 0) && !dryRun
+		if apply && !force && !quiet {
+			prompt := fmt.Sprintf("Delete %d orphaned attachments and strip %d broken links?", toDelete, toStrip)
+			if !confirm(cmd, prompt) {
+				fmt.Println("Cancelled")
+				return nil
+			}
+		}
+
+		failed, deleted, stripped := 0, 0, 0
+		for _, r := range results {
+			if cmd.Context().Err() != nil {
+				break
+			}
+
+			// Links are only stripped once every reference could be checked
+			if stripBroken && len(r.Broken) > 0 && len(r.Errors) == 0 {
+				patch, diff := stripBrokenPatch(r.card, r.Broken)
+				if !patch.IsEmpty() {
+					if dryRun {
+						r.Diff = diff
+						r.Stripped = r.Broken
+					} else if _, err := client.UpdateCard(r.CardID, patch); err != nil {
+						r.Errors = append(r.Errors, fmt.Sprintf("failed to strip broken links: %v", err))
+					} else {
+						r.Stripped = r.Broken
+					}
+				}
+			}
+
+			if deleteOrphans {
+				for _, name := range r.Orphans {
+					if !dryRun {
+						if err := client.DeleteAttachment(r.CardID, name); err != nil {
+							r.Errors = append(r.Errors, fmt.Sprintf("failed to delete %s: %v", name, err))
+							continue
+						}
+					}
+					r.Deleted = append(r.Deleted, name)
+				}
+			}
+
+			if len(r.Errors) > 0 {
+				failed++
+			}
+			deleted += len(r.Deleted)
+			stripped += len(r.Stripped)
+		}
+
+		switch format {
+		case "json":
+			printJSON(map[string]interface{}{
+				"cards":   scanned,
+				"broken":  brokenCount,
+				"orphans": orphanCount,
+				"failed":  failed,
+				"dry-run": dryRun,
+				"results": results,
+			})
+		case "compact":
+			printCompactJSON(results)
+		case "table":
+			rows := make([][]string, len(results))
+			for i, r := range results {
+				rows[i] = []string{r.CardID, truncateString(r.Name, 30), strings.Join(r.Broken, ", "), strings.Join(r.Orphans, ", "), strings.Join(r.Errors, "; ")}
+			}
+			printTable([]string{"CARD ID", "NAME", "BROKEN", "ORPHANS", "ERROR"}, rows)
+		default:
+			for _, r := range results {
+				if r.Name != "" {
+					fmt.Printf("%s (%s)\n", r.CardID, r.Name)
+				} else {
+					fmt.Println(r.CardID)
+				}
+				for _, name := range r.Broken {
+					fmt.Printf("  broken: %s%s\n", media.Prefix, name)
+				}
+				for _, name := range r.Orphans {
+					fmt.Printf("  orphan: %s\n", name)
+				}
+				for _, e := range r.Errors {
+					fmt.Printf("  error: %s\n", e)
+				}
+				if r.Diff != "" {
+					fmt.Print(r.Diff)
+				}
+			}
+		}
+
+		if err := cmd.Context().Err(); err != nil {
+			return err
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of %d cards could not be audited or fixed", failed, len(results))
+		}
+		if format == "json" || quiet {
+			return nil
+		}
+		summary := fmt.Sprintf("Checked %d cards: %d broken references, %d orphaned attachments", scanned, brokenCount, orphanCount)
+		switch {
+		case dryRun && (toDelete > 0 || toStrip > 0):
+			printInfo(fmt.Sprintf("Dry run - %s; would delete %d attachments and strip %d links", summary, deleted, stripped))
+		case apply:
+			printSuccess(fmt.Sprintf("%s; deleted %d attachments and stripped %d links", summary, deleted, stripped))
+		default:
+			printSuccess(summary)
+		}
+		return nil
+	},
+}
+
+// attachmentExists reports whether a card has an attachment, by requesting
+// it. The API doesn't always list a card's attachments in its payload.
+func attachmentExists(client *mochi.Client, cardID, filename string) (bool, error) {
+	body, _, err := client.GetAttachment(cardID, filename)
+	if mochi.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check %s: %w", filename, err)
+	}
+	body.Close()
+	return true, nil
+}
+
+// stripBrokenPatch builds the update removing links to the given
+// attachments from a card's content and fields, with a diff of the changes
+func stripBrokenPatch(card *models.Card, broken []string) (models.CardPatch, string) {
+	names := map[string]bool{}
+	for _, name := range broken {
+		names[name] = true
+	}
+
+	var patch models.CardPatch
+	var diff strings.Builder
+
+	if content := media.StripReferences(card.Content, names); content != card.Content {
+		patch.Content = models.Set(content)
+		diff.WriteString(textdiff.Unified(card.Content, content, card.ID+"/content", card.ID+"/content", 2))
+	}
+
+	ids := make([]string, 0, len(card.Fields))
+	for id := range card.Fields {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	fields := make(map[string]models.Field, len(card.Fields))
+	fieldsChanged := false
+	for _, id := range ids {
+		field := card.Fields[id]
+		if value := media.StripReferences(field.Value, names); value != field.Value {
+			label := card.ID + "/fields/" + id
+			diff.WriteString(textdiff.Unified(field.Value, value, label, label, 2))
+			field.Value = value
+			fieldsChanged = true
+		}
+		fields[id] = field
+	}
+	if fieldsChanged {
+		patch.Fields = models.Set(fields)
+	}
+
+	return patch, diff.String()
+}
+
+// attachmentEntry is an attachment of a card as reported by attachment list
+type attachmentEntry struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"content-type,omitempty"`
+	Size        int64  `json:"size,omitempty"`
+	// Attached is set when the card payload lists the attachment
+	Attached bool `json:"attached"`
+	// Referenced is set when the card content or fields link the attachment
+	Referenced bool `json:"referenced"`
+}
+
+// source describes where an attachment was found
+func (e attachmentEntry) source() string {
+	switch {
+	case e.Attached && e.Referenced:
+		return "attached, referenced"
+	case e.Attached:
+		return "attached"
+	default:
+		return "referenced"
+	}
+}
+
+// cardAttachments merges the attachments a card reports with those its
+// content references, sorted by filename
+func cardAttachments(card *models.Card) []attachmentEntry {
+	byName := map[string]*attachmentEntry{}
+	for name, a := range card.Attachments {
+		byName[name] = &attachmentEntry{Filename: name, ContentType: a.ContentType, Size: a.Size, Attached: true}
+	}
+	for _, name := range media.CardReferences(card) {
+		if e, ok := byName[name]; ok {
+			e.Referenced = true
+		} else {
+			byName[name] = &attachmentEntry{Filename: name, Referenced: true}
+		}
+	}
+
+	entries := make([]attachmentEntry, 0, len(byName))
+	for _, e := range byName {
+		entries = append(entries, *e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Filename < entries[j].Filename })
+	return entries
+}
+
+// pullAttachment downloads one attachment into <dir>/<card-id>/<filename>
+func pullAttachment(client *mochi.Client, dir, cardID, filename string) (media.ManifestEntry, error) {
+	cardDir := filepath.Join(dir, cardID)
+	if err := os.MkdirAll(cardDir, 0755); err != nil {
+		return media.ManifestEntry{}, err
+	}
+
+	body, info, err := client.GetAttachment(cardID, filename)
+	if err != nil {
+		return media.ManifestEntry{}, err
+	}
+	defer body.Close()
+
+	size, sum, err := media.WriteFile(filepath.Join(cardDir, filename), body)
+	if err != nil {
+		return media.ManifestEntry{}, err
+	}
+	return media.ManifestEntry{CardID: cardID, Size: size, SHA256: sum, ContentType: info.ContentType}, nil
+}
+
+// findLocalMedia finds local files linked from card content and rewrites
+// the links to the @media/ names they will be uploaded under. Relative paths
+// are resolved against the directory of sourceFile, or the current
+// directory when the content didn't come from a file.
+func findLocalMedia(content, sourceFile string) (string, []media.LocalFile, error) {
+	baseDir := "."
+	if sourceFile != "" {
+		baseDir = filepath.Dir(sourceFile)
+	}
+
+	files, err := media.FindLocal(content, baseDir)
+	if err != nil {
+		return "", nil, fmt.Errorf("%w (use --no-upload-media to send the content as is)", err)
+	}
+	return media.RewriteLocal(content, files), files, nil
+}
+
+// printLocalMedia lists the uploads a dry run would make
+func printLocalMedia(files []media.LocalFile) {
+	for _, f := range files {
+		printInfo(fmt.Sprintf("  Upload: %s as %s%s", f.Path, media.Prefix, f.Name))
+	}
+}
+
+// uploadLocalMedia uploads local files as attachments of a card, skipping
+// those the card already has
+func uploadLocalMedia(client *mochi.Client, cardID string, files []media.LocalFile, existing map[string]models.Attachment, opts media.PrepareOptions) error {
+	for _, f := range files {
+		if _, ok := existing[f.Name]; ok {
+			continue
+		}
+		if err := uploadLocalFile(client, cardID, f, opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// uploadLocalFile prepares and uploads one linked file under its hash name
+func uploadLocalFile(client *mochi.Client, cardID string, f media.LocalFile, opts media.PrepareOptions) error {
+	prepared, err := media.PrepareFile(f.Path, opts)
+	if err != nil {
+		return err
+	}
+	defer prepared.Close()
+
+	upload, done := attachmentUpload(prepared, f.Name)
+	err = client.UploadAttachment(cardID, upload)
+	done()
+	if err != nil {
+		return fmt.Errorf("failed to upload %s: %w", f.Path, err)
+	}
+	if verbose {
+		printStderrWarning(fmt.Sprintf("Uploaded %s as %s%s (%s)", f.Path, media.Prefix, f.Name, prepared.Describe()))
+	}
+	return nil
+}
+
+// attachmentUpload builds the upload of a prepared file, reporting progress
+// on stderr when it is a terminal. Call done once the upload returns to
+// clear the progress line.
+func attachmentUpload(prepared *media.Prepared, filename string) (upload mochi.AttachmentUpload, done func()) {
+	upload = mochi.AttachmentUpload{
+		Filename:    filename,
+		ContentType: prepared.ContentType,
+		Body:        prepared.Body,
+	}
+	if prepared.Size > 0 {
+		upload.Size = prepared.Size
+	}
+
+	if quiet || !stderrIsTerminal() {
+		return upload, func() {}
+	}
+
+	var (
+		mu    sync.Mutex
+		last  time.Time
+		drawn bool
+	)
+	upload.Progress = func(sent, total int64) {
+		mu.Lock()
+		defer mu.Unlock()
+		if time.Since(last) < 100*time.Millisecond && sent != total {
+			return
+		}
+		last, drawn = time.Now(), true
+		if total > 0 {
+			fmt.Fprintf(os.Stderr, "\r\x1b[KUploading %s: %d%% (%s of %s)", filename, sent*100/total, media.FormatSize(sent), media.FormatSize(total))
+		} else {
+			fmt.Fprintf(os.Stderr, "\r\x1b[KUploading %s: %s", filename, media.FormatSize(sent))
+		}
+	}
+	done = func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if drawn {
+			fmt.Fprint(os.Stderr, "\r\x1b[K")
+		}
+	}
+	return upload, done
+}
+
+// stdinIsTerminal reports whether stdin is a terminal rather than a pipe or
+// file
+func stdinIsTerminal() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// stderrIsTerminal reports whether stderr is a terminal
+func stderrIsTerminal() bool {
+	info, err := os.Stderr.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// attachmentPrepareOptions combines the profile's attachment defaults with
+// the --max-size, --max-dim and --jpeg-quality flags, on commands that have
+// them
+func attachmentPrepareOptions(cmd *cobra.Command) (media.PrepareOptions, error) {
+	p, _ := loadProfile(false)
+	opts := media.PrepareOptions{
+		MaxDim:      p.AttachmentMaxDim,
+		JPEGQuality: p.AttachmentJPEGQuality,
+	}
+	maxSize := p.AttachmentMaxSize
+
+	flags := cmd.Flags()
+	if flags.Lookup("max-size") != nil && flags.Changed("max-size") {
+		maxSize, _ = flags.GetString("max-size")
+	}
+	if flags.Lookup("max-dim") != nil && flags.Changed("max-dim") {
+		opts.MaxDim, _ = flags.GetInt("max-dim")
+	}
+	if flags.Lookup("jpeg-quality") != nil && flags.Changed("jpeg-quality") {
+		opts.JPEGQuality, _ = flags.GetInt("jpeg-quality")
+	}
+
+	if maxSize != "" {
+		n, err := media.ParseSize(maxSize)
+		if err != nil {
+			return opts, fmt.Errorf("--max-size: %w", err)
+		}
+		opts.MaxSize = n
+	}
+	if opts.MaxDim < 0 {
+		return opts, fmt.Errorf("--max-dim must not be negative")
+	}
+	if opts.JPEGQuality < 0 || opts.JPEGQuality > 100 {
+		return opts, fmt.Errorf("--jpeg-quality must be between 1 and 100")
+	}
+	return opts, nil
+}
+
+// formatSize formats a byte count for tables; 0 means unknown and is left
+// blank
+func formatSize(n int64) string {
+	if n <= 0 {
+		return ""
+	}
+	return media.FormatSize(n)
+}
+
 func init() {
 	rootCmd.AddCommand(attachmentCmd)
 	attachmentCmd.AddCommand(attachmentAddCmd)
 	attachmentCmd.AddCommand(attachmentDeleteCmd)
+	attachmentCmd.AddCommand(attachmentListCmd)
+	attachmentCmd.AddCommand(attachmentGetCmd)
+	attachmentCmd.AddCommand(attachmentPullCmd)
+	attachmentCmd.AddCommand(attachmentAuditCmd)
+
+	// Add flags
+	attachmentAddCmd.Flags().StringP("name", "n", "", "Attachment name (required when reading from stdin)")
+	attachmentAddCmd.Flags().String("max-size", "", "Reject files larger than this after processing (e.g. 10MB)")
+	attachmentAddCmd.Flags().Int("max-dim", 0, "Downscale PNG and JPEG images larger than this many pixels")
+	attachmentAddCmd.Flags().Int("jpeg-quality", 0, "Re-encode JPEG images at this quality (1-100)")
+
+	// Get flags
+	attachmentGetCmd.Flags().StringP("output", "o", "", "Where to write the file (- for stdout; default: the filename)")
+
+	// Pull flags
+	attachmentPullCmd.Flags().StringP("deck", "d", "", "Only pull attachments of cards in this deck")
+	attachmentPullCmd.Flags().String("dir", "media", "Directory to download into")
+	attachmentPullCmd.Flags().Int("concurrency", 4, "Number of parallel downloads")
+	attachmentPullCmd.Flags().Bool("force", false, "Download files even if they are unchanged")
+	addCardFilterFlags(attachmentPullCmd)
+
+	// Audit flags
+	attachmentAuditCmd.Flags().StringP("deck", "d", "", "Only audit cards in this deck")
+	attachmentAuditCmd.Flags().StringSlice("fix", nil, "Fixes to apply: delete-orphans, strip-broken")
+	attachmentAuditCmd.Flags().Bool("check-remote", false, "Probe referenced attachments the card doesn't list before reporting them broken")
+	attachmentAuditCmd.Flags().Bool("force", false, "Apply fixes without confirmation")
+	addCardFilterFlags(attachmentAuditCmd)
 }
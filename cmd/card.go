@@ -1,13 +1,22 @@
 package cmd
 
 import (
-	"bufio"
-	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"os/exec"
+	"sort"
+	"strconv"
 	"strings"
 
-	"github.com/nerveband/mochi-cli/internal/models"
+	"github.com/nerveband/mochi-cli/internal/cardfile"
+	"github.com/nerveband/mochi-cli/internal/dupes"
+	"github.com/nerveband/mochi-cli/internal/media"
+	"github.com/nerveband/mochi-cli/internal/query"
+	"github.com/nerveband/mochi-cli/internal/templating"
+	"github.com/nerveband/mochi-cli/internal/textdiff"
+	"github.com/nerveband/mochi-cli/pkg/mochi"
+	"github.com/nerveband/mochi-cli/pkg/mochi/models"
 	"github.com/spf13/cobra"
 )
 
@@ -22,34 +31,47 @@ var cardCmd = &cobra.Command{
 var cardListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List cards",
-	Long:  `List cards with optional filtering by deck.`,
+	Long: `List cards, optionally limited to a deck and filtered by tag, state,
+template, dates and review history, or by a --where query (see card search).
+Filters combine: a card must match all of them.
+
+Only the first page is shown unless --all is given. With filters, pages are
+read until --limit cards match. JSON output includes a bookmark that can be
+passed to --bookmark to fetch the next page.`,
+	Example: `  mochi card list --deck DECK_ID --archived
+  mochi card list --tag verbs --created-after 2025-03-01 --all
+  mochi card list --new --has-reviews=false --limit 50
+  mochi card list --template TEMPLATE_ID --min-lapses 3 --format table
+  mochi card list --where 'deck:Spanish (tag:verbs OR tag:irregular)' --all`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		deckID, _ := cmd.Flags().GetString("deck")
-		limit, _ := cmd.Flags().GetInt("limit")
 
-		client, err := getClient()
+		sel, err := parseCardSelection(cmd)
 		if err != nil {
 			return err
 		}
 
-		resp, err := client.ListCards(deckID, limit, "")
+		client, err := getClient(cmd)
 		if err != nil {
 			return err
 		}
 
-		// Convert docs to cards
-		docs, ok := resp.Docs.([]interface{})
-		if !ok {
-			return fmt.Errorf("unexpected response format")
+		match, err := sel.matcher(client)
+		if err != nil {
+			return err
 		}
 
-		var cards []models.Card
-		for _, doc := range docs {
-			data, _ := json.Marshal(doc)
-			var card models.Card
-			if err := json.Unmarshal(data, &card); err == nil {
-				cards = append(cards, card)
-			}
+		opts := listIterOptions(cmd)
+		if !sel.IsZero() && opts.MaxPages == 1 {
+			// Filters apply across pages: read on until --limit cards match
+			opts.MaxPages = 0
+			opts.MaxItems = opts.PageSize
+			opts.PageSize = 100
+		}
+		it := client.Cards(deckID, opts).Where(match)
+		cards, err := mochi.Collect(it)
+		if err != nil && !isInterrupted(err) {
+			return err
 		}
 
 		// Handle output formats
@@ -57,7 +79,7 @@ var cardListCmd = &cobra.Command{
 			for _, card := range cards {
 				fmt.Println(card.ID)
 			}
-			return nil
+			return err
 		}
 
 		if outputOnly != "" {
@@ -67,15 +89,19 @@ var cardListCmd = &cobra.Command{
 					fmt.Println(val)
 				}
 			}
-			return nil
+			return err
 		}
 
 		switch format {
 		case "json":
-			printJSON(map[string]interface{}{
+			result := map[string]interface{}{
 				"cards":    cards,
-				"bookmark": resp.Bookmark,
-			})
+				"bookmark": it.Bookmark(),
+			}
+			if warnings := it.Warnings(); len(warnings) > 0 {
+				result["warnings"] = warningMessages(warnings)
+			}
+			printJSON(result)
 		case "compact":
 			printCompactJSON(cards)
 		case "table":
@@ -97,7 +123,7 @@ var cardListCmd = &cobra.Command{
 			}
 		}
 
-		return nil
+		return err
 	},
 }
 
@@ -109,7 +135,7 @@ var cardGetCmd = &cobra.Command{
 	RunE: func(cmd *cobra.Command, args []string) error {
 		cardID := args[0]
 
-		client, err := getClient()
+		client, err := getClient(cmd)
 		if err != nil {
 			return err
 		}
@@ -159,7 +185,21 @@ var cardGetCmd = &cobra.Command{
 var cardCreateCmd = &cobra.Command{
 	Use:   "create",
 	Short: "Create a new card",
-	Long:  `Create a new flashcard. Content can be provided via --content, --file, or stdin.`,
+	Long: `Create a new flashcard. Content can be provided via --content, --file, or stdin.
+
+Cards using a template are filled in with --field Name=value (repeatable) or
+--fields-file, a JSON or YAML object of field values. Fields can be named by
+name or ID and are checked against the template: unknown fields, values that
+don't match the field type, and missing text fields used in the template's
+content are rejected.
+
+Local images linked from the content, like ![diagram](./img/diagram.png), are
+uploaded as attachments and the links rewritten to @media/<name>. Paths are
+relative to the --file, or to the current directory. Files are named by
+content hash, so the same image is only stored once. --no-upload-media sends
+the content unchanged.`,
+	Example: `  mochi card create -d DECK_ID -t TEMPLATE_ID --field Word=hola --field Meaning=hello
+  mochi card create -d DECK_ID -t TEMPLATE_ID --fields-file fields.yaml`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		deckID, _ := cmd.Flags().GetString("deck")
 		content, _ := cmd.Flags().GetString("content")
@@ -167,6 +207,10 @@ var cardCreateCmd = &cobra.Command{
 		templateID, _ := cmd.Flags().GetString("template")
 		file, _ := cmd.Flags().GetString("file")
 		stdin, _ := cmd.Flags().GetBool("stdin")
+		fieldFlags, _ := cmd.Flags().GetStringArray("field")
+		fieldsFile, _ := cmd.Flags().GetString("fields-file")
+		allowMissing, _ := cmd.Flags().GetBool("allow-missing")
+		noUploadMedia, _ := cmd.Flags().GetBool("no-upload-media")
 
 		if deckID == "" {
 			return fmt.Errorf("deck ID is required (use --deck)")
@@ -187,19 +231,40 @@ var cardCreateCmd = &cobra.Command{
 			content = string(data)
 		}
 
-		if content == "" {
-			return fmt.Errorf("content is required (use --content, --file, or --stdin)")
+		// Field values from the file, overridden by --field flags
+		values := map[string]string{}
+		if fieldsFile != "" {
+			if fieldsFile == "-" && stdin {
+				return fmt.Errorf("--fields-file - and --stdin cannot both read stdin")
+			}
+			fileValues, err := templating.LoadFieldValues(fieldsFile)
+			if err != nil {
+				return err
+			}
+			values = fileValues
+		}
+		flagValues, err := templating.ParseFieldFlags(fieldFlags)
+		if err != nil {
+			return err
+		}
+		for k, v := range flagValues {
+			values[k] = v
 		}
 
-		if dryRun {
-			printInfo("Dry run - would create card:")
-			printInfo(fmt.Sprintf("  Deck: %s", deckID))
-			printInfo(fmt.Sprintf("  Name: %s", name))
-			printInfo(fmt.Sprintf("  Content: %s", truncateString(content, 50)))
-			return nil
+		if len(values) > 0 && templateID == "" {
+			return fmt.Errorf("--field and --fields-file require a template (use --template)")
+		}
+		if content == "" && len(values) == 0 {
+			return fmt.Errorf("content is required (use --content, --file, --stdin, or --field with --template)")
 		}
 
-		client, err := getClient()
+		var localMedia []media.LocalFile
+		if content != "" && !noUploadMedia {
+			if content, localMedia, err = findLocalMedia(content, file); err != nil {
+				return err
+			}
+		}
+		mediaOpts, err := attachmentPrepareOptions(cmd)
 		if err != nil {
 			return err
 		}
@@ -211,11 +276,57 @@ var cardCreateCmd = &cobra.Command{
 			TemplateID: templateID,
 		}
 
+		// Field names are resolved against the template, so it is fetched
+		// even for a dry run
+		var client *mochi.Client
+		if len(values) > 0 {
+			if client, err = getClient(cmd); err != nil {
+				return err
+			}
+			tmpl, err := client.GetTemplate(templateID)
+			if err != nil {
+				return fmt.Errorf("failed to get template: %w", err)
+			}
+			if card.Fields, err = templating.ResolveFields(tmpl, values, allowMissing); err != nil {
+				return err
+			}
+		}
+
+		if dryRun {
+			printInfo("Dry run - would create card:")
+			printInfo(fmt.Sprintf("  Deck: %s", deckID))
+			printInfo(fmt.Sprintf("  Name: %s", name))
+			if content != "" {
+				printInfo(fmt.Sprintf("  Content: %s", truncateString(content, 50)))
+			}
+			ids := make([]string, 0, len(card.Fields))
+			for id := range card.Fields {
+				ids = append(ids, id)
+			}
+			sort.Strings(ids)
+			for _, id := range ids {
+				printInfo(fmt.Sprintf("  Field %s: %s", id, truncateString(card.Fields[id].Value, 50)))
+			}
+			printLocalMedia(localMedia)
+			return nil
+		}
+
+		if client == nil {
+			if client, err = getClient(cmd); err != nil {
+				return err
+			}
+		}
+
 		created, err := client.CreateCard(card)
 		if err != nil {
 			return err
 		}
 
+		// Attachments need the card's ID, so they go up after it is created
+		if err := uploadLocalMedia(client, created.ID, localMedia, nil, mediaOpts); err != nil {
+			return fmt.Errorf("card %s was created, but its media could not be uploaded: %w", created.ID, err)
+		}
+
 		if idOnly || outputOnly == "id" {
 			fmt.Println(created.ID)
 			return nil
@@ -237,17 +348,19 @@ var cardCreateCmd = &cobra.Command{
 var cardUpdateCmd = &cobra.Command{
 	Use:   "update <card-id>",
 	Short: "Update a card",
-	Args:  cobra.ExactArgs(1),
+	Long: `Update a card. Only the fields given on the command line are changed.
+
+As with card create, local images linked from new content are uploaded as
+attachments and the links rewritten to @media/<name>, unless
+--no-upload-media is given.`,
+	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		cardID := args[0]
 
 		content, _ := cmd.Flags().GetString("content")
-		name, _ := cmd.Flags().GetString("name")
-		deckID, _ := cmd.Flags().GetString("deck")
-		archived, _ := cmd.Flags().GetBool("archive")
-		unarchive, _ := cmd.Flags().GetBool("unarchive")
 		file, _ := cmd.Flags().GetString("file")
 		stdin, _ := cmd.Flags().GetBool("stdin")
+		noUploadMedia, _ := cmd.Flags().GetBool("no-upload-media")
 
 		// Get content from various sources
 		if stdin {
@@ -264,65 +377,81 @@ var cardUpdateCmd = &cobra.Command{
 			content = string(data)
 		}
 
-		client, err := getClient()
-		if err != nil {
-			return err
+		var localMedia []media.LocalFile
+		if content != "" && !noUploadMedia {
+			var err error
+			if content, localMedia, err = findLocalMedia(content, file); err != nil {
+				return err
+			}
 		}
-
-		// Get existing card to update only provided fields
-		existing, err := client.GetCard(cardID)
+		mediaOpts, err := attachmentPrepareOptions(cmd)
 		if err != nil {
 			return err
 		}
 
-		update := &models.Card{
-			ID: existing.ID,
-		}
+		var patch models.CardPatch
+		flags := cmd.Flags()
 
 		if content != "" {
-			update.Content = content
+			patch.Content = models.Set(content)
 		}
-		if name != "" {
-			update.Name = name
+		if flags.Changed("name") {
+			name, _ := flags.GetString("name")
+			patch.Name = models.Set(name)
+		} else if clear, _ := flags.GetBool("clear-name"); clear {
+			patch.Name = models.Null[string]()
 		}
-		if deckID != "" {
-			update.DeckID = deckID
+		if flags.Changed("deck") {
+			deckID, _ := flags.GetString("deck")
+			patch.DeckID = models.Set(deckID)
 		}
-		if archived {
-			update.Archived = true
-		} else if unarchive {
-			update.Archived = false
+		if flags.Changed("pos") {
+			pos, _ := flags.GetString("pos")
+			patch.Pos = models.Set(pos)
+		}
+		if flags.Changed("tags") {
+			tags, _ := flags.GetStringSlice("tags")
+			patch.ManualTags = models.Set(tags)
+		} else if clear, _ := flags.GetBool("clear-tags"); clear {
+			patch.ManualTags = models.Set([]string{})
+		}
+		if archive, _ := flags.GetBool("archive"); archive {
+			patch.Archived = models.Set(true)
+		} else if unarchive, _ := flags.GetBool("unarchive"); unarchive {
+			patch.Archived = models.Set(false)
+		}
+		if flags.Changed("review-reverse") {
+			reverse, _ := flags.GetBool("review-reverse")
+			patch.ReviewReverse = models.Set(reverse)
+		}
+
+		if patch.IsEmpty() {
+			return fmt.Errorf("nothing to update (see --help for the available flags)")
 		}
 
 		if dryRun {
-			printInfo("Dry run - would update card:")
-			printInfo(fmt.Sprintf("  ID: %s", cardID))
-			if content != "" {
-				printInfo(fmt.Sprintf("  Content: %s", truncateString(content, 50)))
-			}
-			if name != "" {
-				printInfo(fmt.Sprintf("  Name: %s", name))
-			}
+			printPatch("card", cardID, patch.Payload())
+			printLocalMedia(localMedia)
 			return nil
 		}
 
-		payload := map[string]interface{}{}
-		if content != "" {
-			payload["content"] = content
-		}
-		if name != "" {
-			payload["name"] = name
-		}
-		if deckID != "" {
-			payload["deck-id"] = deckID
+		client, err := getClient(cmd)
+		if err != nil {
+			return err
 		}
-		if archived {
-			payload["archived?"] = true
-		} else if unarchive {
-			payload["archived?"] = false
+
+		// Upload media before the content linking to it
+		if len(localMedia) > 0 {
+			current, err := client.GetCard(cardID)
+			if err != nil {
+				return err
+			}
+			if err := uploadLocalMedia(client, cardID, localMedia, current.Attachments, mediaOpts); err != nil {
+				return err
+			}
 		}
 
-		updated, err := client.UpdateCardFields(cardID, payload)
+		updated, err := client.UpdateCard(cardID, patch)
 		if err != nil {
 			return err
 		}
@@ -339,6 +468,182 @@ var cardUpdateCmd = &cobra.Command{
 	},
 }
 
+// cardEditCmd edits a card in a text editor
+var cardEditCmd = &cobra.Command{
+	Use:   "edit <card-id>",
+	Short: "Edit a card in your editor",
+	Long: `Open a card in $VISUAL or $EDITOR (vi by default) as a markdown file. The
+YAML front matter holds the name, deck ID, manual tags, archived state and,
+for cards using a template, the template fields by name; the content
+follows it. Save and close the editor to update the card with what changed;
+close it without changes to cancel.
+
+Before updating, the card is fetched again. If it changed since it was
+opened (its updated-at moved), you are offered to re-open the editor with
+your version, which is then compared against the current card; --force
+updates it regardless. Files that can't be read back can be re-opened too.
+When you decline, the path of the file holding your edits is printed.
+
+With --dry-run, the diff of your edits is printed and nothing is updated.`,
+	Example: `  mochi card edit CARD_ID
+  EDITOR="code --wait" mochi card edit CARD_ID`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cardID := args[0]
+		force, _ := cmd.Flags().GetBool("force")
+
+		client, err := getClient(cmd)
+		if err != nil {
+			return err
+		}
+
+		card, err := client.GetCard(cardID)
+		if err != nil {
+			return err
+		}
+
+		var tmpl *models.Template
+		if card.TemplateID != "" {
+			if tmpl, err = client.GetTemplate(card.TemplateID); err != nil {
+				return fmt.Errorf("failed to get template %s: %w", card.TemplateID, err)
+			}
+		}
+		// The deck path is only a hint next to the deck ID
+		paths, _ := deckPaths(client)
+
+		render := func(card *models.Card) ([]byte, error) {
+			header := fmt.Sprintf("Card %s. Save and close the editor to update it; close it without\nchanges to cancel.", card.ID)
+			return cardfile.Render(card, tmpl, paths[card.DeckID], header)
+		}
+		original, err := render(card)
+		if err != nil {
+			return err
+		}
+
+		f, err := os.CreateTemp("", "mochi-card-"+card.ID+"-*.md")
+		if err != nil {
+			return err
+		}
+		path := f.Name()
+		f.Close()
+		keep := false
+		defer func() {
+			if keep {
+				printStderrWarning(fmt.Sprintf("Your edits are saved in %s", path))
+			} else {
+				os.Remove(path)
+			}
+		}()
+
+		text := original
+		for {
+			if err := os.WriteFile(path, text, 0600); err != nil {
+				return err
+			}
+			if err := runEditor(cmd, path); err != nil {
+				keep = true
+				return err
+			}
+			if text, err = os.ReadFile(path); err != nil {
+				return err
+			}
+
+			edit, err := cardfile.Parse(text)
+			var patch models.CardPatch
+			if err == nil {
+				patch, err = edit.Patch(card, tmpl)
+			}
+			if err != nil {
+				if !quiet && confirm(cmd, fmt.Sprintf("%v\nRe-open the editor?", err)) {
+					continue
+				}
+				keep = true
+				return err
+			}
+
+			if patch.IsEmpty() {
+				printInfo("No changes")
+				return nil
+			}
+			if !quiet {
+				fmt.Print(textdiff.Unified(string(original), string(text), "a/"+card.ID+".md", "b/"+card.ID+".md", 3))
+			}
+			if dryRun {
+				printPatch("card", card.ID, patch.Payload())
+				return nil
+			}
+
+			if !force {
+				current, err := client.GetCard(card.ID)
+				if err != nil {
+					keep = true
+					return err
+				}
+				if !sameUpdatedAt(current, card) {
+					printWarning(fmt.Sprintf("Card %s was changed remotely since it was opened:", card.ID))
+					if remote, err := render(current); err == nil && !quiet {
+						fmt.Print(textdiff.Unified(string(original), string(remote), "opened/"+card.ID+".md", "remote/"+card.ID+".md", 3))
+					}
+					if !quiet && confirm(cmd, "Re-open the editor to apply your version to the current card?") {
+						card = current
+						if original, err = render(card); err != nil {
+							return err
+						}
+						continue
+					}
+					keep = true
+					return fmt.Errorf("card %s was changed remotely; not updated (use --force to overwrite)", card.ID)
+				}
+			}
+
+			updated, err := client.UpdateCardFields(card.ID, patch.Payload())
+			if err != nil {
+				keep = true
+				return err
+			}
+
+			if !quiet {
+				printSuccess(fmt.Sprintf("Card updated: %s", updated.ID))
+			}
+
+			if format == "json" {
+				printJSON(updated)
+			}
+
+			return nil
+		}
+	},
+}
+
+// runEditor opens a file in the user's editor: $VISUAL, then $EDITOR, then
+// vi. The editor setting may include arguments, e.g. "code --wait".
+func runEditor(cmd *cobra.Command, path string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+
+	args := strings.Fields(editor)
+	c := exec.CommandContext(cmd.Context(), args[0], append(args[1:], path)...)
+	c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := c.Run(); err != nil {
+		return fmt.Errorf("editor %s failed: %w", args[0], err)
+	}
+	return nil
+}
+
+// sameUpdatedAt reports whether two versions of a card were last updated at
+// the same time
+func sameUpdatedAt(a, b *models.Card) bool {
+	if a.UpdatedAt == nil || b.UpdatedAt == nil {
+		return a.UpdatedAt == nil && b.UpdatedAt == nil
+	}
+	return a.UpdatedAt.Equal(b.UpdatedAt.Time)
+}
+
 // cardDeleteCmd deletes a card
 var cardDeleteCmd = &cobra.Command{
 	Use:   "delete <card-id>",
@@ -350,11 +655,7 @@ var cardDeleteCmd = &cobra.Command{
 		force, _ := cmd.Flags().GetBool("force")
 
 		if !force && !quiet {
-			fmt.Printf("Delete card %s? This cannot be undone. [y/N]: ", cardID)
-			reader := bufio.NewReader(os.Stdin)
-			response, _ := reader.ReadString('\n')
-			response = strings.TrimSpace(strings.ToLower(response))
-			if response != "y" && response != "yes" {
+			if !confirm(cmd, fmt.Sprintf("Delete card %s? This cannot be undone.", cardID)) {
 				fmt.Println("Cancelled")
 				return nil
 			}
@@ -365,7 +666,7 @@ var cardDeleteCmd = &cobra.Command{
 			return nil
 		}
 
-		client, err := getClient()
+		client, err := getClient(cmd)
 		if err != nil {
 			return err
 		}
@@ -386,35 +687,107 @@ var cardDeleteCmd = &cobra.Command{
 var cardSearchCmd = &cobra.Command{
 	Use:   "search <query>",
 	Short: "Search cards",
-	Long:  `Search for cards by content (client-side search).`,
-	Args:  cobra.ExactArgs(1),
+	Long: `Search for cards with a query (client-side search). Words and "quoted
+phrases" are looked for in the name and content, ignoring case; terms next
+to each other must all match.
+
+Terms:
+  word, "exact phrase"   Text in the name or content
+  /regex/                Regular expression over the name or content
+  tag:grammar            Manual tag (tag:~regex matches any tag)
+  deck:"Spanish/Verbs"   Deck ID or path; deck:Spanish includes subdecks
+  name:text, content:text
+                         Text in the name or content (name:=text for all
+                         of it, name:~^regex for a regular expression)
+  template:Vocab         Template ID or name
+  id:ID                  Card ID
+  created:>2025-01-01    Creation date; also updated:. Operators are
+                         : = > >= < <=, dates YYYY, YYYY-MM, YYYY-MM-DD or
+                         RFC 3339
+  reviews:>=3, lapses:>2 Review and lapse counts, with the same operators
+  is:archived            State: archived, trashed, new or reviewed; the
+                         bare words archived, trashed and new mean the same
+  has:attachments        Also has:name, has:reviews, has:tags, has:template
+
+Combine terms with AND (implied), OR, NOT or a leading -, and group them
+with parentheses. Quote a state word ("new") to search for it as text. A
+query starting with - goes after --, e.g. mochi card search -- '-archived'.
+
+The same queries select cards for --where, and the card list filters
+(--tag, --archived, --created-after and so on) narrow the matches.
+
+With --index, the search runs against the local search index (see mochi
+index) instead of downloading every card, and results are ranked by
+relevance with a snippet of the matching text. Words then match their
+English variants ("verbs" finds "verb") and accents are ignored.`,
+	Example: `  mochi card search 'tag:grammar deck:"Spanish/Verbs" name:~^irregular'
+  mochi card search 'created:>2025-01-01 -archived "exact phrase"'
+  mochi card search '/\bser\b/ OR (tag:verbs lapses:>=2)'`,
+	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		query := args[0]
 		deckID, _ := cmd.Flags().GetString("deck")
 
-		client, err := getClient()
+		q, err := query.Parse(args[0])
+		if err != nil {
+			return err
+		}
+		sel, err := parseCardSelection(cmd)
+		if err != nil {
+			return err
+		}
+		limit, _ := cmd.Flags().GetInt("limit")
+		if limit < 0 {
+			return fmt.Errorf("--limit must not be negative")
+		}
+
+		if useIndex, _ := cmd.Flags().GetBool("index"); useIndex {
+			return searchIndex(cmd, q, sel, deckID, limit)
+		}
+
+		client, err := getClient(cmd)
 		if err != nil {
 			return err
 		}
 
-		cards, err := client.SearchCards(query, deckID)
+		env, err := queryEnv(client, q, sel.where)
 		if err != nil {
 			return err
 		}
+		queryMatch := q.Matcher(env)
+		match := sel.matcherEnv(env)
+
+		// On Ctrl-C, still print the matches found so far
+		cards, err := client.FilterCards(deckID, func(card *models.Card) bool {
+			return queryMatch(card) && match(card)
+		})
+		if err != nil && !isInterrupted(err) {
+			return err
+		}
+		if limit > 0 && len(cards) > limit {
+			cards = cards[:limit]
+		}
+		partial := err != nil
+		if partial && !quiet {
+			fmt.Fprintf(os.Stderr, "Search interrupted; showing %d partial results\n", len(cards))
+		}
 
 		if idOnly || outputOnly == "id" {
 			for _, card := range cards {
 				fmt.Println(card.ID)
 			}
-			return nil
+			return err
 		}
 
 		switch format {
 		case "json":
-			printJSON(map[string]interface{}{
+			result := map[string]interface{}{
 				"cards": cards,
 				"count": len(cards),
-			})
+			}
+			if partial {
+				result["partial"] = true
+			}
+			printJSON(result)
 		case "compact":
 			printCompactJSON(cards)
 		case "table":
@@ -436,6 +809,272 @@ var cardSearchCmd = &cobra.Command{
 			}
 		}
 
+		return err
+	},
+}
+
+// cardDupesCmd finds near-duplicate cards
+var cardDupesCmd = &cobra.Command{
+	Use:   "dupes",
+	Short: "Find duplicate and near-duplicate cards",
+	Long: `Find cards with the same or nearly the same content, in all decks or one
+deck. Content is compared after dropping markdown formatting, images, link
+targets and punctuation, by the Jaccard similarity of its 5-character
+shingles; MinHash signatures pick the pairs worth comparing, so large
+collections stay fast. Cards using a template are compared by their field
+values. Cards similar to each other, directly or through another card, form
+a cluster.
+
+Trashed cards are skipped, and so are archived cards unless --archived
+asks for them. The other card list filters (--tag, --where
+and so on) narrow the cards compared.
+
+In each cluster, the card with the most reviews is kept (the oldest one on
+a tie). --merge asks about each cluster whether to archive the other cards,
+--auto archives them after one confirmation, and --action delete deletes
+them instead. --force skips the confirmations; with --quiet it is required.
+With --dry-run, nothing is changed. With --format json or compact, clusters
+and prompts are written to stderr.`,
+	Example: `  mochi card dupes --format table
+  mochi card dupes --deck DECK_ID --threshold 0.7
+  mochi card dupes --merge
+  mochi card dupes --auto --action delete --dry-run`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		deckID, _ := cmd.Flags().GetString("deck")
+		threshold, _ := cmd.Flags().GetFloat64("threshold")
+		merge, _ := cmd.Flags().GetBool("merge")
+		auto, _ := cmd.Flags().GetBool("auto")
+		action, _ := cmd.Flags().GetString("action")
+		force, _ := cmd.Flags().GetBool("force")
+
+		if threshold <= 0 || threshold > 1 {
+			return fmt.Errorf("--threshold must be greater than 0 and at most 1")
+		}
+		if action != "archive" && action != "delete" {
+			return fmt.Errorf("unknown action %q (expected archive or delete)", action)
+		}
+		if (merge || auto) && quiet && !force && !dryRun {
+			return fmt.Errorf("--quiet skips the confirmation prompts of --merge and --auto; add --force to %s duplicates without them", action)
+		}
+		sel, err := parseCardSelection(cmd)
+		if err != nil {
+			return err
+		}
+
+		client, err := getClient(cmd)
+		if err != nil {
+			return err
+		}
+
+		match, err := sel.matcher(client)
+		if err != nil {
+			return err
+		}
+
+		skipArchived := sel.filter.Archived == nil
+		cards, err := client.FilterCards(deckID, func(card *models.Card) bool {
+			if mochi.IsTrashed(card) || (skipArchived && card.Archived) {
+				return false
+			}
+			return match(card)
+		})
+		if err != nil {
+			return err
+		}
+
+		docs := make([]dupes.Doc, len(cards))
+		byID := make(map[string]*models.Card, len(cards))
+		for i := range cards {
+			docs[i] = dupes.Doc{ID: cards[i].ID, Text: cardText(&cards[i])}
+			byID[cards[i].ID] = &cards[i]
+		}
+
+		type dupeCard struct {
+			ID      string `json:"id"`
+			Name    string `json:"name,omitempty"`
+			DeckID  string `json:"deck-id"`
+			Reviews int    `json:"reviews"`
+			Keep    bool   `json:"keep,omitempty"`
+			// Similarity is to the kept card
+			Similarity float64 `json:"similarity,omitempty"`
+			Result     string  `json:"result,omitempty"`
+			Error      string  `json:"error,omitempty"`
+		}
+		type dupeCluster struct {
+			Similarity float64     `json:"similarity"`
+			Keep       string      `json:"keep"`
+			Cards      []*dupeCard `json:"cards"`
+		}
+
+		results := []*dupeCluster{}
+		duplicates := 0
+		for _, c := range dupes.Find(docs, threshold) {
+			members := make([]*models.Card, len(c.IDs))
+			for i, id := range c.IDs {
+				members[i] = byID[id]
+			}
+			keep := dupes.Keeper(members)
+
+			cluster := &dupeCluster{Similarity: c.MaxSimilarity(), Keep: keep.ID}
+			for _, card := range members {
+				dc := &dupeCard{ID: card.ID, Name: card.Name, DeckID: card.DeckID, Reviews: len(card.Reviews)}
+				if card == keep {
+					dc.Keep = true
+				} else {
+					dc.Similarity = dupes.Compare(cardText(keep), cardText(card))
+				}
+				cluster.Cards = append(cluster.Cards, dc)
+			}
+			// The kept card first, then the closest duplicates
+			sort.SliceStable(cluster.Cards, func(i, j int) bool {
+				a, b := cluster.Cards[i], cluster.Cards[j]
+				if a.Keep != b.Keep {
+					return a.Keep
+				}
+				return a.Similarity > b.Similarity
+			})
+
+			duplicates += len(cluster.Cards) - 1
+			results = append(results, cluster)
+		}
+
+		// Clusters shown with the prompts go to stderr when stdout carries
+		// JSON
+		var out io.Writer = os.Stdout
+		if format == "json" || format == "compact" {
+			out = os.Stderr
+		}
+		printCluster := func(n int, c *dupeCluster) {
+			fmt.Fprintf(out, "Cluster %d (similarity %.2f)\n", n, c.Similarity)
+			for _, dc := range c.Cards {
+				label := "keep"
+				if !dc.Keep {
+					label = fmt.Sprintf("%.2f", dc.Similarity)
+				}
+				line := fmt.Sprintf("  %-5s %s", label, dc.ID)
+				if dc.Name != "" {
+					line += "  " + truncateString(dc.Name, 40)
+				}
+				line += fmt.Sprintf(" (%d reviews)", dc.Reviews)
+				switch {
+				case dc.Error != "":
+					line += "  error: " + dc.Error
+				case dc.Result != "":
+					line += "  " + dc.Result
+				}
+				fmt.Fprintln(out, line)
+			}
+		}
+
+		past := map[string]string{"archive": "archived", "delete": "deleted"}[action]
+		interactive := merge && !dryRun && !force
+		if auto && duplicates > 0 && !dryRun && !force {
+			prompt := fmt.Sprintf("Keep one card of each of %d clusters and %s the other %d?", len(results), action, duplicates)
+			if !confirmTo(cmd, out, prompt) {
+				fmt.Fprintln(out, "Cancelled")
+				return nil
+			}
+		}
+
+		changed, failed := 0, 0
+		if merge || auto {
+			for n, c := range results {
+				if cmd.Context().Err() != nil {
+					break
+				}
+
+				if interactive {
+					printCluster(n+1, c)
+					if !confirmTo(cmd, out, fmt.Sprintf("Keep %s and %s the other %d?", c.Keep, action, len(c.Cards)-1)) {
+						for _, dc := range c.Cards[1:] {
+							dc.Result = "skipped"
+						}
+						continue
+					}
+				}
+
+				for _, dc := range c.Cards[1:] {
+					if dryRun {
+						dc.Result = "would " + action
+						changed++
+						continue
+					}
+					var err error
+					if action == "delete" {
+						err = client.DeleteCard(dc.ID)
+					} else {
+						_, err = client.UpdateCard(dc.ID, models.CardPatch{Archived: models.Set(true)})
+					}
+					if err != nil {
+						dc.Error = err.Error()
+						failed++
+						continue
+					}
+					dc.Result = past
+					changed++
+				}
+			}
+		}
+
+		switch format {
+		case "json":
+			result := map[string]interface{}{
+				"cards":      len(cards),
+				"threshold":  threshold,
+				"duplicates": duplicates,
+				"clusters":   results,
+			}
+			if merge || auto {
+				result[past] = changed
+				result["failed"] = failed
+				result["dry-run"] = dryRun
+			}
+			printJSON(result)
+		case "compact":
+			printCompactJSON(results)
+		case "table":
+			var rows [][]string
+			for n, c := range results {
+				for _, dc := range c.Cards {
+					similarity := "keep"
+					if !dc.Keep {
+						similarity = fmt.Sprintf("%.2f", dc.Similarity)
+					}
+					status := dc.Result
+					if dc.Error != "" {
+						status = "error: " + dc.Error
+					}
+					rows = append(rows, []string{strconv.Itoa(n + 1), dc.ID, truncateString(dc.Name, 30), dc.DeckID, strconv.Itoa(dc.Reviews), similarity, status})
+				}
+			}
+			printTable([]string{"CLUSTER", "ID", "NAME", "DECK", "REVIEWS", "SIMILARITY", "RESULT"}, rows)
+		default:
+			if !interactive {
+				for n, c := range results {
+					printCluster(n+1, c)
+				}
+			}
+		}
+
+		if err := cmd.Context().Err(); err != nil {
+			return err
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of %d duplicate cards could not be %s", failed, duplicates, past)
+		}
+		if format == "json" || quiet {
+			return nil
+		}
+		summary := fmt.Sprintf("Found %d duplicates in %d clusters among %d cards", duplicates, len(results), len(cards))
+		switch {
+		case (merge || auto) && dryRun:
+			printInfo(fmt.Sprintf("Dry run - %s; would %s %d cards", summary, action, changed))
+		case merge || auto:
+			printSuccess(fmt.Sprintf("%s; %s %d cards", summary, past, changed))
+		default:
+			printSuccess(summary)
+		}
 		return nil
 	},
 }
@@ -446,12 +1085,16 @@ func init() {
 	cardCmd.AddCommand(cardGetCmd)
 	cardCmd.AddCommand(cardCreateCmd)
 	cardCmd.AddCommand(cardUpdateCmd)
+	cardCmd.AddCommand(cardEditCmd)
 	cardCmd.AddCommand(cardDeleteCmd)
 	cardCmd.AddCommand(cardSearchCmd)
+	cardCmd.AddCommand(cardDupesCmd)
 
 	// List flags
 	cardListCmd.Flags().StringP("deck", "d", "", "Filter by deck ID")
-	cardListCmd.Flags().IntP("limit", "l", 10, "Number of cards to return")
+	cardListCmd.Flags().IntP("limit", "l", 10, "Number of cards to return (with --all, the total cap)")
+	addListFlags(cardListCmd)
+	addCardFilterFlags(cardListCmd)
 
 	// Get flags (none needed)
 
@@ -462,19 +1105,49 @@ func init() {
 	cardCreateCmd.Flags().StringP("template", "t", "", "Template ID")
 	cardCreateCmd.Flags().StringP("file", "F", "", "Read content from file")
 	cardCreateCmd.Flags().Bool("stdin", false, "Read content from stdin")
+	cardCreateCmd.Flags().StringArray("field", nil, "Template field value as Name=value (repeatable)")
+	cardCreateCmd.Flags().Bool("no-upload-media", false, "Send local image links as they are instead of uploading the files")
+	cardCreateCmd.Flags().String("fields-file", "", "JSON or YAML file of template field values (- for stdin)")
+	cardCreateCmd.Flags().Bool("allow-missing", false, "Allow leaving required template fields empty")
 
 	// Update flags
 	cardUpdateCmd.Flags().StringP("content", "c", "", "New content")
-	cardUpdateCmd.Flags().StringP("name", "n", "", "New name")
+	cardUpdateCmd.Flags().StringP("name", "n", "", "New name (may be empty)")
+	cardUpdateCmd.Flags().Bool("clear-name", false, "Remove the card name")
 	cardUpdateCmd.Flags().StringP("deck", "d", "", "Move to different deck")
+	cardUpdateCmd.Flags().String("pos", "", "New position within the deck")
+	cardUpdateCmd.Flags().StringSlice("tags", nil, "Replace the manual tags (comma-separated)")
+	cardUpdateCmd.Flags().Bool("clear-tags", false, "Remove all manual tags")
 	cardUpdateCmd.Flags().Bool("archive", false, "Archive the card")
 	cardUpdateCmd.Flags().Bool("unarchive", false, "Unarchive the card")
+	cardUpdateCmd.Flags().Bool("review-reverse", false, "Also review the card in reverse (use --review-reverse=false to turn off)")
 	cardUpdateCmd.Flags().StringP("file", "F", "", "Read content from file")
 	cardUpdateCmd.Flags().Bool("stdin", false, "Read content from stdin")
+	cardUpdateCmd.Flags().Bool("no-upload-media", false, "Send local image links as they are instead of uploading the files")
+	cardUpdateCmd.MarkFlagsMutuallyExclusive("name", "clear-name")
+	cardUpdateCmd.MarkFlagsMutuallyExclusive("tags", "clear-tags")
+	cardUpdateCmd.MarkFlagsMutuallyExclusive("archive", "unarchive")
+
+	// Edit flags
+	cardEditCmd.Flags().Bool("force", false, "Update the card even if it changed remotely while editing")
 
 	// Delete flags
 	cardDeleteCmd.Flags().Bool("force", false, "Skip confirmation prompt")
 
 	// Search flags
 	cardSearchCmd.Flags().StringP("deck", "d", "", "Limit search to specific deck")
+	cardSearchCmd.Flags().IntP("limit", "l", 0, "Maximum number of results (0 for all)")
+	cardSearchCmd.Flags().Bool("index", false, "Search the local search index, ranking results by relevance")
+	cardSearchCmd.Flags().Bool("refresh", false, "With --index, refresh the index before searching")
+	addCardFilterFlags(cardSearchCmd)
+
+	// Dupes flags
+	cardDupesCmd.Flags().StringP("deck", "d", "", "Only compare cards in this deck")
+	cardDupesCmd.Flags().Float64("threshold", 0.85, "Minimum similarity (0-1) for cards to count as duplicates")
+	cardDupesCmd.Flags().Bool("merge", false, "Ask about each cluster whether to keep one card and archive the rest")
+	cardDupesCmd.Flags().Bool("auto", false, "Keep one card of every cluster and archive the rest without asking per cluster")
+	cardDupesCmd.Flags().String("action", "archive", "What to do with the duplicates when merging: archive or delete")
+	cardDupesCmd.Flags().Bool("force", false, "Skip confirmation prompts")
+	cardDupesCmd.MarkFlagsMutuallyExclusive("merge", "auto")
+	addCardFilterFlags(cardDupesCmd)
 }
@@ -19,8 +19,9 @@ var configCmd = &cobra.Command{
 var configAddCmd = &cobra.Command{
 	Use:   "add <name> <api-key>",
 	Short: "Add a new profile",
-	Long:  `Add a new profile with an API key. The first profile added becomes active by default.`,
-	Args:  cobra.ExactArgs(2),
+	Long: `Add a new profile with an API key. The first profile added becomes active by default.
+Pass --base-url to store a non-default API endpoint with the profile.`,
+	Args: cobra.ExactArgs(2),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		name := args[0]
 		apiKey := args[1]
@@ -34,6 +35,12 @@ var configAddCmd = &cobra.Command{
 			return err
 		}
 
+		if cmd.Flags().Changed("base-url") {
+			if err := cfg.SetBaseURL(name, baseURL); err != nil {
+				return err
+			}
+		}
+
 		if !quiet {
 			fmt.Printf("Profile '%s' added successfully\n", name)
 			if cfg.ActiveProfile == name {
@@ -70,6 +77,88 @@ var configRemoveCmd = &cobra.Command{
 	},
 }
 
+// configSetBaseURLCmd sets the API base URL of a profile
+var configSetBaseURLCmd = &cobra.Command{
+	Use:   "set-base-url <name> [url]",
+	Short: "Set the API base URL of a profile",
+	Long: `Point a profile at a different Mochi API host, such as a staging server,
+a recording proxy or 'mochi dev fake-server'. Omit the URL to reset the
+profile to the default endpoint.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		endpoint := ""
+		if len(args) > 1 {
+			endpoint = args[1]
+		}
+
+		cfg, err := config.GetConfig()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		if err := cfg.SetBaseURL(name, endpoint); err != nil {
+			return err
+		}
+
+		if !quiet {
+			if endpoint == "" {
+				fmt.Printf("Profile '%s' now uses the default API endpoint\n", name)
+			} else {
+				fmt.Printf("Profile '%s' now uses %s\n", name, endpoint)
+			}
+		}
+
+		return nil
+	},
+}
+
+// configSetCmd sets a profile setting
+var configSetCmd = &cobra.Command{
+	Use:   "set <name> <key> [value]",
+	Short: "Set a profile setting",
+	Long: `Set a setting stored with a profile. Omit the value to reset it to the default.
+
+Settings:
+  base-url                 API base URL
+  max-retries              Retries for rate-limited or failed requests
+  retry-max-wait           Longest wait between retries (e.g. 30s)
+  rate-limit               Maximum requests per second (0 for unlimited)
+  attachment-max-size      Largest attachment to upload (e.g. 10MB)
+  attachment-max-dim       Downscale uploaded images to this many pixels
+  attachment-jpeg-quality  Quality (1-100) for re-encoded JPEG uploads
+
+Flags such as --max-retries and --max-dim override profile settings for a
+single command.`,
+	Args: cobra.RangeArgs(2, 3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name, key := args[0], args[1]
+		value := ""
+		if len(args) > 2 {
+			value = args[2]
+		}
+
+		cfg, err := config.GetConfig()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		if err := cfg.SetProfileValue(name, key, value); err != nil {
+			return err
+		}
+
+		if !quiet {
+			if value == "" {
+				fmt.Printf("Reset %s for profile '%s'\n", key, name)
+			} else {
+				fmt.Printf("Set %s=%s for profile '%s'\n", key, value, name)
+			}
+		}
+
+		return nil
+	},
+}
+
 // configUseCmd sets the active profile
 var configUseCmd = &cobra.Command{
 	Use:   "use <name>",
@@ -154,10 +243,7 @@ var configResetCmd = &cobra.Command{
 	Long:  `Remove all profiles and reset configuration to defaults.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		if !quiet {
-			fmt.Print("Are you sure? This will remove all profiles. [y/N]: ")
-			var response string
-			fmt.Scanln(&response)
-			if strings.ToLower(response) != "y" {
+			if !confirm(cmd, "Are you sure? This will remove all profiles.") {
 				fmt.Println("Cancelled")
 				return nil
 			}
@@ -187,4 +273,6 @@ func init() {
 	configCmd.AddCommand(configUseCmd)
 	configCmd.AddCommand(configListCmd)
 	configCmd.AddCommand(configResetCmd)
+	configCmd.AddCommand(configSetBaseURLCmd)
+	configCmd.AddCommand(configSetCmd)
 }
@@ -1,13 +1,10 @@
 package cmd
 
 import (
-	"bufio"
-	"encoding/json"
 	"fmt"
-	"os"
-	"strings"
 
-	"github.com/nerveband/mochi-cli/internal/models"
+	"github.com/nerveband/mochi-cli/pkg/mochi"
+	"github.com/nerveband/mochi-cli/pkg/mochi/models"
 	"github.com/spf13/cobra"
 )
 
@@ -22,37 +19,27 @@ var deckCmd = &cobra.Command{
 var deckListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List all decks",
+	Long: `List decks.
+
+Only the first page is shown unless --all is given. JSON output includes a
+bookmark that can be passed to --bookmark to fetch the next page.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		client, err := getClient()
+		client, err := getClient(cmd)
 		if err != nil {
 			return err
 		}
 
-		resp, err := client.ListDecks("")
-		if err != nil {
+		it := client.Decks(listIterOptions(cmd))
+		decks, err := mochi.Collect(it)
+		if err != nil && !isInterrupted(err) {
 			return err
 		}
 
-		// Convert docs to decks
-		docs, ok := resp.Docs.([]interface{})
-		if !ok {
-			return fmt.Errorf("unexpected response format")
-		}
-
-		var decks []models.Deck
-		for _, doc := range docs {
-			data, _ := json.Marshal(doc)
-			var deck models.Deck
-			if err := json.Unmarshal(data, &deck); err == nil {
-				decks = append(decks, deck)
-			}
-		}
-
 		if idOnly || outputOnly == "id" {
 			for _, deck := range decks {
 				fmt.Println(deck.ID)
 			}
-			return nil
+			return err
 		}
 
 		if outputOnly != "" {
@@ -62,15 +49,19 @@ var deckListCmd = &cobra.Command{
 					fmt.Println(val)
 				}
 			}
-			return nil
+			return err
 		}
 
 		switch format {
 		case "json":
-			printJSON(map[string]interface{}{
+			result := map[string]interface{}{
 				"decks":    decks,
-				"bookmark": resp.Bookmark,
-			})
+				"bookmark": it.Bookmark(),
+			}
+			if warnings := it.Warnings(); len(warnings) > 0 {
+				result["warnings"] = warningMessages(warnings)
+			}
+			printJSON(result)
 		case "compact":
 			printCompactJSON(decks)
 		case "table":
@@ -95,7 +86,7 @@ var deckListCmd = &cobra.Command{
 			}
 		}
 
-		return nil
+		return err
 	},
 }
 
@@ -107,7 +98,7 @@ var deckGetCmd = &cobra.Command{
 	RunE: func(cmd *cobra.Command, args []string) error {
 		deckID := args[0]
 
-		client, err := getClient()
+		client, err := getClient(cmd)
 		if err != nil {
 			return err
 		}
@@ -169,7 +160,7 @@ var deckCreateCmd = &cobra.Command{
 			return nil
 		}
 
-		client, err := getClient()
+		client, err := getClient(cmd)
 		if err != nil {
 			return err
 		}
@@ -210,52 +201,60 @@ var deckUpdateCmd = &cobra.Command{
 	RunE: func(cmd *cobra.Command, args []string) error {
 		deckID := args[0]
 
-		name, _ := cmd.Flags().GetString("name")
-		parentID, _ := cmd.Flags().GetString("parent")
-		sort, _ := cmd.Flags().GetInt("sort")
-		archive, _ := cmd.Flags().GetBool("archive")
-		unarchive, _ := cmd.Flags().GetBool("unarchive")
+		var patch models.DeckPatch
+		flags := cmd.Flags()
 
-		client, err := getClient()
-		if err != nil {
-			return err
+		if flags.Changed("name") {
+			name, _ := flags.GetString("name")
+			patch.Name = models.Set(name)
 		}
-
-		// Get existing deck
-		existing, err := client.GetDeck(deckID)
-		if err != nil {
-			return err
+		if flags.Changed("parent") {
+			parentID, _ := flags.GetString("parent")
+			patch.ParentID = models.Set(parentID)
+		} else if noParent, _ := flags.GetBool("no-parent"); noParent {
+			patch.ParentID = models.Null[string]()
 		}
-
-		update := &models.Deck{
-			ID: existing.ID,
+		if flags.Changed("sort") {
+			sort, _ := flags.GetInt("sort")
+			patch.Sort = models.Set(sort)
 		}
-
-		if name != "" {
-			update.Name = name
+		if archive, _ := flags.GetBool("archive"); archive {
+			patch.Archived = models.Set(true)
+		} else if unarchive, _ := flags.GetBool("unarchive"); unarchive {
+			patch.Archived = models.Set(false)
+		}
+		if flags.Changed("review-reverse") {
+			reverse, _ := flags.GetBool("review-reverse")
+			patch.ReviewReverse = models.Set(reverse)
 		}
-		if cmd.Flags().Changed("parent") {
-			update.ParentID = parentID
+		if flags.Changed("show-sides") {
+			showSides, _ := flags.GetBool("show-sides")
+			patch.ShowSides = models.Set(showSides)
 		}
-		if cmd.Flags().Changed("sort") {
-			update.Sort = sort
+		if flags.Changed("sort-by") {
+			sortBy, _ := flags.GetString("sort-by")
+			patch.SortBy = models.Set(sortBy)
 		}
-		if archive {
-			update.Archived = true
-		} else if unarchive {
-			update.Archived = false
+		if flags.Changed("cards-view") {
+			view, _ := flags.GetString("cards-view")
+			patch.CardsView = models.Set(view)
+		}
+
+		if patch.IsEmpty() {
+			return fmt.Errorf("nothing to update (see --help for the available flags)")
 		}
 
 		if dryRun {
-			printInfo("Dry run - would update deck:")
-			printInfo(fmt.Sprintf("  ID: %s", deckID))
-			if name != "" {
-				printInfo(fmt.Sprintf("  Name: %s", name))
-			}
+			printPatch("deck", deckID, patch.Payload())
 			return nil
 		}
 
-		updated, err := client.UpdateDeck(deckID, update)
+		client, err := getClient(cmd)
+		if err != nil {
+			return err
+		}
+
+		updated, err := client.UpdateDeck(deckID, patch)
 		if err != nil {
 			return err
 		}
@@ -283,11 +282,7 @@ var deckDeleteCmd = &cobra.Command{
 		force, _ := cmd.Flags().GetBool("force")
 
 		if !force && !quiet {
-			fmt.Printf("Delete deck %s? This cannot be undone. [y/N]: ", deckID)
-			reader := bufio.NewReader(os.Stdin)
-			response, _ := reader.ReadString('\n')
-			response = strings.TrimSpace(strings.ToLower(response))
-			if response != "y" && response != "yes" {
+			if !confirm(cmd, fmt.Sprintf("Delete deck %s? This cannot be undone.", deckID)) {
 				fmt.Println("Cancelled")
 				return nil
 			}
@@ -298,7 +293,7 @@ var deckDeleteCmd = &cobra.Command{
 			return nil
 		}
 
-		client, err := getClient()
+		client, err := getClient(cmd)
 		if err != nil {
 			return err
 		}
@@ -323,6 +318,9 @@ func init() {
 	deckCmd.AddCommand(deckUpdateCmd)
 	deckCmd.AddCommand(deckDeleteCmd)
 
+	// List flags
+	addListFlags(deckListCmd)
+
 	// Create flags
 	deckCreateCmd.Flags().StringP("parent", "P", "", "Parent deck ID")
 	deckCreateCmd.Flags().IntP("sort", "s", 0, "Sort order")
@@ -333,6 +331,13 @@ func init() {
 	deckUpdateCmd.Flags().IntP("sort", "s", 0, "Sort order")
 	deckUpdateCmd.Flags().Bool("archive", false, "Archive the deck")
 	deckUpdateCmd.Flags().Bool("unarchive", false, "Unarchive the deck")
+	deckUpdateCmd.Flags().Bool("no-parent", false, "Move the deck to the top level")
+	deckUpdateCmd.Flags().Bool("review-reverse", false, "Also review cards in reverse (use --review-reverse=false to turn off)")
+	deckUpdateCmd.Flags().Bool("show-sides", false, "Show all card sides at once (use --show-sides=false to turn off)")
+	deckUpdateCmd.Flags().String("sort-by", "", "Card sort order in the deck view")
+	deckUpdateCmd.Flags().String("cards-view", "", "Card view mode (e.g. list, grid)")
+	deckUpdateCmd.MarkFlagsMutuallyExclusive("parent", "no-parent")
+	deckUpdateCmd.MarkFlagsMutuallyExclusive("archive", "unarchive")
 
 	// Delete flags
 	deckDeleteCmd.Flags().Bool("force", false, "Skip confirmation prompt")
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/nerveband/mochi-cli/internal/fakeserver"
+	"github.com/spf13/cobra"
+)
+
+// devCmd represents the dev command
+var devCmd = &cobra.Command{
+	Use:   "dev",
+	Short: "Developer tools",
+	Long:  `Tools for developing and testing scripts against the Mochi API.`,
+}
+
+// devFakeServerCmd runs an in-memory Mochi API
+var devFakeServerCmd = &cobra.Command{
+	Use:   "fake-server",
+	Short: "Run a local in-memory Mochi API",
+	Long: `Run a local in-memory implementation of the Mochi API for offline testing.
+
+The server supports cards, decks, templates, due cards and attachments with
+bookmark pagination. All data is lost when the server stops.
+
+Point the CLI at it with:
+  export MOCHI_BASE_URL=http://127.0.0.1:8787/api
+  export MOCHI_API_KEY=anything`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		addr, _ := cmd.Flags().GetString("addr")
+		seed, _ := cmd.Flags().GetBool("seed")
+		requireKey, _ := cmd.Flags().GetString("require-key")
+
+		srv := fakeserver.New()
+		srv.APIKey = requireKey
+		if seed {
+			srv.Seed()
+		}
+
+		listener, err := net.Listen("tcp", addr)
+		if err != nil {
+			return fmt.Errorf("failed to listen on %s: %w", addr, err)
+		}
+
+		mux := http.NewServeMux()
+		mux.Handle("/api/", http.StripPrefix("/api", srv))
+
+		endpoint := fmt.Sprintf("http://%s/api", listener.Addr().String())
+		if !quiet {
+			fmt.Printf("Fake Mochi API listening on %s\n", endpoint)
+			fmt.Printf("  export MOCHI_BASE_URL=%s\n", endpoint)
+			fmt.Println("Press Ctrl-C to stop")
+		}
+
+		server := &http.Server{Handler: mux}
+		go func() {
+			<-cmd.Context().Done()
+			server.Close()
+		}()
+
+		if err := server.Serve(listener); err != http.ErrServerClosed {
+			return err
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(devCmd)
+	devCmd.AddCommand(devFakeServerCmd)
+
+	// Fake server flags
+	devFakeServerCmd.Flags().String("addr", "127.0.0.1:8787", "Address to listen on")
+	devFakeServerCmd.Flags().Bool("seed", false, "Populate the server with demo decks, cards and a template")
+	devFakeServerCmd.Flags().String("require-key", "", "Reject requests that don't use this API key")
+}
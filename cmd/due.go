@@ -27,7 +27,7 @@ var dueListCmd = &cobra.Command{
 			date = time.Now().Format("2006-01-02")
 		}
 
-		client, err := getClient()
+		client, err := getClient(cmd)
 		if err != nil {
 			return err
 		}
@@ -106,7 +106,7 @@ var dueCountCmd = &cobra.Command{
 			date = time.Now().Format("2006-01-02")
 		}
 
-		client, err := getClient()
+		client, err := getClient(cmd)
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/nerveband/mochi-cli/internal/config"
+	"github.com/nerveband/mochi-cli/pkg/mochi"
+)
+
+// Exit codes documented in the README
+const (
+	exitUserError   = 1
+	exitAPIError    = 2
+	exitConfigError = 3
+	exitInterrupted = 130
+)
+
+// Error categories reported with --json-errors, in addition to the API
+// categories defined in the mochi package
+const (
+	categoryConfig      = "CONFIG_ERROR"
+	categoryUser        = "USER_ERROR"
+	categoryInterrupted = "INTERRUPTED"
+)
+
+// classifyError maps an error to its category and process exit code
+func classifyError(err error) (string, int) {
+	var apiErr *mochi.Error
+	var cfgErr *config.Error
+	var netErr net.Error
+
+	switch {
+	case isInterrupted(err):
+		return categoryInterrupted, exitInterrupted
+	case errors.Is(err, config.ErrNoAPIKey):
+		return mochi.CategoryAuth, exitConfigError
+	case errors.As(err, &apiErr):
+		return apiErr.Category, exitAPIError
+	case errors.As(err, &cfgErr):
+		return categoryConfig, exitConfigError
+	case errors.As(err, &netErr):
+		return mochi.CategoryAPI, exitAPIError
+	default:
+		return categoryUser, exitUserError
+	}
+}
+
+// reportError prints err to stderr in the selected style and returns the
+// exit code for it
+func reportError(err error) int {
+	category, code := classifyError(err)
+
+	if jsonErrors {
+		out := map[string]interface{}{
+			"error":    err.Error(),
+			"category": category,
+			"code":     code,
+		}
+		var apiErr *mochi.Error
+		if errors.As(err, &apiErr) {
+			out["status"] = apiErr.StatusCode
+			out["method"] = apiErr.Method
+			out["path"] = apiErr.Path
+			if apiErr.Errors != nil {
+				out["details"] = apiErr.Errors
+			}
+		}
+		printErrorJSON(out)
+		return code
+	}
+
+	if !quiet {
+		if code == exitInterrupted {
+			fmt.Fprintln(os.Stderr, "Interrupted")
+		} else {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+		}
+	}
+	return code
+}
+
+// printErrorJSON writes a JSON error object to stderr on a single line
+func printErrorJSON(v interface{}) {
+	enc := json.NewEncoder(os.Stderr)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		fmt.Fprintf(os.Stderr, "{\"error\": %q}\n", err.Error())
+	}
+}
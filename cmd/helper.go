@@ -1,15 +1,28 @@
 package cmd
 
 import (
+	"bufio"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"sort"
+	"strings"
+	"time"
 
-	"github.com/nerveband/mochi-cli/internal/api"
+	"github.com/nerveband/mochi-cli/internal/cassette"
 	"github.com/nerveband/mochi-cli/internal/config"
+	"github.com/nerveband/mochi-cli/internal/trace"
+	"github.com/nerveband/mochi-cli/pkg/mochi"
+	"github.com/nerveband/mochi-cli/pkg/mochi/models"
+	"github.com/spf13/cobra"
 )
 
-// getClient creates an API client using the active profile or provided credentials
-func getClient() (*api.Client, error) {
+// getClient creates an API client using the active profile or provided
+// credentials. The client is bound to the command's context, so Ctrl-C
+// cancels its in-flight requests.
+func getClient(cmd *cobra.Command) (*mochi.Client, error) {
 	// Priority: CLI flag > Environment variable > Config profile
 
 	// 1. Check CLI flag
@@ -21,32 +34,221 @@ func getClient() (*api.Client, error) {
 	}
 
 	// 3. Check profile
+	replaying := os.Getenv("MOCHI_REPLAY") != ""
+	p, err := loadProfile(key == "" && !replaying)
+	if err != nil {
+		return nil, err
+	}
 	if key == "" {
-		cfg, err := config.GetConfig()
-		if err != nil {
-			return nil, fmt.Errorf("failed to load config: %w", err)
-		}
+		key = p.APIKey
+	}
+
+	// Replayed sessions never reach the API, so they need no real key
+	if key == "" && replaying {
+		key = "replay"
+	}
 
-		// Use specified profile or active profile
-		profileName := profile
-		if profileName == "" {
-			profileName = cfg.ActiveProfile
+	if key == "" {
+		return nil, fmt.Errorf("%w. Set MOCHI_API_KEY environment variable, use --api-key flag, or run 'mochi config add <name> <api-key>'", config.ErrNoAPIKey)
+	}
+
+	// Base URL follows the same precedence as the API key
+	endpoint := baseURL
+	if endpoint == "" {
+		endpoint = config.GetBaseURL(p)
+	}
+
+	client := mochi.NewClient(key,
+		mochi.WithBaseURL(endpoint),
+		mochi.WithUserAgent("mochi-cli/"+rootCmd.Version),
+	)
+
+	if err := configureRetries(cmd, client, p); err != nil {
+		return nil, err
+	}
+
+	client.SetWarningHandler(func(w models.DecodeWarning) {
+		printStderrWarning("Warning: " + w.String())
+	})
+
+	if verbose {
+		client.SetLogger(func(format string, args ...interface{}) {
+			fmt.Fprintf(os.Stderr, "[mochi] "+format+"\n", args...)
+		})
+	}
+
+	if err := configureCassette(client); err != nil {
+		return nil, err
+	}
+	configureTracing(client)
+
+	return client.WithContext(cmd.Context()), nil
+}
+
+// The cassette transports are shared by every client of the process, so a
+// session is recorded to or replayed from a single cassette
+var (
+	cassetteRecorder *cassette.Recorder
+	cassettePlayer   *cassette.Player
+)
+
+// configureCassette records the session to MOCHI_RECORD or replays it from
+// MOCHI_REPLAY. MOCHI_CASSETTE_MATCH selects the request parts that must
+// match during replay (default "method,path,query,body").
+func configureCassette(client *mochi.Client) error {
+	record := os.Getenv("MOCHI_RECORD")
+	replay := os.Getenv("MOCHI_REPLAY")
+
+	switch {
+	case record != "" && replay != "":
+		return fmt.Errorf("MOCHI_RECORD and MOCHI_REPLAY cannot be used together")
+
+	case record != "":
+		if cassetteRecorder == nil {
+			r, err := cassette.NewRecorder(client.Transport(), record)
+			if err != nil {
+				return err
+			}
+			cassetteRecorder = r
 		}
+		client.SetTransport(cassetteRecorder)
 
-		if profileName != "" {
-			p, err := cfg.GetProfile(profileName)
+	case replay != "":
+		if cassettePlayer == nil {
+			matcher, err := cassette.ParseMatcher(os.Getenv("MOCHI_CASSETTE_MATCH"))
+			if err != nil {
+				return err
+			}
+			c, err := cassette.Load(replay)
 			if err != nil {
-				return nil, err
+				return err
 			}
-			key = p.APIKey
+			cassettePlayer = cassette.NewPlayer(c, matcher)
 		}
+		client.SetTransport(cassettePlayer)
+
+		// Replayed responses are served without waiting, whatever
+		// Retry-After they recorded
+		policy := client.RetryPolicy()
+		policy.BaseDelay = 0
+		policy.MaxDelay = time.Millisecond
+		client.SetRetryPolicy(policy)
+		client.SetRateLimit(0, 0)
 	}
 
-	if key == "" {
-		return nil, fmt.Errorf("no API key found. Set MOCHI_API_KEY environment variable, use --api-key flag, or run 'mochi config add <name> <api-key>'")
+	return nil
+}
+
+// writeCassette saves the session recorded for MOCHI_RECORD, if any
+func writeCassette() {
+	if cassetteRecorder == nil {
+		return
 	}
+	if err := cassetteRecorder.Save(); err != nil {
+		printStderrWarning(fmt.Sprintf("Warning: %s", err))
+	}
+}
+
+// harRecorder captures requests for --har; it is created by the first client
+// and written out when the command finishes
+var harRecorder *trace.Recorder
+
+// configureTracing wraps the client's transport for --trace and --har
+func configureTracing(client *mochi.Client) {
+	rt := client.Transport()
 
-	return api.NewClient(key), nil
+	if harFile != "" {
+		if harRecorder == nil {
+			harRecorder = trace.NewRecorder(rt, "mochi-cli", rootCmd.Version)
+		}
+		rt = harRecorder
+	}
+	if traceHTTP || traceBodies {
+		rt = trace.NewLogger(rt, os.Stderr, traceBodies)
+	}
+
+	client.SetTransport(rt)
+}
+
+// writeHAR saves the session recorded for --har, if any
+func writeHAR() {
+	if harRecorder == nil {
+		return
+	}
+	if err := harRecorder.WriteFile(harFile); err != nil {
+		printStderrWarning(fmt.Sprintf("Warning: failed to write HAR file: %s", err))
+		return
+	}
+	if !quiet {
+		fmt.Fprintf(os.Stderr, "Wrote HAR file %s (%d requests)\n", harFile, harRecorder.Len())
+	}
+}
+
+// configureRetries applies the retry policy and rate limit. Flags take
+// precedence over profile settings, which take precedence over defaults.
+func configureRetries(cmd *cobra.Command, client *mochi.Client, p config.Profile) error {
+	policy := mochi.DefaultRetryPolicy()
+	flags := cmd.Flags()
+
+	if flags.Changed("max-retries") {
+		policy.MaxRetries = maxRetries
+	} else if p.MaxRetries != nil {
+		policy.MaxRetries = *p.MaxRetries
+	}
+	if policy.MaxRetries < 0 {
+		return fmt.Errorf("--max-retries must not be negative")
+	}
+
+	if flags.Changed("retry-max-wait") {
+		policy.MaxDelay = retryMaxWait
+	} else if p.RetryMaxWait != "" {
+		wait, err := time.ParseDuration(p.RetryMaxWait)
+		if err != nil {
+			return fmt.Errorf("invalid retry_max_wait in profile: %w", err)
+		}
+		policy.MaxDelay = wait
+	}
+
+	policy.RetryNonIdempotent = retryWrites
+	client.SetRetryPolicy(policy)
+
+	rate := p.RateLimit
+	if flags.Changed("rate-limit") {
+		rate = rateLimit
+	}
+	client.SetRateLimit(rate, 1)
+
+	return nil
+}
+
+// loadProfile returns the specified or active profile. When required is
+// false, config problems are ignored and an empty profile is returned, since
+// the profile only supplies optional settings.
+func loadProfile(required bool) (config.Profile, error) {
+	cfg, err := config.GetConfig()
+	if err != nil {
+		if !required {
+			return config.Profile{}, nil
+		}
+		return config.Profile{}, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	// Use specified profile or active profile
+	profileName := profile
+	if profileName == "" {
+		profileName = cfg.ActiveProfile
+	}
+
+	if profileName == "" {
+		return config.Profile{}, nil
+	}
+
+	p, err := cfg.GetProfile(profileName)
+	if err != nil && required {
+		return config.Profile{}, err
+	}
+
+	return p, nil
 }
 
 // getActiveProfileName returns the name of the active profile
@@ -63,10 +265,124 @@ func getActiveProfileName() string {
 	return cfg.ActiveProfile
 }
 
+// addListFlags registers the pagination flags shared by list commands
+func addListFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("all", false, "Fetch every page instead of only the first")
+	cmd.Flags().String("bookmark", "", "Resume listing from a bookmark returned by a previous list")
+}
+
+// listIterOptions builds iterator options from the --all, --bookmark and,
+// where present, --limit flags. Without --all a single page is fetched;
+// with --all, an explicit --limit caps the total number of items.
+func listIterOptions(cmd *cobra.Command) mochi.IterOptions {
+	all, _ := cmd.Flags().GetBool("all")
+	bookmark, _ := cmd.Flags().GetString("bookmark")
+
+	limit := 0
+	if cmd.Flags().Lookup("limit") != nil {
+		limit, _ = cmd.Flags().GetInt("limit")
+	}
+
+	opts := mochi.IterOptions{Bookmark: bookmark}
+	if all {
+		opts.PageSize = 100
+		if cmd.Flags().Changed("limit") {
+			opts.MaxItems = limit
+		}
+	} else {
+		opts.MaxPages = 1
+		opts.PageSize = limit
+	}
+
+	return opts
+}
+
+// printPatch describes the fields a dry-run update would send
+func printPatch(kind, id string, payload map[string]interface{}) {
+	printInfo(fmt.Sprintf("Dry run - would update %s:", kind))
+	printInfo(fmt.Sprintf("  ID: %s", id))
+
+	keys := make([]string, 0, len(payload))
+	for k := range payload {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		switch v := payload[k].(type) {
+		case nil:
+			printInfo(fmt.Sprintf("  %s: (cleared)", k))
+		case string:
+			printInfo(fmt.Sprintf("  %s: %q", k, truncateString(v, 50)))
+		default:
+			printInfo(fmt.Sprintf("  %s: %v", k, v))
+		}
+	}
+}
+
+// warningMessages formats decode warnings for JSON output
+func warningMessages(warnings []models.DecodeWarning) []string {
+	msgs := make([]string, len(warnings))
+	for i, w := range warnings {
+		msgs[i] = w.String()
+	}
+	return msgs
+}
+
+// cardText returns the text a card shows: its content, or for cards using
+// a template without content, their field values ordered by field ID
+func cardText(card *models.Card) string {
+	if card.Content != "" || len(card.Fields) == 0 {
+		return card.Content
+	}
+	ids := make([]string, 0, len(card.Fields))
+	for id := range card.Fields {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	values := make([]string, len(ids))
+	for i, id := range ids {
+		values[i] = card.Fields[id].Value
+	}
+	return strings.Join(values, "\n")
+}
+
+// isInterrupted reports whether err was caused by the user pressing Ctrl-C
+func isInterrupted(err error) bool {
+	return errors.Is(err, context.Canceled)
+}
+
+// confirm asks a yes/no question on stdin. It returns false if the answer is
+// not yes or the command is interrupted while waiting.
+func confirm(cmd *cobra.Command, prompt string) bool {
+	return confirmTo(cmd, os.Stdout, prompt)
+}
+
+// confirmTo is like confirm, writing the question to out, e.g. stderr when
+// stdout carries JSON
+func confirmTo(cmd *cobra.Command, out io.Writer, prompt string) bool {
+	fmt.Fprintf(out, "%s [y/N]: ", prompt)
+
+	answer := make(chan string, 1)
+	go func() {
+		reader := bufio.NewReader(os.Stdin)
+		response, _ := reader.ReadString('\n')
+		answer <- strings.TrimSpace(strings.ToLower(response))
+	}()
+
+	select {
+	case response := <-answer:
+		return response == "y" || response == "yes"
+	case <-cmd.Context().Done():
+		fmt.Fprintln(out)
+		return false
+	}
+}
+
 // exitWithError exits with an error code
 func exitWithError(code int, msg string) {
 	if jsonErrors {
-		fmt.Fprintf(os.Stderr, `{"error": "%s", "code": %d}`, msg, code)
+		printErrorJSON(map[string]interface{}{"error": msg, "code": code})
 	} else if !quiet {
 		fmt.Fprintf(os.Stderr, "Error: %s\n", msg)
 	}
@@ -4,7 +4,7 @@ import (
 	"fmt"
 	"os"
 
-	importexport "github.com/nerveband/mochi-cli/internal/importexport"
+	importexport "github.com/nerveband/mochi-cli/pkg/mochi/importexport"
 	"github.com/spf13/cobra"
 )
 
@@ -30,7 +30,7 @@ var exportCmd = &cobra.Command{
 			return fmt.Errorf("output file is required (use --output)")
 		}
 
-		client, err := getClient()
+		client, err := getClient(cmd)
 		if err != nil {
 			return err
 		}
@@ -57,9 +57,26 @@ var exportCmd = &cobra.Command{
 			data, err = exporter.ExportAllDecks(opts)
 		}
 
-		if err != nil {
+		if err != nil && !(isInterrupted(err) && data != nil) {
 			return fmt.Errorf("failed to export: %w", err)
 		}
+		if err != nil {
+			// Keep what was fetched before Ctrl-C rather than discarding it
+			interrupted := err
+			cardCount := 0
+			for _, deck := range data.Decks {
+				cardCount += len(deck.Cards)
+			}
+			if dryRun {
+				printWarning(fmt.Sprintf("Interrupted after fetching %d decks and %d cards", len(data.Decks), cardCount))
+				return interrupted
+			}
+			if err := exporter.ExportToFile(data, output, opts); err != nil {
+				return fmt.Errorf("failed to write partial export: %w", err)
+			}
+			printWarning(fmt.Sprintf("Interrupted: wrote partial export of %d decks and %d cards to %s", len(data.Decks), cardCount, output))
+			return interrupted
+		}
 
 		if dryRun {
 			printInfo("Dry run - would export:")
@@ -120,7 +137,7 @@ var importCmd = &cobra.Command{
 			return err
 		}
 
-		client, err := getClient()
+		client, err := getClient(cmd)
 		if err != nil {
 			return err
 		}
@@ -142,6 +159,11 @@ var importCmd = &cobra.Command{
 		}
 
 		result, err := importer.ImportFromFile(filepath, opts)
+		if err != nil && isInterrupted(err) && result != nil {
+			printWarning(fmt.Sprintf("Interrupted after importing %d decks, %d cards, %d templates",
+				result.DecksCreated, result.CardsCreated, result.TemplatesCreated))
+			return err
+		}
 		if err != nil {
 			return fmt.Errorf("import failed: %w", err)
 		}
@@ -0,0 +1,394 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/nerveband/mochi-cli/internal/config"
+	"github.com/nerveband/mochi-cli/internal/index"
+	"github.com/nerveband/mochi-cli/internal/query"
+	"github.com/nerveband/mochi-cli/pkg/mochi"
+	"github.com/nerveband/mochi-cli/pkg/mochi/models"
+	"github.com/spf13/cobra"
+)
+
+// indexStaleAfter is how old an index gets before searches warn about it
+const indexStaleAfter = 24 * time.Hour
+
+// indexCmd represents the index command
+var indexCmd = &cobra.Command{
+	Use:   "index",
+	Short: "Manage the local search index",
+	Long: `Manage the local full-text index used by card search --index.
+
+Each profile and API key has its own index, stored next to the
+configuration file. It holds a copy of every card, so searches rank results
+in milliseconds without downloading the collection. Refreshing re-indexes only the cards whose
+updated-at changed and drops cards that were deleted.`,
+}
+
+// indexRebuildCmd builds or refreshes the search index
+var indexRebuildCmd = &cobra.Command{
+	Use:   "rebuild",
+	Short: "Build or refresh the search index",
+	Long: `Build the search index of the active profile, or bring an existing one up to
+date. Only cards changed since the last refresh are re-indexed, unless
+--full starts over.
+
+If interrupted, the cards indexed so far are kept and the next rebuild
+continues from there.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		full, _ := cmd.Flags().GetBool("full")
+
+		client, err := getClient(cmd)
+		if err != nil {
+			return err
+		}
+
+		path := indexPath()
+		var ix *index.Index
+		if !full {
+			ix, err = index.Load(path)
+			switch {
+			case errors.Is(err, index.ErrNotFound):
+			case err != nil:
+				printStderrWarning(fmt.Sprintf("Warning: %v; starting over", err))
+			case !ix.Belongs(client.BaseURL(), indexKeyFingerprint()):
+				printStderrWarning(fmt.Sprintf("Warning: index was built from %s with another API key; starting over", ix.BaseURL))
+				ix = nil
+			}
+		}
+		if ix == nil {
+			ix = index.New(client.BaseURL(), indexKeyFingerprint())
+		}
+
+		if dryRun {
+			printInfo(fmt.Sprintf("Dry run - would index the cards of %s into %s", client.BaseURL(), path))
+			return nil
+		}
+
+		stats, err := refreshIndex(client, ix)
+		if err != nil && !isInterrupted(err) {
+			return err
+		}
+		if saveErr := ix.Save(path); saveErr != nil {
+			return fmt.Errorf("failed to save search index: %w", saveErr)
+		}
+		if err != nil {
+			printStderrWarning(fmt.Sprintf("Rebuild interrupted; saved %d indexed cards", len(ix.Docs)))
+			return err
+		}
+
+		switch format {
+		case "json", "compact":
+			result := map[string]interface{}{
+				"path":      path,
+				"cards":     len(ix.Docs),
+				"added":     stats.Added,
+				"updated":   stats.Updated,
+				"removed":   stats.Removed,
+				"unchanged": stats.Unchanged,
+			}
+			if format == "compact" {
+				printCompactJSON(result)
+			} else {
+				printJSON(result)
+			}
+		default:
+			printSuccess(fmt.Sprintf("Indexed %d cards (%d added, %d updated, %d removed, %d unchanged)",
+				len(ix.Docs), stats.Added, stats.Updated, stats.Removed, stats.Unchanged))
+		}
+		return nil
+	},
+}
+
+// indexStatusCmd describes the search index
+var indexStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the state of the search index",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := indexPath()
+		ix, err := index.Load(path)
+		if err != nil && !errors.Is(err, index.ErrNotFound) {
+			return err
+		}
+
+		status := map[string]interface{}{
+			"profile": indexProfileName(),
+			"path":    path,
+			"exists":  ix != nil,
+		}
+		if ix != nil {
+			status["cards"] = len(ix.Docs)
+			status["terms"] = len(ix.Postings)
+			status["base-url"] = ix.BaseURL
+			status["created-at"] = ix.CreatedAt
+			status["refreshed-at"] = ix.RefreshedAt
+			if info, err := os.Stat(path); err == nil {
+				status["size"] = info.Size()
+			}
+		}
+
+		switch format {
+		case "json":
+			printJSON(status)
+		case "compact":
+			printCompactJSON(status)
+		default:
+			if ix == nil {
+				printInfo(fmt.Sprintf("No search index for profile %q (run mochi index rebuild)", indexProfileName()))
+				return nil
+			}
+			fmt.Printf("Profile:   %s\n", indexProfileName())
+			fmt.Printf("Path:      %s\n", path)
+			fmt.Printf("API:       %s\n", ix.BaseURL)
+			fmt.Printf("Cards:     %d\n", len(ix.Docs))
+			fmt.Printf("Terms:     %d\n", len(ix.Postings))
+			if size, ok := status["size"].(int64); ok {
+				fmt.Printf("Size:      %s\n", formatSize(size))
+			}
+			fmt.Printf("Created:   %s\n", ix.CreatedAt.Format("2006-01-02 15:04"))
+			fmt.Printf("Refreshed: %s\n", ix.RefreshedAt.Format("2006-01-02 15:04"))
+		}
+		return nil
+	},
+}
+
+// indexClearCmd deletes the search index
+var indexClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Delete the search index",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := indexPath()
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			printInfo("No search index to clear")
+			return nil
+		}
+
+		if dryRun {
+			printInfo(fmt.Sprintf("Dry run - would delete %s", path))
+			return nil
+		}
+
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("failed to delete search index: %w", err)
+		}
+		printSuccess(fmt.Sprintf("Deleted search index %s", path))
+		return nil
+	},
+}
+
+// indexStats counts what a refresh did to the index
+type indexStats struct {
+	Added, Updated, Removed, Unchanged int
+}
+
+// refreshIndex brings an index up to date with the API: cards whose
+// updated-at changed are re-indexed, and cards that no longer exist are
+// removed once every card has been listed. On error the index keeps the
+// cards seen so far.
+func refreshIndex(client *mochi.Client, ix *index.Index) (indexStats, error) {
+	var stats indexStats
+
+	paths, err := deckPaths(client)
+	if err != nil {
+		return stats, fmt.Errorf("failed to list decks: %w", err)
+	}
+	names, err := templateNames(client)
+	if err != nil {
+		return stats, fmt.Errorf("failed to list templates: %w", err)
+	}
+	ix.DeckPaths, ix.TemplateNames = paths, names
+
+	progress := !quiet && stderrIsTerminal()
+	seen := map[string]bool{}
+	it := client.Cards("", mochi.IterOptions{PageSize: 100})
+	for it.Next() {
+		card := it.Item()
+		seen[card.ID] = true
+		switch _, known := ix.Docs[card.ID]; {
+		case ix.Unchanged(&card):
+			stats.Unchanged++
+		case known:
+			ix.Put(card)
+			stats.Updated++
+		default:
+			ix.Put(card)
+			stats.Added++
+		}
+		if progress && len(seen)%100 == 0 {
+			fmt.Fprintf(os.Stderr, "\r\x1b[KIndexing cards: %d", len(seen))
+		}
+	}
+	if progress && len(seen) >= 100 {
+		fmt.Fprint(os.Stderr, "\r\x1b[K")
+	}
+	if err := it.Err(); err != nil {
+		return stats, err
+	}
+
+	for _, id := range ix.IDs() {
+		if !seen[id] {
+			ix.Remove(id)
+			stats.Removed++
+		}
+	}
+	ix.RefreshedAt = time.Now()
+	return stats, nil
+}
+
+// searchIndex runs card search --index: the query and selection filter the
+// indexed cards, and its words rank them
+func searchIndex(cmd *cobra.Command, q *query.Query, sel *cardSelection, deckID string, limit int) error {
+	path := indexPath()
+	ix, err := index.Load(path)
+	if errors.Is(err, index.ErrNotFound) {
+		return fmt.Errorf("no search index for profile %q; build it with 'mochi index rebuild'", indexProfileName())
+	}
+	if err != nil {
+		return err
+	}
+
+	if refresh, _ := cmd.Flags().GetBool("refresh"); refresh {
+		client, err := getClient(cmd)
+		if err != nil {
+			return err
+		}
+		if !ix.Belongs(client.BaseURL(), indexKeyFingerprint()) {
+			return fmt.Errorf("search index was built from %s with another API key; rebuild it with 'mochi index rebuild'", ix.BaseURL)
+		}
+		if _, err := refreshIndex(client, ix); err != nil {
+			return fmt.Errorf("failed to refresh search index: %w", err)
+		}
+		if err := ix.Save(path); err != nil {
+			return fmt.Errorf("failed to save search index: %w", err)
+		}
+	} else if time.Since(ix.RefreshedAt) > indexStaleAfter {
+		printStderrWarning(fmt.Sprintf("Warning: search index was last refreshed %s; use --refresh or mochi index rebuild",
+			ix.RefreshedAt.Format("2006-01-02 15:04")))
+	}
+
+	env := query.Env{
+		DeckPath:     func(id string) string { return ix.DeckPaths[id] },
+		TemplateName: func(id string) string { return ix.TemplateNames[id] },
+		Word:         ix.ContainsWord,
+	}
+	queryMatch := q.Matcher(env)
+	match := sel.matcherEnv(env)
+
+	terms := index.Terms(strings.Join(q.Text(), " "))
+	results := ix.Search(terms, func(card *models.Card) bool {
+		return (deckID == "" || card.DeckID == deckID) && queryMatch(card) && match(card)
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+
+	if idOnly || outputOnly == "id" {
+		for _, r := range results {
+			fmt.Println(r.Card.ID)
+		}
+		return nil
+	}
+
+	snippet := func(r index.Result, mark func(string) string) string {
+		text := cardText(r.Card)
+		if text == "" {
+			text = r.Card.Name
+		}
+		return index.Snippet(text, terms, 80, mark)
+	}
+
+	switch format {
+	case "json":
+		cards := make([]models.Card, len(results))
+		ranked := make([]map[string]interface{}, len(results))
+		for i, r := range results {
+			cards[i] = *r.Card
+			ranked[i] = map[string]interface{}{
+				"id":      r.Card.ID,
+				"score":   r.Score,
+				"snippet": snippet(r, func(w string) string { return "**" + w + "**" }),
+			}
+		}
+		printJSON(map[string]interface{}{
+			"cards":      cards,
+			"count":      len(results),
+			"results":    ranked,
+			"indexed-at": ix.RefreshedAt,
+		})
+	case "compact":
+		cards := make([]models.Card, len(results))
+		for i, r := range results {
+			cards[i] = *r.Card
+		}
+		printCompactJSON(cards)
+	case "table":
+		headers := []string{"ID", "NAME", "SCORE", "SNIPPET"}
+		rows := make([][]string, len(results))
+		for i, r := range results {
+			rows[i] = []string{
+				r.Card.ID,
+				r.Card.Name,
+				fmt.Sprintf("%.2f", r.Score),
+				snippet(r, func(w string) string { return w }),
+			}
+		}
+		printTable(headers, rows)
+	default:
+		bold := color.New(color.Bold).Sprint
+		for _, r := range results {
+			fmt.Printf("%s: %s\n", r.Card.ID, snippet(r, func(w string) string { return bold(w) }))
+		}
+	}
+	return nil
+}
+
+// indexProfileName returns the name of the profile whose index is used
+func indexProfileName() string {
+	if name := getActiveProfileName(); name != "" {
+		return name
+	}
+	return "default"
+}
+
+// unsafePathChars matches what may not appear in an index file name
+var unsafePathChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// indexKeyFingerprint identifies the API key in use, which may come from
+// --api-key or MOCHI_API_KEY rather than the profile
+func indexKeyFingerprint() string {
+	key := apiKey
+	if key == "" {
+		key = os.Getenv("MOCHI_API_KEY")
+	}
+	if key == "" {
+		p, _ := loadProfile(false)
+		key = p.APIKey
+	}
+	return index.Fingerprint(key)
+}
+
+// indexPath returns where the search index of the active profile and API
+// key is stored. Each key gets its own file, so accounts used through the
+// same profile never share an index.
+func indexPath() string {
+	name := unsafePathChars.ReplaceAllString(indexProfileName(), "_")
+	return filepath.Join(config.Dir(), "index", name+"-"+indexKeyFingerprint()+".gob")
+}
+
+func init() {
+	rootCmd.AddCommand(indexCmd)
+	indexCmd.AddCommand(indexRebuildCmd)
+	indexCmd.AddCommand(indexStatusCmd)
+	indexCmd.AddCommand(indexClearCmd)
+
+	// Index rebuild flags
+	indexRebuildCmd.Flags().Bool("full", false, "Discard the existing index and index every card again")
+}
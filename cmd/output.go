@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -9,15 +10,15 @@ import (
 	"strings"
 
 	"github.com/fatih/color"
-	"github.com/nerveband/mochi-cli/internal/models"
+	"github.com/nerveband/mochi-cli/pkg/mochi/models"
 )
 
 // printJSON prints data as formatted JSON
 func printJSON(data interface{}) {
-	output, err := json.MarshalIndent(data, "", "  ")
+	output, err := marshalOutput(data, "  ")
 	if err != nil {
 		if jsonErrors {
-			fmt.Fprintf(os.Stderr, `{"error": "failed to marshal JSON: %s"}`, err.Error())
+			printErrorJSON(map[string]string{"error": "failed to marshal JSON: " + err.Error()})
 		} else {
 			fmt.Fprintf(os.Stderr, "Error: failed to marshal JSON: %s\n", err.Error())
 		}
@@ -28,10 +29,10 @@ func printJSON(data interface{}) {
 
 // printCompactJSON prints data as compact JSON (single line)
 func printCompactJSON(data interface{}) {
-	output, err := json.Marshal(data)
+	output, err := marshalOutput(data, "")
 	if err != nil {
 		if jsonErrors {
-			fmt.Fprintf(os.Stderr, `{"error": "failed to marshal JSON: %s"}`, err.Error())
+			printErrorJSON(map[string]string{"error": "failed to marshal JSON: " + err.Error()})
 		} else {
 			fmt.Fprintf(os.Stderr, "Error: failed to marshal JSON: %s\n", err.Error())
 		}
@@ -40,6 +41,19 @@ func printCompactJSON(data interface{}) {
 	fmt.Println(string(output))
 }
 
+// marshalOutput encodes data as JSON without escaping <, > and &, which
+// appear in card content and template placeholders
+func marshalOutput(data interface{}, indent string) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", indent)
+	if err := enc.Encode(data); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
 // extractField extracts a specific field from data using reflection
 func extractField(data interface{}, field string) interface{} {
 	if data == nil {
@@ -48,6 +62,14 @@ func extractField(data interface{}, field string) interface{} {
 
 	v := reflect.ValueOf(data)
 
+	// Look through pointers, such as the *models.Card the API returns
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
 	// Handle slice
 	if v.Kind() == reflect.Slice {
 		var result []interface{}
@@ -190,10 +212,18 @@ func printWarning(msg string) {
 	}
 }
 
+// printStderrWarning prints a warning to stderr (unless quiet), keeping
+// stdout clean for data
+func printStderrWarning(msg string) {
+	if !quiet {
+		color.New(color.FgYellow).Fprintln(os.Stderr, msg)
+	}
+}
+
 // printError prints an error message
 func printError(msg string) {
 	if jsonErrors {
-		fmt.Fprintf(os.Stderr, `{"error": "%s"}`, msg)
+		printErrorJSON(map[string]string{"error": msg})
 	} else {
 		color.Red(msg)
 	}
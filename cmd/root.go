@@ -1,8 +1,10 @@
 package cmd
 
 import (
-	"fmt"
+	"context"
 	"os"
+	"os/signal"
+	"time"
 
 	"github.com/spf13/cobra"
 )
@@ -16,8 +18,21 @@ var (
 	idOnly     bool
 	noHeaders  bool
 	apiKey     string
+	baseURL    string
 	profile    string
 	dryRun     bool
+	verbose    bool
+
+	// Retry and rate limit flags
+	maxRetries   int
+	retryMaxWait time.Duration
+	retryWrites  bool
+	rateLimit    float64
+
+	// Debugging flags
+	traceHTTP   bool
+	traceBodies bool
+	harFile     string
 )
 
 // rootCmd represents the base command
@@ -46,17 +61,24 @@ Get started:
 	},
 }
 
-// Execute runs the root command
+// Execute runs the root command. The first Ctrl-C cancels the command's
+// context so in-flight API requests abort cleanly; a second one exits
+// immediately.
 func Execute(version string) {
 	rootCmd.Version = version
 
-	if err := rootCmd.Execute(); err != nil {
-		if jsonErrors {
-			fmt.Fprintf(os.Stderr, `{"error": "%s"}\n`, err.Error())
-		} else if !quiet {
-			fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
-		}
-		os.Exit(1)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	err := rootCmd.ExecuteContext(ctx)
+	writeCassette()
+	writeHAR()
+	if err != nil {
+		os.Exit(reportError(err))
 	}
 }
 
@@ -69,6 +91,19 @@ func init() {
 	rootCmd.PersistentFlags().BoolVar(&idOnly, "id-only", false, "Output only IDs (shorthand for --output-only=id)")
 	rootCmd.PersistentFlags().BoolVar(&noHeaders, "no-headers", false, "Suppress table headers")
 	rootCmd.PersistentFlags().StringVarP(&apiKey, "api-key", "k", "", "API key (overrides profile and env var)")
+	rootCmd.PersistentFlags().StringVar(&baseURL, "base-url", "", "API base URL (overrides profile and MOCHI_BASE_URL)")
 	rootCmd.PersistentFlags().StringVarP(&profile, "profile", "p", "", "Profile to use (overrides active profile)")
 	rootCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "Preview changes without executing")
+	rootCmd.PersistentFlags().BoolVar(&verbose, "verbose", false, "Log retries and rate limiting to stderr")
+
+	// Retry and rate limit flags
+	rootCmd.PersistentFlags().IntVar(&maxRetries, "max-retries", 3, "Retries for rate-limited or failed requests (0 disables)")
+	rootCmd.PersistentFlags().DurationVar(&retryMaxWait, "retry-max-wait", 30*time.Second, "Longest wait between retries, including Retry-After")
+	rootCmd.PersistentFlags().BoolVar(&retryWrites, "retry-writes", false, "Also retry POST requests on server errors (may create duplicates)")
+	rootCmd.PersistentFlags().Float64Var(&rateLimit, "rate-limit", 0, "Maximum requests per second (0 for unlimited)")
+
+	// Debugging flags
+	rootCmd.PersistentFlags().BoolVar(&traceHTTP, "trace", false, "Log each HTTP request with status and latency to stderr")
+	rootCmd.PersistentFlags().BoolVar(&traceBodies, "trace-bodies", false, "Like --trace, also logging redacted request and response bodies")
+	rootCmd.PersistentFlags().StringVar(&harFile, "har", "", "Record the session's HTTP traffic to a HAR 1.2 file (credentials scrubbed)")
 }
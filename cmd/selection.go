@@ -0,0 +1,203 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nerveband/mochi-cli/internal/query"
+	"github.com/nerveband/mochi-cli/pkg/mochi"
+	"github.com/nerveband/mochi-cli/pkg/mochi/models"
+	"github.com/spf13/cobra"
+)
+
+// addCardFilterFlags registers the flags that select cards by their
+// properties or a --where query; read them back with parseCardSelection
+func addCardFilterFlags(cmd *cobra.Command) {
+	cmd.Flags().StringSlice("tag", nil, "Only cards with this manual tag (repeatable; all must match)")
+	cmd.Flags().Bool("archived", false, "Only archived cards")
+	cmd.Flags().Bool("no-archived", false, "Only cards that aren't archived")
+	cmd.Flags().Bool("trashed", false, "Only trashed cards (--trashed=false for cards not in the trash)")
+	cmd.Flags().Bool("new", false, "Only new cards (--new=false for cards that aren't new)")
+	cmd.Flags().String("template", "", "Only cards using this template ID")
+	cmd.Flags().String("created-after", "", "Only cards created after this date (YYYY-MM-DD or RFC 3339)")
+	cmd.Flags().String("created-before", "", "Only cards created before this date")
+	cmd.Flags().String("updated-after", "", "Only cards updated after this date")
+	cmd.Flags().String("updated-before", "", "Only cards updated before this date")
+	cmd.Flags().Bool("has-reviews", false, "Only reviewed cards (--has-reviews=false for never reviewed)")
+	cmd.Flags().Int("min-lapses", 0, "Only cards forgotten at least this many times")
+	cmd.Flags().String("where", "", `Only cards matching a query, e.g. 'tag:verbs -archived' (see "mochi card search --help")`)
+	cmd.MarkFlagsMutuallyExclusive("archived", "no-archived")
+}
+
+// cardFilter builds the card filter given by the flags of
+// addCardFilterFlags. Boolean conditions only apply when the flag is given.
+func cardFilter(cmd *cobra.Command) (mochi.CardFilter, error) {
+	flags := cmd.Flags()
+	var filter mochi.CardFilter
+
+	filter.Tags, _ = flags.GetStringSlice("tag")
+	filter.TemplateID, _ = flags.GetString("template")
+	filter.MinLapses, _ = flags.GetInt("min-lapses")
+	if filter.MinLapses < 0 {
+		return filter, fmt.Errorf("--min-lapses must not be negative")
+	}
+
+	boolFlag := func(name string) *bool {
+		if !flags.Changed(name) {
+			return nil
+		}
+		value, _ := flags.GetBool(name)
+		return &value
+	}
+	filter.Archived = boolFlag("archived")
+	if noArchived := boolFlag("no-archived"); noArchived != nil {
+		filter.Archived = mochi.Bool(!*noArchived)
+	}
+	filter.Trashed = boolFlag("trashed")
+	filter.New = boolFlag("new")
+	filter.HasReviews = boolFlag("has-reviews")
+
+	bounds := []struct {
+		flag string
+		t    *time.Time
+	}{
+		{"created-after", &filter.CreatedAfter},
+		{"created-before", &filter.CreatedBefore},
+		{"updated-after", &filter.UpdatedAfter},
+		{"updated-before", &filter.UpdatedBefore},
+	}
+	for _, b := range bounds {
+		value, _ := flags.GetString(b.flag)
+		if value == "" {
+			continue
+		}
+		t, err := mochi.ParseFilterTime(value)
+		if err != nil {
+			return filter, fmt.Errorf("--%s: %w", b.flag, err)
+		}
+		*b.t = t
+	}
+
+	return filter, nil
+}
+
+// cardSelection is the set of cards chosen by the flags of
+// addCardFilterFlags
+type cardSelection struct {
+	filter mochi.CardFilter
+	where  *query.Query
+}
+
+// parseCardSelection reads and checks the flags of addCardFilterFlags
+func parseCardSelection(cmd *cobra.Command) (*cardSelection, error) {
+	filter, err := cardFilter(cmd)
+	if err != nil {
+		return nil, err
+	}
+	sel := &cardSelection{filter: filter}
+
+	if where, _ := cmd.Flags().GetString("where"); where != "" {
+		sel.where, err = query.Parse(where)
+		if err != nil {
+			return nil, fmt.Errorf("--where: %w", err)
+		}
+	}
+	return sel, nil
+}
+
+// IsZero reports whether every card is selected
+func (s *cardSelection) IsZero() bool {
+	return s.filter.IsZero() && s.where == nil
+}
+
+// matcher returns a predicate for the selected cards, looking up the decks
+// and templates the --where query refers to
+func (s *cardSelection) matcher(client *mochi.Client) (func(card *models.Card) bool, error) {
+	env, err := queryEnv(client, s.where)
+	if err != nil {
+		return nil, err
+	}
+	return s.matcherEnv(env), nil
+}
+
+// matcherEnv returns a predicate for the selected cards, resolving the
+// --where query with env
+func (s *cardSelection) matcherEnv(env query.Env) func(card *models.Card) bool {
+	match := s.filter.Match
+	if s.where == nil {
+		return match
+	}
+	whereMatch := s.where.Matcher(env)
+	return func(card *models.Card) bool {
+		return match(card) && whereMatch(card)
+	}
+}
+
+// queryEnv fetches the deck paths and template names the queries refer to.
+// Nil queries are ignored.
+func queryEnv(client *mochi.Client, queries ...*query.Query) (query.Env, error) {
+	var env query.Env
+	uses := func(field string) bool {
+		for _, q := range queries {
+			if q != nil && q.Uses(field) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if uses("deck") {
+		paths, err := deckPaths(client)
+		if err != nil {
+			return env, fmt.Errorf("failed to list decks: %w", err)
+		}
+		env.DeckPath = func(id string) string { return paths[id] }
+	}
+	if uses("template") {
+		names, err := templateNames(client)
+		if err != nil {
+			return env, fmt.Errorf("failed to list templates: %w", err)
+		}
+		env.TemplateName = func(id string) string { return names[id] }
+	}
+
+	return env, nil
+}
+
+// templateNames returns the name of every template by ID
+func templateNames(client *mochi.Client) (map[string]string, error) {
+	names := map[string]string{}
+	it := client.Templates(mochi.IterOptions{})
+	for it.Next() {
+		tmpl := it.Item()
+		names[tmpl.ID] = tmpl.Name
+	}
+	return names, it.Err()
+}
+
+// deckPaths returns the path of every deck by ID: its name prefixed with
+// the names of its parents, e.g. "Spanish/Verbs"
+func deckPaths(client *mochi.Client) (map[string]string, error) {
+	decks := map[string]models.Deck{}
+	it := client.Decks(mochi.IterOptions{})
+	for it.Next() {
+		deck := it.Item()
+		decks[deck.ID] = deck
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+
+	paths := make(map[string]string, len(decks))
+	for id := range decks {
+		var names []string
+		seen := map[string]bool{}
+		for cur, ok := decks[id]; ok && !seen[cur.ID]; cur, ok = decks[cur.ParentID] {
+			seen[cur.ID] = true
+			names = append([]string{cur.Name}, names...)
+		}
+		paths[id] = strings.Join(names, "/")
+	}
+	return paths, nil
+}
@@ -1,10 +1,13 @@
 package cmd
 
 import (
-	"encoding/json"
 	"fmt"
+	"sort"
 
-	"github.com/nerveband/mochi-cli/internal/models"
+	"github.com/fatih/color"
+	"github.com/nerveband/mochi-cli/internal/templating"
+	"github.com/nerveband/mochi-cli/pkg/mochi"
+	"github.com/nerveband/mochi-cli/pkg/mochi/models"
 	"github.com/spf13/cobra"
 )
 
@@ -12,44 +15,47 @@ import (
 var templateCmd = &cobra.Command{
 	Use:   "template",
 	Short: "Manage templates",
-	Long:  `List and get card templates.`,
+	Long: `List, get, create, update, delete and clone card templates.
+
+Template definitions can be kept in YAML or JSON files, for example:
+
+  name: Vocabulary
+  content: "<< Word >>\n---\n<< Meaning >>"
+  fields:
+    word:
+      name: Word
+      pos: a
+    meaning:
+      name: Meaning
+      type: text
+      pos: b`,
 }
 
 // templateListCmd lists templates
 var templateListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List all templates",
+	Long: `List card templates.
+
+Only the first page is shown unless --all is given. JSON output includes a
+bookmark that can be passed to --bookmark to fetch the next page.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		client, err := getClient()
+		client, err := getClient(cmd)
 		if err != nil {
 			return err
 		}
 
-		resp, err := client.ListTemplates("")
-		if err != nil {
+		it := client.Templates(listIterOptions(cmd))
+		templates, err := mochi.Collect(it)
+		if err != nil && !isInterrupted(err) {
 			return err
 		}
 
-		// Convert docs to templates
-		docs, ok := resp.Docs.([]interface{})
-		if !ok {
-			return fmt.Errorf("unexpected response format")
-		}
-
-		var templates []models.Template
-		for _, doc := range docs {
-			data, _ := json.Marshal(doc)
-			var template models.Template
-			if err := json.Unmarshal(data, &template); err == nil {
-				templates = append(templates, template)
-			}
-		}
-
 		if idOnly || outputOnly == "id" {
 			for _, template := range templates {
 				fmt.Println(template.ID)
 			}
-			return nil
+			return err
 		}
 
 		if outputOnly != "" {
@@ -59,15 +65,19 @@ var templateListCmd = &cobra.Command{
 					fmt.Println(val)
 				}
 			}
-			return nil
+			return err
 		}
 
 		switch format {
 		case "json":
-			printJSON(map[string]interface{}{
+			result := map[string]interface{}{
 				"templates": templates,
-				"bookmark":  resp.Bookmark,
-			})
+				"bookmark":  it.Bookmark(),
+			}
+			if warnings := it.Warnings(); len(warnings) > 0 {
+				result["warnings"] = warningMessages(warnings)
+			}
+			printJSON(result)
 		case "compact":
 			printCompactJSON(templates)
 		case "table":
@@ -87,7 +97,7 @@ var templateListCmd = &cobra.Command{
 			}
 		}
 
-		return nil
+		return err
 	},
 }
 
@@ -99,7 +109,7 @@ var templateGetCmd = &cobra.Command{
 	RunE: func(cmd *cobra.Command, args []string) error {
 		templateID := args[0]
 
-		client, err := getClient()
+		client, err := getClient(cmd)
 		if err != nil {
 			return err
 		}
@@ -147,8 +157,647 @@ var templateGetCmd = &cobra.Command{
 	},
 }
 
+// templateCreateCmd creates a template from a definition file
+var templateCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create a template from a YAML or JSON file",
+	Long: `Create a template from a YAML or JSON definition file (use - for stdin).
+The definition is validated before anything is sent.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		file, _ := cmd.Flags().GetString("file")
+		name, _ := cmd.Flags().GetString("name")
+
+		if file == "" {
+			return fmt.Errorf("template file is required (use --file)")
+		}
+
+		tmpl, err := templating.LoadFile(file)
+		if err != nil {
+			return err
+		}
+		tmpl.ID = ""
+		if name != "" {
+			tmpl.Name = name
+		}
+		if err := tmpl.Validate(); err != nil {
+			return err
+		}
+
+		if dryRun {
+			printTemplatePlan("create", tmpl)
+			return nil
+		}
+
+		client, err := getClient(cmd)
+		if err != nil {
+			return err
+		}
+
+		created, err := client.CreateTemplate(tmpl)
+		if err != nil {
+			return err
+		}
+
+		printTemplateResult("created", created)
+		return nil
+	},
+}
+
+// templateUpdateCmd replaces a template with a definition file
+var templateUpdateCmd = &cobra.Command{
+	Use:   "update <template-id>",
+	Short: "Update a template from a YAML or JSON file",
+	Long: `Replace a template's name, content, fields and settings with those of a
+YAML or JSON definition file (use - for stdin). With only --name, the template
+is renamed and everything else is kept.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		templateID := args[0]
+		file, _ := cmd.Flags().GetString("file")
+		name, _ := cmd.Flags().GetString("name")
+
+		if file == "" && name == "" {
+			return fmt.Errorf("nothing to update (use --file or --name)")
+		}
+
+		client, err := getClient(cmd)
+		if err != nil {
+			return err
+		}
+
+		var tmpl *models.Template
+		if file != "" {
+			tmpl, err = templating.LoadFile(file)
+		} else {
+			tmpl, err = client.GetTemplate(templateID)
+		}
+		if err != nil {
+			return err
+		}
+		if tmpl.ID != "" && tmpl.ID != templateID {
+			printStderrWarning(fmt.Sprintf("Warning: ignoring id %s from the file, updating %s", tmpl.ID, templateID))
+		}
+		tmpl.ID = templateID
+		if name != "" {
+			tmpl.Name = name
+		}
+		if err := tmpl.Validate(); err != nil {
+			return err
+		}
+
+		if dryRun {
+			printTemplatePlan("update", tmpl)
+			return nil
+		}
+
+		updated, err := client.UpdateTemplate(templateID, tmpl)
+		if err != nil {
+			return err
+		}
+
+		printTemplateResult("updated", updated)
+		return nil
+	},
+}
+
+// templateDeleteCmd deletes a template
+var templateDeleteCmd = &cobra.Command{
+	Use:   "delete <template-id>",
+	Short: "Delete a template",
+	Long:  `Permanently delete a template. This cannot be undone.`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		templateID := args[0]
+		force, _ := cmd.Flags().GetBool("force")
+
+		if !force && !quiet {
+			if !confirm(cmd, fmt.Sprintf("Delete template %s? This cannot be undone.", templateID)) {
+				fmt.Println("Cancelled")
+				return nil
+			}
+		}
+
+		if dryRun {
+			printInfo(fmt.Sprintf("Dry run - would delete template: %s", templateID))
+			return nil
+		}
+
+		client, err := getClient(cmd)
+		if err != nil {
+			return err
+		}
+
+		if err := client.DeleteTemplate(templateID); err != nil {
+			return err
+		}
+
+		printSuccess(fmt.Sprintf("Template deleted: %s", templateID))
+		return nil
+	},
+}
+
+// templateCloneCmd copies a template under a new name
+var templateCloneCmd = &cobra.Command{
+	Use:   "clone <template-id>",
+	Short: "Copy a template under a new name",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		templateID := args[0]
+		name, _ := cmd.Flags().GetString("name")
+
+		if name == "" {
+			return fmt.Errorf("a name for the copy is required (use --name)")
+		}
+
+		client, err := getClient(cmd)
+		if err != nil {
+			return err
+		}
+
+		tmpl, err := client.GetTemplate(templateID)
+		if err != nil {
+			return err
+		}
+		tmpl.ID = ""
+		tmpl.Name = name
+		if err := tmpl.Validate(); err != nil {
+			return err
+		}
+
+		if dryRun {
+			printTemplatePlan("create", tmpl)
+			return nil
+		}
+
+		created, err := client.CreateTemplate(tmpl)
+		if err != nil {
+			return err
+		}
+
+		printTemplateResult("created", created)
+		return nil
+	},
+}
+
+// templateUsageCmd reports which decks use a template
+var templateUsageCmd = &cobra.Command{
+	Use:   "usage <template-id>",
+	Short: "Count the cards using a template, per deck",
+	Long: `Scan cards and count those using a template, grouped by deck. All cards
+are scanned unless --deck limits the scan to one deck.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		templateID := args[0]
+		deckID, _ := cmd.Flags().GetString("deck")
+
+		client, err := getClient(cmd)
+		if err != nil {
+			return err
+		}
+
+		tmpl, err := client.GetTemplate(templateID)
+		if err != nil {
+			return err
+		}
+
+		counts := map[string]int{}
+		total, scanned := 0, 0
+		it := client.Cards(deckID, mochi.IterOptions{PageSize: 100})
+		for it.Next() {
+			scanned++
+			card := it.Item()
+			if card.TemplateID == templateID {
+				counts[card.DeckID]++
+				total++
+			}
+		}
+		if err := it.Err(); err != nil {
+			if !isInterrupted(err) {
+				return err
+			}
+			printStderrWarning(fmt.Sprintf("Interrupted after scanning %d cards; counts are partial", scanned))
+		}
+
+		deckNames := map[string]string{}
+		if len(counts) > 0 {
+			decks, err := mochi.Collect(client.Decks(mochi.IterOptions{}))
+			if err != nil && !isInterrupted(err) {
+				return err
+			}
+			for _, deck := range decks {
+				deckNames[deck.ID] = deck.Name
+			}
+		}
+
+		type deckUsage struct {
+			DeckID   string `json:"deck-id"`
+			DeckName string `json:"deck-name,omitempty"`
+			Cards    int    `json:"cards"`
+		}
+		usage := make([]deckUsage, 0, len(counts))
+		for id, n := range counts {
+			usage = append(usage, deckUsage{DeckID: id, DeckName: deckNames[id], Cards: n})
+		}
+		sort.Slice(usage, func(i, j int) bool {
+			if usage[i].Cards != usage[j].Cards {
+				return usage[i].Cards > usage[j].Cards
+			}
+			return usage[i].DeckID < usage[j].DeckID
+		})
+
+		switch format {
+		case "json":
+			printJSON(map[string]interface{}{
+				"template-id":   tmpl.ID,
+				"template-name": tmpl.Name,
+				"total":         total,
+				"scanned":       scanned,
+				"decks":         usage,
+			})
+		case "compact":
+			printCompactJSON(usage)
+		case "table":
+			rows := make([][]string, len(usage))
+			for i, u := range usage {
+				rows[i] = []string{u.DeckID, u.DeckName, fmt.Sprintf("%d", u.Cards)}
+			}
+			printTable([]string{"DECK ID", "NAME", "CARDS"}, rows)
+		default:
+			fmt.Printf("%s is used by %d of %d cards\n", tmpl.Name, total, scanned)
+			for _, u := range usage {
+				fmt.Printf("  %s (%s): %d\n", u.DeckName, u.DeckID, u.Cards)
+			}
+		}
+
+		return it.Err()
+	},
+}
+
+// templateMigrateCmd moves cards from one template to another
+var templateMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Move cards from one template to another",
+	Long: `Move every card using the --from template to the --to template, rewriting
+its fields. --map lists From=To pairs of field names or IDs; fields with the
+same name in both templates are mapped automatically. Fields of the old
+template without a target are an error unless --drop-unmapped is given.
+The card list filters (--tag, --archived, --created-after and so on) narrow
+the cards migrated.
+
+Use --dry-run to preview the changes. Each card is reported as migrated or
+failed; one failure doesn't stop the others.`,
+	Example: `  mochi template migrate --from OLD_ID --to NEW_ID --map "Front=Question,Back=Answer" --dry-run`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		fromID, _ := cmd.Flags().GetString("from")
+		toID, _ := cmd.Flags().GetString("to")
+		spec, _ := cmd.Flags().GetString("map")
+		deckID, _ := cmd.Flags().GetString("deck")
+		dropUnmapped, _ := cmd.Flags().GetBool("drop-unmapped")
+		force, _ := cmd.Flags().GetBool("force")
+
+		if fromID == "" || toID == "" {
+			return fmt.Errorf("both --from and --to are required")
+		}
+		if fromID == toID {
+			return fmt.Errorf("--from and --to are the same template")
+		}
+		sel, err := parseCardSelection(cmd)
+		if err != nil {
+			return err
+		}
+
+		client, err := getClient(cmd)
+		if err != nil {
+			return err
+		}
+
+		match, err := sel.matcher(client)
+		if err != nil {
+			return err
+		}
+
+		from, err := client.GetTemplate(fromID)
+		if err != nil {
+			return fmt.Errorf("failed to get template %s: %w", fromID, err)
+		}
+		to, err := client.GetTemplate(toID)
+		if err != nil {
+			return fmt.Errorf("failed to get template %s: %w", toID, err)
+		}
+
+		mapping, err := templating.ParseFieldMap(spec, from, to, dropUnmapped)
+		if err != nil {
+			return err
+		}
+		mapped := map[string]bool{}
+		for _, dst := range mapping {
+			mapped[dst] = true
+		}
+		for _, field := range templating.RequiredFields(to) {
+			if !mapped[field.ID] {
+				printStderrWarning(fmt.Sprintf("Warning: no field maps to %q, which will be empty", field.Name))
+			}
+		}
+
+		// Collect the cards first so updates don't disturb pagination
+		var cards []models.Card
+		it := client.Cards(deckID, mochi.IterOptions{PageSize: 100}).Where(match)
+		for it.Next() {
+			if card := it.Item(); card.TemplateID == fromID {
+				cards = append(cards, card)
+			}
+		}
+		if err := it.Err(); err != nil {
+			return err
+		}
+
+		if len(cards) == 0 {
+			printInfo(fmt.Sprintf("No cards use template %s", from.Name))
+			return nil
+		}
+
+		if dryRun {
+			printInfo(fmt.Sprintf("Dry run - would migrate %d cards from %s to %s:", len(cards), from.Name, to.Name))
+			for _, line := range mapping.Describe(from, to) {
+				printInfo("  " + line)
+			}
+		} else if !force && !quiet {
+			if !confirm(cmd, fmt.Sprintf("Migrate %d cards from %s to %s?", len(cards), from.Name, to.Name)) {
+				fmt.Println("Cancelled")
+				return nil
+			}
+		}
+
+		type migrationResult struct {
+			CardID string                  `json:"card-id"`
+			Name   string                  `json:"name,omitempty"`
+			Status string                  `json:"status"`
+			Fields map[string]models.Field `json:"fields,omitempty"`
+			Error  string                  `json:"error,omitempty"`
+		}
+		results := make([]migrationResult, 0, len(cards))
+		migrated, failed := 0, 0
+
+		var runErr error
+		for _, card := range cards {
+			fields := mapping.Apply(card.Fields)
+			result := migrationResult{CardID: card.ID, Name: card.Name, Fields: fields}
+
+			if dryRun {
+				result.Status = "would-migrate"
+				results = append(results, result)
+				continue
+			}
+
+			patch := models.CardPatch{
+				TemplateID: models.Set(toID),
+				Fields:     models.Set(fields),
+			}
+			if _, err := client.UpdateCard(card.ID, patch); err != nil {
+				if isInterrupted(err) {
+					runErr = err
+					break
+				}
+				result.Status = "failed"
+				result.Error = err.Error()
+				failed++
+			} else {
+				result.Status = "migrated"
+				migrated++
+			}
+			results = append(results, result)
+		}
+
+		switch format {
+		case "json":
+			report := map[string]interface{}{
+				"from":     fromID,
+				"to":       toID,
+				"total":    len(cards),
+				"migrated": migrated,
+				"failed":   failed,
+				"dry-run":  dryRun,
+				"results":  results,
+			}
+			if dryRun {
+				report["would-migrate"] = len(results)
+			}
+			printJSON(report)
+		case "compact":
+			printCompactJSON(results)
+		case "table":
+			rows := make([][]string, len(results))
+			for i, r := range results {
+				rows[i] = []string{r.CardID, truncateString(r.Name, 30), r.Status, r.Error}
+			}
+			printTable([]string{"CARD ID", "NAME", "STATUS", "ERROR"}, rows)
+		default:
+			for _, r := range results {
+				if r.Error != "" {
+					fmt.Printf("%s: %s (%s)\n", r.CardID, r.Status, r.Error)
+				} else {
+					fmt.Printf("%s: %s\n", r.CardID, r.Status)
+				}
+			}
+		}
+
+		if runErr != nil {
+			return runErr
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of %d cards failed to migrate", failed, len(cards))
+		}
+		if !dryRun {
+			printSuccess(fmt.Sprintf("Migrated %d cards from %s to %s", migrated, from.Name, to.Name))
+		}
+		return nil
+	},
+}
+
+// templateRenderCmd previews a template filled in with field values
+var templateRenderCmd = &cobra.Command{
+	Use:   "render [template-id]",
+	Short: "Preview a template with field values",
+	Long: `Fill a template's << Field >> placeholders with values from --field flags
+or an existing card (--card), split it into sides on "---" lines and render
+it for the terminal (ansi), as markdown, or as an HTML page.
+
+Placeholders that match no field of the template are reported, and make the
+command fail with --strict. The template ID may be left out with --card, in
+which case the card's template is used.`,
+	Example: `  mochi template render TEMPLATE_ID --field Front=hola --field Back=hello
+  mochi template render --card CARD_ID --to html > preview.html`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cardID, _ := cmd.Flags().GetString("card")
+		fieldFlags, _ := cmd.Flags().GetStringArray("field")
+		to, _ := cmd.Flags().GetString("to")
+		strict, _ := cmd.Flags().GetBool("strict")
+
+		if len(args) == 0 && cardID == "" {
+			return fmt.Errorf("a template ID or --card is required")
+		}
+		if to == "" {
+			to = "ansi"
+			if color.NoColor {
+				to = "markdown"
+			}
+		}
+		if to != "ansi" && to != "markdown" && to != "html" {
+			return fmt.Errorf("invalid --to %q (use ansi, markdown or html)", to)
+		}
+
+		client, err := getClient(cmd)
+		if err != nil {
+			return err
+		}
+
+		fields := map[string]models.Field{}
+		templateID := ""
+		if len(args) == 1 {
+			templateID = args[0]
+		}
+		if cardID != "" {
+			card, err := client.GetCard(cardID)
+			if err != nil {
+				return err
+			}
+			if templateID == "" {
+				if card.TemplateID == "" {
+					return fmt.Errorf("card %s does not use a template", cardID)
+				}
+				templateID = card.TemplateID
+			}
+			for id, field := range card.Fields {
+				fields[id] = field
+			}
+		}
+
+		tmpl, err := client.GetTemplate(templateID)
+		if err != nil {
+			return err
+		}
+
+		values, err := templating.ParseFieldFlags(fieldFlags)
+		if err != nil {
+			return err
+		}
+		if len(values) > 0 {
+			resolved, err := templating.ResolveFields(tmpl, values, true)
+			if err != nil {
+				return err
+			}
+			for id, field := range resolved {
+				fields[id] = field
+			}
+		}
+
+		rendered := templating.Render(tmpl, fields)
+		for _, name := range rendered.Unknown {
+			printStderrWarning(fmt.Sprintf("Warning: placeholder << %s >> matches no field of template %s (fields: %s)",
+				name, tmpl.Name, templating.DescribeFields(tmpl)))
+		}
+		for _, name := range rendered.Missing {
+			printStderrWarning(fmt.Sprintf("Warning: no value for field %q", name))
+		}
+
+		if rootCmd.PersistentFlags().Changed("format") && format == "json" {
+			printJSON(rendered)
+		} else {
+			switch to {
+			case "html":
+				fmt.Print(rendered.HTML(tmpl.Name))
+			case "markdown":
+				fmt.Print(rendered.Markdown())
+			default:
+				fmt.Print(rendered.ANSI())
+			}
+		}
+
+		if strict && len(rendered.Unknown) > 0 {
+			return fmt.Errorf("template %s has %d unknown placeholder(s)", tmpl.Name, len(rendered.Unknown))
+		}
+		return nil
+	},
+}
+
+// printTemplatePlan describes the template a dry run would send
+func printTemplatePlan(action string, tmpl *models.Template) {
+	printInfo(fmt.Sprintf("Dry run - would %s template:", action))
+	if tmpl.ID != "" {
+		printInfo(fmt.Sprintf("  ID: %s", tmpl.ID))
+	}
+	printInfo(fmt.Sprintf("  Name: %s", tmpl.Name))
+	printInfo(fmt.Sprintf("  Content: %s", truncateString(tmpl.Content, 50)))
+	for _, field := range templating.SortedFields(tmpl) {
+		typ := field.Type
+		if typ == "" {
+			typ = "text"
+		}
+		printInfo(fmt.Sprintf("  Field %s: %s (%s)", field.ID, field.Name, typ))
+	}
+}
+
+// printTemplateResult reports a created or updated template
+func printTemplateResult(action string, tmpl *models.Template) {
+	if idOnly || outputOnly == "id" {
+		fmt.Println(tmpl.ID)
+		return
+	}
+
+	if !quiet {
+		printSuccess(fmt.Sprintf("Template %s: %s", action, tmpl.ID))
+	}
+
+	if format == "json" {
+		printJSON(tmpl)
+	}
+}
+
 func init() {
 	rootCmd.AddCommand(templateCmd)
 	templateCmd.AddCommand(templateListCmd)
 	templateCmd.AddCommand(templateGetCmd)
+	templateCmd.AddCommand(templateCreateCmd)
+	templateCmd.AddCommand(templateUpdateCmd)
+	templateCmd.AddCommand(templateDeleteCmd)
+	templateCmd.AddCommand(templateCloneCmd)
+	templateCmd.AddCommand(templateUsageCmd)
+	templateCmd.AddCommand(templateMigrateCmd)
+	templateCmd.AddCommand(templateRenderCmd)
+
+	// List flags
+	addListFlags(templateListCmd)
+
+	// Create flags
+	templateCreateCmd.Flags().StringP("file", "F", "", "Template definition file, YAML or JSON (- for stdin)")
+	templateCreateCmd.Flags().StringP("name", "n", "", "Override the name from the file")
+
+	// Update flags
+	templateUpdateCmd.Flags().StringP("file", "F", "", "Template definition file, YAML or JSON (- for stdin)")
+	templateUpdateCmd.Flags().StringP("name", "n", "", "New name")
+
+	// Delete flags
+	templateDeleteCmd.Flags().Bool("force", false, "Skip confirmation prompt")
+
+	// Clone flags
+	templateCloneCmd.Flags().StringP("name", "n", "", "Name of the copy (required)")
+
+	// Usage flags
+	templateUsageCmd.Flags().StringP("deck", "d", "", "Only scan cards in this deck")
+
+	// Migrate flags
+	templateMigrateCmd.Flags().String("from", "", "Template the cards use now (required)")
+	templateMigrateCmd.Flags().String("to", "", "Template to move the cards to (required)")
+	templateMigrateCmd.Flags().String("map", "", "Field mapping as From=To pairs, comma-separated")
+	templateMigrateCmd.Flags().StringP("deck", "d", "", "Only migrate cards in this deck")
+	templateMigrateCmd.Flags().Bool("drop-unmapped", false, "Discard values of fields without a target")
+	templateMigrateCmd.Flags().Bool("force", false, "Skip confirmation prompt")
+	addCardFilterFlags(templateMigrateCmd)
+
+	// Render flags
+	templateRenderCmd.Flags().StringArray("field", nil, "Field value as Name=value (repeatable)")
+	templateRenderCmd.Flags().String("card", "", "Take field values from this card")
+	templateRenderCmd.Flags().String("to", "", "Output: ansi, markdown or html (default ansi on a terminal, else markdown)")
+	templateRenderCmd.Flags().Bool("strict", false, "Fail if the template has unknown placeholders")
 }
@@ -127,7 +127,7 @@ var upgradeCmd = &cobra.Command{
 	Short: "Upgrade mochi-cli to the latest version",
 	Long:  "Check for and install the latest version of mochi-cli from GitHub releases",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return runUpgrade()
+		return runUpgrade(cmd.Context())
 	},
 }
 
@@ -135,7 +135,7 @@ func init() {
 	rootCmd.AddCommand(upgradeCmd)
 }
 
-func runUpgrade() error {
+func runUpgrade(ctx context.Context) error {
 	fmt.Printf("Current version: %s\n", versionString)
 	fmt.Printf("Checking for updates...\n")
 
@@ -152,7 +152,7 @@ func runUpgrade() error {
 		return fmt.Errorf("failed to create updater: %w", err)
 	}
 
-	latest, found, err := updater.DetectLatest(context.Background(), selfupdate.NewRepositorySlug(repoOwner, repoName))
+	latest, found, err := updater.DetectLatest(ctx, selfupdate.NewRepositorySlug(repoOwner, repoName))
 	if err != nil {
 		return fmt.Errorf("failed to check for updates: %w", err)
 	}
@@ -175,7 +175,7 @@ func runUpgrade() error {
 		return fmt.Errorf("failed to get executable path: %w", err)
 	}
 
-	if err := updater.UpdateTo(context.Background(), latest, exe); err != nil {
+	if err := updater.UpdateTo(ctx, latest, exe); err != nil {
 		return fmt.Errorf("failed to update: %w", err)
 	}
 
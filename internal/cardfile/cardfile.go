@@ -0,0 +1,241 @@
+// Package cardfile renders a card as a markdown file with YAML front matter
+// and turns edits of that file back into a card update, for card edit:
+//
+//	---
+//	name: hola
+//	deck: atTCsEUb # Spanish
+//	tags: [greetings]
+//	archived: false
+//	---
+//	hola
+//	---
+//	hello
+package cardfile
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/nerveband/mochi-cli/internal/templating"
+	"github.com/nerveband/mochi-cli/pkg/mochi/models"
+	"gopkg.in/yaml.v3"
+)
+
+// delimiter opens and closes the front matter
+const delimiter = "---"
+
+// frontMatter is the metadata of a card file
+type frontMatter struct {
+	Name     string   `yaml:"name"`
+	Deck     string   `yaml:"deck"`
+	Tags     []string `yaml:"tags,flow"`
+	Archived bool     `yaml:"archived"`
+	// Fields maps template field names (or IDs) to values, in template order
+	Fields yaml.Node `yaml:"fields,omitempty"`
+}
+
+// Render returns the file for a card. tmpl, the card's template, names the
+// fields; fields Mochi generates are left out. deckPath, when known, is
+// shown next to the deck ID. header becomes a comment at the top of the
+// front matter.
+func Render(card *models.Card, tmpl *models.Template, deckPath, header string) ([]byte, error) {
+	fm := frontMatter{
+		Name:     card.Name,
+		Deck:     card.DeckID,
+		Tags:     card.ManualTags,
+		Archived: card.Archived,
+	}
+	if fm.Tags == nil {
+		fm.Tags = []string{}
+	}
+
+	if tmpl != nil && len(tmpl.Fields) > 0 {
+		fm.Fields = yaml.Node{Kind: yaml.MappingNode}
+		for _, field := range templating.SortedFields(tmpl) {
+			if templating.IsGenerated(field) {
+				continue
+			}
+			fm.Fields.Content = append(fm.Fields.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Value: fieldKey(tmpl, field)},
+				stringNode(card.Fields[field.ID].Value))
+		}
+	}
+
+	var doc yaml.Node
+	if err := doc.Encode(&fm); err != nil {
+		return nil, err
+	}
+	doc.HeadComment = header
+	for i := 0; i+1 < len(doc.Content); i += 2 {
+		if doc.Content[i].Value == "deck" && deckPath != "" {
+			doc.Content[i+1].LineComment = deckPath
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(delimiter + "\n")
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return nil, err
+	}
+	enc.Close()
+	buf.WriteString(delimiter + "\n")
+	// The content always gets a final newline, which Parse removes again,
+	// so newlines the content itself ends with are kept
+	if card.Content != "" {
+		buf.WriteString(card.Content)
+		buf.WriteString("\n")
+	}
+	return buf.Bytes(), nil
+}
+
+// fieldKey names a field in the front matter: by name, unless another field
+// has the same name or it has none
+func fieldKey(tmpl *models.Template, field models.TemplateField) string {
+	if field.Name == "" {
+		return field.ID
+	}
+	for _, other := range tmpl.Fields {
+		if other.ID != field.ID && strings.EqualFold(other.Name, field.Name) {
+			return field.ID
+		}
+	}
+	return field.Name
+}
+
+// stringNode returns a node for a string value, written as a block when it
+// spans lines
+func stringNode(value string) *yaml.Node {
+	n := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+	if strings.Contains(value, "\n") {
+		n.Style = yaml.LiteralStyle
+	}
+	return n
+}
+
+// Edit is a card as read back from an edited file
+type Edit struct {
+	Name     string
+	DeckID   string
+	Tags     []string
+	Archived bool
+	// Fields holds template field values by name or ID
+	Fields map[string]string
+	// Content is the text after the front matter, without the final newline
+	// Render adds
+	Content string
+}
+
+// Parse reads an edited card file
+func Parse(data []byte) (*Edit, error) {
+	lines := strings.SplitAfter(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	if strings.TrimSuffix(lines[0], "\n") != delimiter {
+		return nil, fmt.Errorf("the file must start with a %s line opening the front matter", delimiter)
+	}
+
+	// The front matter ends at the first delimiter line; the content may
+	// use more of them to separate card sides
+	end := -1
+	for i := 1; i < len(lines); i++ {
+		if strings.TrimSuffix(lines[i], "\n") == delimiter {
+			end = i
+			break
+		}
+	}
+	if end < 0 {
+		return nil, fmt.Errorf("missing %s line closing the front matter", delimiter)
+	}
+	meta := strings.Join(lines[1:end], "")
+	content := strings.TrimSuffix(strings.Join(lines[end+1:], ""), "\n")
+
+	var fm frontMatter
+	dec := yaml.NewDecoder(strings.NewReader(meta))
+	dec.KnownFields(true)
+	if err := dec.Decode(&fm); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid front matter: %w", err)
+	}
+	if strings.TrimSpace(fm.Deck) == "" {
+		return nil, fmt.Errorf("invalid front matter: deck must not be empty")
+	}
+
+	edit := &Edit{
+		Name:     fm.Name,
+		DeckID:   strings.TrimSpace(fm.Deck),
+		Tags:     fm.Tags,
+		Archived: fm.Archived,
+		Content:  content,
+	}
+	if !fm.Fields.IsZero() {
+		if err := fm.Fields.Decode(&edit.Fields); err != nil {
+			return nil, fmt.Errorf("invalid front matter: fields: %w", err)
+		}
+	}
+	return edit, nil
+}
+
+// Patch returns the update turning card into the edit, with only the
+// attributes that changed. Fields left out of the edit keep their values.
+// tmpl is the card's template, which field values are checked against.
+func (e *Edit) Patch(card *models.Card, tmpl *models.Template) (models.CardPatch, error) {
+	var patch models.CardPatch
+
+	if e.Name != card.Name {
+		patch.Name = models.Set(e.Name)
+	}
+	if e.DeckID != card.DeckID {
+		patch.DeckID = models.Set(e.DeckID)
+	}
+	if !sameTags(e.Tags, card.ManualTags) {
+		tags := e.Tags
+		if tags == nil {
+			tags = []string{}
+		}
+		patch.ManualTags = models.Set(tags)
+	}
+	if e.Archived != card.Archived {
+		patch.Archived = models.Set(e.Archived)
+	}
+	if e.Content != card.Content {
+		patch.Content = models.Set(e.Content)
+	}
+
+	if len(e.Fields) > 0 {
+		if tmpl == nil {
+			return patch, fmt.Errorf("the card uses no template, so it has no fields to set")
+		}
+		resolved, err := templating.ResolveFields(tmpl, e.Fields, true)
+		if err != nil {
+			return patch, err
+		}
+
+		fields := make(map[string]models.Field, len(card.Fields)+len(resolved))
+		for id, field := range card.Fields {
+			fields[id] = field
+		}
+		changed := false
+		for id, field := range resolved {
+			if card.Fields[id].Value != field.Value {
+				fields[id] = field
+				changed = true
+			}
+		}
+		if changed {
+			patch.Fields = models.Set(fields)
+		}
+	}
+
+	return patch, nil
+}
+
+// sameTags reports whether two tag lists are equal, treating nil as empty
+func sameTags(a, b []string) bool {
+	if len(a) == 0 && len(b) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
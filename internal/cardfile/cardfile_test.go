@@ -0,0 +1,200 @@
+package cardfile
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/nerveband/mochi-cli/pkg/mochi/models"
+)
+
+// basic is a template with two text fields and a generated one
+var basic = &models.Template{
+	ID:   "basic001",
+	Name: "Basic",
+	Fields: map[string]models.TemplateField{
+		"name":  {ID: "name", Name: "Front", Pos: "a"},
+		"back":  {ID: "back", Name: "Back", Pos: "b"},
+		"voice": {ID: "voice", Name: "Voice", Type: "speech", Pos: "c"},
+	},
+}
+
+func TestRoundTripUnchanged(t *testing.T) {
+	tests := []struct {
+		name string
+		card *models.Card
+		tmpl *models.Template
+	}{
+		{
+			name: "markdown with side separators",
+			card: &models.Card{
+				Name:       "hola",
+				DeckID:     "spanish1",
+				ManualTags: []string{"greetings"},
+				Content:    "hola\n---\nhello\n---\nextra side",
+			},
+		},
+		{
+			name: "content ending with newlines",
+			card: &models.Card{DeckID: "spanish1", Content: "line\n\n"},
+		},
+		{
+			name: "empty content",
+			card: &models.Card{DeckID: "spanish1", Archived: true},
+		},
+		{
+			name: "template fields",
+			card: &models.Card{
+				DeckID:     "spanish1",
+				TemplateID: "basic001",
+				Fields: map[string]models.Field{
+					"name":  {ID: "name", Value: "hablar"},
+					"back":  {ID: "back", Value: "to speak\nto talk"},
+					"voice": {ID: "voice", Value: "generated"},
+				},
+			},
+			tmpl: basic,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := Render(tt.card, tt.tmpl, "Spanish", "edit me")
+			if err != nil {
+				t.Fatalf("Render: %v", err)
+			}
+			edit, err := Parse(data)
+			if err != nil {
+				t.Fatalf("Parse: %v\n%s", err, data)
+			}
+			if edit.Content != tt.card.Content {
+				t.Errorf("content = %q, want %q", edit.Content, tt.card.Content)
+			}
+			patch, err := edit.Patch(tt.card, tt.tmpl)
+			if err != nil {
+				t.Fatalf("Patch: %v", err)
+			}
+			if !patch.IsEmpty() {
+				t.Errorf("patch of an unchanged file = %v, want empty\n%s", patch.Payload(), data)
+			}
+		})
+	}
+}
+
+func TestRenderLeavesOutGeneratedFields(t *testing.T) {
+	card := &models.Card{DeckID: "d", Fields: map[string]models.Field{"voice": {ID: "voice", Value: "x"}}}
+	data, err := Render(card, basic, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "Voice") {
+		t.Errorf("rendered file lists the generated Voice field:\n%s", data)
+	}
+}
+
+func TestPatchChanges(t *testing.T) {
+	card := &models.Card{
+		Name:       "hola",
+		DeckID:     "spanish1",
+		ManualTags: []string{"greetings"},
+		Content:    "hola\n---\nhello",
+	}
+	edited := "---\nname: hola\ndeck: verbs001\ntags: [greetings, basics]\narchived: true\n---\n" +
+		"hola\n---\nhello, hi\n\n"
+	edit, err := Parse([]byte(edited))
+	if err != nil {
+		t.Fatal(err)
+	}
+	patch, err := edit.Patch(card, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload := patch.Payload()
+	if _, ok := payload["name"]; ok {
+		t.Error("unchanged name is in the patch")
+	}
+	if payload["deck-id"] != "verbs001" || payload["archived?"] != true {
+		t.Errorf("patch = %v, want the new deck and archived", payload)
+	}
+	if tags, _ := payload["manual-tags"].([]string); strings.Join(tags, ",") != "greetings,basics" {
+		t.Errorf("tags = %v, want [greetings basics]", payload["manual-tags"])
+	}
+	// Only the newline Render adds is dropped; the blank line the user
+	// left at the end is kept
+	if payload["content"] != "hola\n---\nhello, hi\n" {
+		t.Errorf("content = %q, want %q", payload["content"], "hola\n---\nhello, hi\n")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		want string
+	}{
+		{"no front matter", "hola\n", "must start with"},
+		{"unclosed front matter", "---\ndeck: x\nhola\n", "missing --- line"},
+		{"unknown key", "---\ndeck: x\ncolour: red\n---\nhola\n", "field colour not found"},
+		{"empty deck", "---\nname: x\ndeck: \"\"\n---\n", "deck must not be empty"},
+		{"bad yaml", "---\ndeck: [x\n---\n", "invalid front matter"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.file))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Parse error = %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPatchFields(t *testing.T) {
+	card := &models.Card{
+		DeckID:     "d",
+		TemplateID: "basic001",
+		Fields: map[string]models.Field{
+			"name": {ID: "name", Value: "hablar"},
+			"back": {ID: "back", Value: "to speak"},
+		},
+	}
+	parse := func(fields string) *Edit {
+		t.Helper()
+		edit, err := Parse([]byte("---\ndeck: d\nfields:\n" + fields + "---\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return edit
+	}
+
+	// By name, case-insensitively, or by ID
+	for _, fields := range []string{"  Back: to talk\n", "  back: to talk\n"} {
+		patch, err := parse(fields).Patch(card, basic)
+		if err != nil {
+			t.Fatalf("Patch(%q): %v", fields, err)
+		}
+		got, ok := patch.Fields.Get()
+		if !ok {
+			t.Fatalf("Patch(%q) sets no fields", fields)
+		}
+		if got["back"].Value != "to talk" || got["name"].Value != "hablar" {
+			t.Errorf("Patch(%q) fields = %v, want back changed and name kept", fields, got)
+		}
+	}
+
+	// The same value is no change
+	if patch, err := parse("  Front: hablar\n").Patch(card, basic); err != nil || patch.Fields.IsSet() {
+		t.Errorf("unchanged field: patch %v, error %v, want no fields", patch.Payload(), err)
+	}
+
+	for fields, want := range map[string]string{
+		"  Colour: red\n": `unknown field "Colour"`,
+		"  Voice: hi\n":   "generated by Mochi",
+	} {
+		if _, err := parse(fields).Patch(card, basic); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Patch(%q) error = %v, want one containing %q", fields, err, want)
+		}
+	}
+
+	if _, err := parse("  Back: x\n").Patch(&models.Card{DeckID: "d"}, nil); err == nil {
+		t.Error("setting fields on a card without a template succeeded")
+	}
+}
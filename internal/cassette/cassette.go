@@ -0,0 +1,396 @@
+// Package cassette records API exchanges to a file and replays them later
+// without network access, for reproducible scripts, demos and pipeline
+// tests. A Recorder and a Player are http.RoundTrippers, so they plug into
+// any http.Client.
+package cassette
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Version is the cassette file format version
+const Version = 1
+
+// ErrNoMatch is returned by a Player when no recorded interaction matches a
+// request
+var ErrNoMatch = errors.New("no matching interaction in cassette")
+
+// Cassette is a recorded sequence of request/response interactions
+type Cassette struct {
+	Version      int           `json:"version"`
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is one recorded exchange
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Request identifies a recorded request. Headers are not stored, so
+// credentials never end up in a cassette.
+type Request struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	// BodyHash is the SHA-256 of the normalized request body
+	BodyHash string `json:"body_sha256,omitempty"`
+	// Body is the request body, kept for readability only; matching uses
+	// BodyHash
+	Body string `json:"body,omitempty"`
+}
+
+// Response is a recorded response
+type Response struct {
+	Status  int         `json:"status"`
+	Headers http.Header `json:"headers,omitempty"`
+	Body    string      `json:"body"`
+	// Encoding is "base64" for binary bodies
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// Load reads a cassette file
+func Load(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cassette: %w", err)
+	}
+
+	var c Cassette
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("invalid cassette %s: %w", path, err)
+	}
+	if c.Version != Version {
+		return nil, fmt.Errorf("unsupported cassette version %d in %s", c.Version, path)
+	}
+	return &c, nil
+}
+
+// Save writes the cassette to path, replacing the file atomically
+func (c *Cassette) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".cassette-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Matcher selects which parts of a request must be equal for a recorded
+// interaction to be replayed
+type Matcher struct {
+	Method bool
+	Path   bool
+	Query  bool
+	Body   bool
+}
+
+// DefaultMatcher matches on method, path, query and body hash
+var DefaultMatcher = Matcher{Method: true, Path: true, Query: true, Body: true}
+
+// ParseMatcher parses a comma-separated list of method, path, query and
+// body, e.g. "method,path". An empty spec returns DefaultMatcher.
+func ParseMatcher(spec string) (Matcher, error) {
+	if strings.TrimSpace(spec) == "" {
+		return DefaultMatcher, nil
+	}
+
+	var m Matcher
+	for _, part := range strings.Split(spec, ",") {
+		switch strings.ToLower(strings.TrimSpace(part)) {
+		case "method":
+			m.Method = true
+		case "path":
+			m.Path = true
+		case "query":
+			m.Query = true
+		case "body":
+			m.Body = true
+		case "":
+		default:
+			return Matcher{}, fmt.Errorf("unknown cassette match criterion %q (use method, path, query, body)", part)
+		}
+	}
+	return m, nil
+}
+
+// matches reports whether a recorded request matches an incoming one
+func (m Matcher) matches(recorded Request, method string, u *url.URL, bodyHash string) bool {
+	ru, err := url.Parse(recorded.URL)
+	if err != nil {
+		return false
+	}
+	if m.Method && !strings.EqualFold(recorded.Method, method) {
+		return false
+	}
+	if m.Path && ru.Path != u.Path {
+		return false
+	}
+	if m.Query && ru.Query().Encode() != u.Query().Encode() {
+		return false
+	}
+	if m.Body && recorded.BodyHash != bodyHash {
+		return false
+	}
+	return true
+}
+
+// Recorder is an http.RoundTripper that performs requests and records each
+// exchange in memory. Call Save once the session ends to write the
+// cassette file.
+type Recorder struct {
+	// Base performs the requests; nil uses http.DefaultTransport
+	Base http.RoundTripper
+
+	path     string
+	mu       sync.Mutex
+	cassette Cassette
+}
+
+// NewRecorder creates a Recorder that writes to path. Interactions are
+// appended to an existing cassette there, so a script running several
+// commands records them all; remove the file to start over.
+func NewRecorder(base http.RoundTripper, path string) (*Recorder, error) {
+	r := &Recorder{
+		Base:     base,
+		path:     path,
+		cassette: Cassette{Version: Version, Interactions: []Interaction{}},
+	}
+
+	if _, err := os.Stat(path); err == nil {
+		c, err := Load(path)
+		if err != nil {
+			return nil, err
+		}
+		r.cassette = *c
+	}
+	return r, nil
+}
+
+// RoundTrip implements http.RoundTripper
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := requestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	rt := r.Base
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	interaction := Interaction{
+		Request: Request{
+			Method:   req.Method,
+			URL:      req.URL.String(),
+			BodyHash: hashBody(req.Header.Get("Content-Type"), body),
+		},
+		Response: Response{
+			Status:  resp.StatusCode,
+			Headers: recordedHeaders(resp.Header),
+		},
+	}
+	if text, encoding := encodeBody(body); encoding == "" {
+		interaction.Request.Body = text
+	}
+	interaction.Response.Body, interaction.Response.Encoding = encodeBody(respBody)
+
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, interaction)
+	r.mu.Unlock()
+
+	return resp, nil
+}
+
+// Save writes the recorded interactions, after those already in the file
+// when the Recorder was created, to the cassette file
+func (r *Recorder) Save() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.cassette.Save(r.path); err != nil {
+		return fmt.Errorf("failed to save cassette: %w", err)
+	}
+	return nil
+}
+
+// Len returns the number of interactions in the cassette
+func (r *Recorder) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.cassette.Interactions)
+}
+
+// Player is an http.RoundTripper that answers requests from a cassette
+// without touching the network. Interactions are replayed in recorded order:
+// each request gets the first unused matching interaction, and once all
+// matches are used the last one is served again.
+type Player struct {
+	cassette *Cassette
+	matcher  Matcher
+
+	mu   sync.Mutex
+	used []bool
+}
+
+// NewPlayer creates a Player serving interactions from c
+func NewPlayer(c *Cassette, m Matcher) *Player {
+	return &Player{
+		cassette: c,
+		matcher:  m,
+		used:     make([]bool, len(c.Interactions)),
+	}
+}
+
+// RoundTrip implements http.RoundTripper
+func (p *Player) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := requestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	hash := hashBody(req.Header.Get("Content-Type"), body)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	last := -1
+	for i, interaction := range p.cassette.Interactions {
+		if !p.matcher.matches(interaction.Request, req.Method, req.URL, hash) {
+			continue
+		}
+		last = i
+		if !p.used[i] {
+			p.used[i] = true
+			return interaction.Response.httpResponse(req)
+		}
+	}
+	if last >= 0 {
+		return p.cassette.Interactions[last].Response.httpResponse(req)
+	}
+
+	return nil, fmt.Errorf("%w: %s %s", ErrNoMatch, req.Method, req.URL.RequestURI())
+}
+
+// httpResponse builds the response for a replayed interaction
+func (r Response) httpResponse(req *http.Request) (*http.Response, error) {
+	body := []byte(r.Body)
+	if r.Encoding == "base64" {
+		var err error
+		if body, err = base64.StdEncoding.DecodeString(r.Body); err != nil {
+			return nil, fmt.Errorf("invalid body in cassette: %w", err)
+		}
+	}
+
+	header := r.Headers.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", r.Status, http.StatusText(r.Status)),
+		StatusCode:    r.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// requestBody returns the request body, leaving the request able to send it
+func requestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer body.Close()
+		return io.ReadAll(body)
+	}
+
+	data, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(data))
+	return data, nil
+}
+
+// hashBody returns the SHA-256 of a request body. Multipart boundaries are
+// random per request, so they are normalized before hashing.
+func hashBody(contentType string, body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+	if mediaType, params, err := mime.ParseMediaType(contentType); err == nil &&
+		strings.HasPrefix(mediaType, "multipart/") && params["boundary"] != "" {
+		body = bytes.ReplaceAll(body, []byte(params["boundary"]), []byte("BOUNDARY"))
+	}
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// recordedHeaders returns the response headers worth keeping in a cassette
+func recordedHeaders(h http.Header) http.Header {
+	out := http.Header{}
+	for _, name := range []string{"Content-Type", "Retry-After", "Location"} {
+		if v := h.Values(name); len(v) > 0 {
+			out[name] = v
+		}
+	}
+	return out
+}
+
+// encodeBody returns a body as text, or base64 with its encoding for binary
+// data
+func encodeBody(body []byte) (string, string) {
+	if utf8.Valid(body) {
+		return string(body), ""
+	}
+	return base64.StdEncoding.EncodeToString(body), "base64"
+}
@@ -0,0 +1,224 @@
+package cassette
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+
+	"github.com/nerveband/mochi-cli/internal/fakeserver"
+	"github.com/nerveband/mochi-cli/pkg/mochi"
+	"github.com/nerveband/mochi-cli/pkg/mochi/models"
+)
+
+func TestParseMatcher(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    Matcher
+		wantErr bool
+	}{
+		{"", DefaultMatcher, false},
+		{"  ", DefaultMatcher, false},
+		{"method,path", Matcher{Method: true, Path: true}, false},
+		{" Method , BODY ", Matcher{Method: true, Body: true}, false},
+		{"path,,query,", Matcher{Path: true, Query: true}, false},
+		{"method,path,query,body", DefaultMatcher, false},
+		{"method,headers", Matcher{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseMatcher(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseMatcher(%q) error = %v, want error %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseMatcher(%q) = %+v, want %+v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestMatcherMatches(t *testing.T) {
+	recorded := Request{
+		Method:   "GET",
+		URL:      "https://app.mochi.cards/api/cards?deck-id=abc&limit=10",
+		BodyHash: "hash1",
+	}
+	tests := []struct {
+		name    string
+		matcher Matcher
+		method  string
+		url     string
+		hash    string
+		want    bool
+	}{
+		{"identical", DefaultMatcher, "GET", "https://app.mochi.cards/api/cards?deck-id=abc&limit=10", "hash1", true},
+		{"query in another order", DefaultMatcher, "GET", "https://app.mochi.cards/api/cards?limit=10&deck-id=abc", "hash1", true},
+		{"another host", DefaultMatcher, "GET", "http://127.0.0.1:8787/api/cards?deck-id=abc&limit=10", "hash1", true},
+		{"method case", DefaultMatcher, "get", "https://app.mochi.cards/api/cards?deck-id=abc&limit=10", "hash1", true},
+		{"another method", DefaultMatcher, "POST", "https://app.mochi.cards/api/cards?deck-id=abc&limit=10", "hash1", false},
+		{"another path", DefaultMatcher, "GET", "https://app.mochi.cards/api/decks?deck-id=abc&limit=10", "hash1", false},
+		{"another query", DefaultMatcher, "GET", "https://app.mochi.cards/api/cards?deck-id=xyz&limit=10", "hash1", false},
+		{"another query, not matched", Matcher{Method: true, Path: true, Body: true}, "GET", "https://app.mochi.cards/api/cards", "hash1", true},
+		{"another body", DefaultMatcher, "GET", "https://app.mochi.cards/api/cards?deck-id=abc&limit=10", "hash2", false},
+		{"another body, not matched", Matcher{Method: true, Path: true, Query: true}, "GET", "https://app.mochi.cards/api/cards?deck-id=abc&limit=10", "hash2", true},
+		{"nothing matched", Matcher{}, "DELETE", "https://example.com/other", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := url.Parse(tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := tt.matcher.matches(recorded, tt.method, u, tt.hash); got != tt.want {
+				t.Errorf("matches = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// multipartBody encodes a file upload with a fresh random boundary
+func multipartBody(t *testing.T, content string) (string, []byte) {
+	t.Helper()
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	part, err := w.CreateFormFile("file", "notes.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(part, content)
+	w.Close()
+	return w.FormDataContentType(), buf.Bytes()
+}
+
+func TestHashBody(t *testing.T) {
+	if got := hashBody("application/json", nil); got != "" {
+		t.Errorf("hash of an empty body = %q, want empty", got)
+	}
+
+	a := hashBody("application/json", []byte(`{"content":"hola"}`))
+	if a == "" || a != hashBody("application/json", []byte(`{"content":"hola"}`)) {
+		t.Error("equal bodies hash differently")
+	}
+	if a == hashBody("application/json", []byte(`{"content":"adiós"}`)) {
+		t.Error("different bodies hash the same")
+	}
+
+	type1, body1 := multipartBody(t, "same file")
+	type2, body2 := multipartBody(t, "same file")
+	if bytes.Equal(body1, body2) {
+		t.Fatal("multipart bodies share a boundary")
+	}
+	if hashBody(type1, body1) != hashBody(type2, body2) {
+		t.Error("multipart bodies differing only in boundary hash differently")
+	}
+	type3, body3 := multipartBody(t, "other file")
+	if hashBody(type1, body1) == hashBody(type3, body3) {
+		t.Error("multipart bodies with different files hash the same")
+	}
+}
+
+// session runs API calls whose results a replay must reproduce
+func session(t *testing.T, client *mochi.Client) []string {
+	t.Helper()
+	var got []string
+
+	deck, err := client.CreateDeck(&models.Deck{Name: "Spanish"})
+	if err != nil {
+		t.Fatalf("CreateDeck: %v", err)
+	}
+	card, err := client.CreateCard(&models.Card{DeckID: deck.ID, Content: "hola\n---\nhello"})
+	if err != nil {
+		t.Fatalf("CreateCard: %v", err)
+	}
+	if err := client.AddAttachment(card.ID, "notes.txt", []byte("some notes")); err != nil {
+		t.Fatalf("AddAttachment: %v", err)
+	}
+	page, err := client.ListCards(deck.ID, 10, "")
+	if err != nil {
+		t.Fatalf("ListCards: %v", err)
+	}
+	for _, c := range page.Docs {
+		got = append(got, c.ID+" "+c.Content)
+	}
+	got = append(got, deck.ID, card.ID)
+
+	if _, err := client.GetCard("missing1"); !mochi.IsNotFound(err) {
+		t.Fatalf("GetCard of a missing card: error %v, want not found", err)
+	}
+	return got
+}
+
+func TestRecordReplay(t *testing.T) {
+	srv := fakeserver.New()
+	srv.APIKey = "test-key"
+	ts := httptest.NewServer(http.StripPrefix("/api", srv))
+	baseURL := ts.URL + "/api"
+	path := filepath.Join(t.TempDir(), "session.json")
+
+	rec, err := NewRecorder(http.DefaultTransport, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorded := session(t, mochi.NewClient("test-key", mochi.WithBaseURL(baseURL), mochi.WithTransport(rec)))
+	if err := rec.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Len() != 5 {
+		t.Errorf("recorded %d interactions, want 5", rec.Len())
+	}
+
+	// Replay with the server gone, so nothing can reach the network
+	ts.Close()
+	c, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	player := NewPlayer(c, DefaultMatcher)
+	client := mochi.NewClient("other-key",
+		mochi.WithBaseURL(baseURL),
+		mochi.WithTransport(player),
+		mochi.WithRetryPolicy(mochi.RetryPolicy{}))
+	replayed := session(t, client)
+	if len(replayed) != len(recorded) {
+		t.Fatalf("replay returned %v, want %v", replayed, recorded)
+	}
+	for i := range recorded {
+		if replayed[i] != recorded[i] {
+			t.Errorf("replay returned %v, want %v", replayed, recorded)
+			break
+		}
+	}
+
+	if _, err := client.CreateDeck(&models.Deck{Name: "French"}); !errors.Is(err, ErrNoMatch) {
+		t.Errorf("unrecorded request: error %v, want ErrNoMatch", err)
+	}
+}
+
+func TestRecorderAppends(t *testing.T) {
+	srv := fakeserver.New()
+	ts := httptest.NewServer(http.StripPrefix("/api", srv))
+	defer ts.Close()
+	path := filepath.Join(t.TempDir(), "session.json")
+
+	for run := 1; run <= 2; run++ {
+		rec, err := NewRecorder(nil, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		client := mochi.NewClient("key", mochi.WithBaseURL(ts.URL+"/api"), mochi.WithTransport(rec))
+		if _, err := client.ListDecks(""); err != nil {
+			t.Fatal(err)
+		}
+		if err := rec.Save(); err != nil {
+			t.Fatal(err)
+		}
+		if rec.Len() != run {
+			t.Errorf("run %d: cassette has %d interactions, want %d", run, rec.Len(), run)
+		}
+	}
+}
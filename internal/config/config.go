@@ -2,10 +2,15 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"sync"
+	"time"
 )
 
 const (
@@ -13,9 +18,130 @@ const (
 	configFile = "config.json"
 )
 
+// ErrNoAPIKey is returned when no API key is configured anywhere
+var ErrNoAPIKey = errors.New("no API key found")
+
+// Error reports a configuration problem, such as an unreadable config file
+// or a missing profile
+type Error struct {
+	err error
+}
+
+// errorf creates a configuration error; %w wraps like fmt.Errorf
+func errorf(format string, args ...interface{}) error {
+	return &Error{err: fmt.Errorf(format, args...)}
+}
+
+// Error implements the error interface
+func (e *Error) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the underlying error, if any
+func (e *Error) Unwrap() error {
+	return errors.Unwrap(e.err)
+}
+
 // Profile represents a Mochi API profile
 type Profile struct {
-	APIKey string `json:"api_key"`
+	APIKey  string `json:"api_key"`
+	BaseURL string `json:"base_url,omitempty"`
+
+	// Retry and rate limit tuning; zero values mean "use the default"
+	MaxRetries   *int    `json:"max_retries,omitempty"`
+	RetryMaxWait string  `json:"retry_max_wait,omitempty"`
+	RateLimit    float64 `json:"rate_limit,omitempty"`
+
+	// Attachment upload defaults; zero values disable the step
+	AttachmentMaxSize     string `json:"attachment_max_size,omitempty"`
+	AttachmentMaxDim      int    `json:"attachment_max_dim,omitempty"`
+	AttachmentJPEGQuality int    `json:"attachment_jpeg_quality,omitempty"`
+}
+
+// profileSetters maps the keys accepted by SetProfileValue to functions that
+// validate and apply them. An empty value resets the setting.
+var profileSetters = map[string]func(p *Profile, value string) error{
+	"base-url": func(p *Profile, value string) error {
+		p.BaseURL = value
+		return nil
+	},
+	"max-retries": func(p *Profile, value string) error {
+		if value == "" {
+			p.MaxRetries = nil
+			return nil
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return errorf("max-retries must be a non-negative integer")
+		}
+		p.MaxRetries = &n
+		return nil
+	},
+	"retry-max-wait": func(p *Profile, value string) error {
+		if value != "" {
+			if _, err := time.ParseDuration(value); err != nil {
+				return errorf("retry-max-wait must be a duration such as 30s: %w", err)
+			}
+		}
+		p.RetryMaxWait = value
+		return nil
+	},
+	"rate-limit": func(p *Profile, value string) error {
+		if value == "" {
+			p.RateLimit = 0
+			return nil
+		}
+		rate, err := strconv.ParseFloat(value, 64)
+		if err != nil || rate < 0 {
+			return errorf("rate-limit must be a non-negative number of requests per second")
+		}
+		p.RateLimit = rate
+		return nil
+	},
+	"attachment-max-size": func(p *Profile, value string) error {
+		if value != "" && !sizeRe.MatchString(value) {
+			return errorf("attachment-max-size must be a size such as 10MB or 512KB")
+		}
+		p.AttachmentMaxSize = value
+		return nil
+	},
+	"attachment-max-dim": func(p *Profile, value string) error {
+		if value == "" {
+			p.AttachmentMaxDim = 0
+			return nil
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return errorf("attachment-max-dim must be a non-negative number of pixels")
+		}
+		p.AttachmentMaxDim = n
+		return nil
+	},
+	"attachment-jpeg-quality": func(p *Profile, value string) error {
+		if value == "" {
+			p.AttachmentJPEGQuality = 0
+			return nil
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > 100 {
+			return errorf("attachment-jpeg-quality must be between 1 and 100")
+		}
+		p.AttachmentJPEGQuality = n
+		return nil
+	},
+}
+
+// sizeRe matches sizes such as "10MB", "512 KiB" or "2048"
+var sizeRe = regexp.MustCompile(`(?i)^\s*\d+(\.\d+)?\s*([kmg]i?b?|b)?\s*$`)
+
+// ProfileKeys returns the setting names accepted by SetProfileValue
+func ProfileKeys() []string {
+	keys := make([]string, 0, len(profileSetters))
+	for k := range profileSetters {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
 }
 
 // Config represents the CLI configuration
@@ -40,6 +166,12 @@ func GetConfig() (*Config, error) {
 	return instance, err
 }
 
+// Dir returns the directory holding the config file and other per-user
+// state, such as search indexes
+func Dir() string {
+	return filepath.Dir(getConfigPath())
+}
+
 // getConfigPath returns the path to the config file
 func getConfigPath() string {
 	home, err := os.UserHomeDir()
@@ -56,7 +188,7 @@ func loadConfig() (*Config, error) {
 	// Ensure directory exists
 	dir := filepath.Dir(path)
 	if err := os.MkdirAll(dir, 0700); err != nil {
-		return nil, fmt.Errorf("failed to create config directory: %w", err)
+		return nil, errorf("failed to create config directory: %w", err)
 	}
 
 	// Check if file exists
@@ -71,12 +203,12 @@ func loadConfig() (*Config, error) {
 	// Read file
 	data, err := os.ReadFile(path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read config: %w", err)
+		return nil, errorf("failed to read config: %w", err)
 	}
 
 	var cfg Config
 	if err := json.Unmarshal(data, &cfg); err != nil {
-		return nil, fmt.Errorf("failed to parse config: %w", err)
+		return nil, errorf("failed to parse config: %w", err)
 	}
 
 	if cfg.Profiles == nil {
@@ -99,12 +231,12 @@ func (c *Config) saveUnlocked() error {
 
 	data, err := json.MarshalIndent(c, "", "  ")
 	if err != nil {
-		return fmt.Errorf("failed to marshal config: %w", err)
+		return errorf("failed to marshal config: %w", err)
 	}
 
 	// Set secure permissions
 	if err := os.WriteFile(path, data, 0600); err != nil {
-		return fmt.Errorf("failed to write config: %w", err)
+		return errorf("failed to write config: %w", err)
 	}
 
 	return nil
@@ -115,9 +247,9 @@ func (c *Config) AddProfile(name, apiKey string) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	c.Profiles[name] = Profile{
-		APIKey: apiKey,
-	}
+	p := c.Profiles[name]
+	p.APIKey = apiKey
+	c.Profiles[name] = p
 
 	// If this is the first profile, set it as active
 	if c.ActiveProfile == "" {
@@ -133,7 +265,7 @@ func (c *Config) RemoveProfile(name string) error {
 	defer c.mu.Unlock()
 
 	if _, ok := c.Profiles[name]; !ok {
-		return fmt.Errorf("profile '%s' does not exist", name)
+		return errorf("profile '%s' does not exist", name)
 	}
 
 	delete(c.Profiles, name)
@@ -151,13 +283,43 @@ func (c *Config) RemoveProfile(name string) error {
 	return c.saveUnlocked()
 }
 
+// SetBaseURL sets the API base URL for a profile. An empty URL resets the
+// profile to the default Mochi endpoint.
+func (c *Config) SetBaseURL(name, baseURL string) error {
+	return c.SetProfileValue(name, "base-url", baseURL)
+}
+
+// SetProfileValue sets a named setting on a profile. See ProfileKeys for the
+// accepted names; an empty value resets the setting to its default.
+func (c *Config) SetProfileValue(name, key, value string) error {
+	setter, ok := profileSetters[key]
+	if !ok {
+		return errorf("unknown setting '%s' (valid: %v)", key, ProfileKeys())
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	p, ok := c.Profiles[name]
+	if !ok {
+		return errorf("profile '%s' does not exist", name)
+	}
+
+	if err := setter(&p, value); err != nil {
+		return err
+	}
+
+	c.Profiles[name] = p
+	return c.saveUnlocked()
+}
+
 // UseProfile sets the active profile
 func (c *Config) UseProfile(name string) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	if _, ok := c.Profiles[name]; !ok {
-		return fmt.Errorf("profile '%s' does not exist", name)
+		return errorf("profile '%s' does not exist", name)
 	}
 
 	c.ActiveProfile = name
@@ -170,12 +332,12 @@ func (c *Config) GetActiveProfile() (string, Profile, error) {
 	defer c.mu.RUnlock()
 
 	if c.ActiveProfile == "" {
-		return "", Profile{}, fmt.Errorf("no active profile set. Run 'mochi config add <name> <api-key>' to create a profile")
+		return "", Profile{}, errorf("no active profile set. Run 'mochi config add <name> <api-key>' to create a profile")
 	}
 
 	profile, ok := c.Profiles[c.ActiveProfile]
 	if !ok {
-		return "", Profile{}, fmt.Errorf("active profile '%s' not found", c.ActiveProfile)
+		return "", Profile{}, errorf("active profile '%s' not found", c.ActiveProfile)
 	}
 
 	return c.ActiveProfile, profile, nil
@@ -188,7 +350,7 @@ func (c *Config) GetProfile(name string) (Profile, error) {
 
 	profile, ok := c.Profiles[name]
 	if !ok {
-		return Profile{}, fmt.Errorf("profile '%s' not found", name)
+		return Profile{}, errorf("profile '%s' not found", name)
 	}
 
 	return profile, nil
@@ -228,3 +390,13 @@ func GetAPIKey(profile Profile) string {
 	// Fall back to profile
 	return profile.APIKey
 }
+
+// GetBaseURL returns the API base URL, checking environment variable first.
+// An empty result means the client default should be used.
+func GetBaseURL(profile Profile) string {
+	if u := os.Getenv("MOCHI_BASE_URL"); u != "" {
+		return u
+	}
+
+	return profile.BaseURL
+}
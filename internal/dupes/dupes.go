@@ -0,0 +1,166 @@
+// Package dupes finds near-duplicate cards. Card text is normalized,
+// split into character shingles and compared by Jaccard similarity; MinHash
+// signatures with locality sensitive hashing pick the pairs worth comparing,
+// so large collections aren't compared pair by pair.
+package dupes
+
+import (
+	"sort"
+)
+
+// Doc is a text to compare, such as a card's content
+type Doc struct {
+	ID   string
+	Text string
+}
+
+// Pair is two documents and the Jaccard similarity of their shingles
+type Pair struct {
+	A, B       string
+	Similarity float64
+}
+
+// Cluster is a group of documents linked by similar pairs. A document may
+// be similar to another one in its cluster only through a third.
+type Cluster struct {
+	// IDs are the documents, in the order they were given
+	IDs   []string
+	Pairs []Pair
+}
+
+// MaxSimilarity returns the similarity of the cluster's closest pair
+func (c *Cluster) MaxSimilarity() float64 {
+	max := 0.0
+	for _, p := range c.Pairs {
+		if p.Similarity > max {
+			max = p.Similarity
+		}
+	}
+	return max
+}
+
+// Find groups the documents whose normalized text has a Jaccard similarity
+// of at least threshold (between 0 and 1) with another one of the group.
+// Documents without text are ignored. Clusters are returned largest first.
+func Find(docs []Doc, threshold float64) []Cluster {
+	type entry struct {
+		id       string
+		shingles map[uint64]bool
+		sig      signature
+	}
+	var entries []entry
+	for _, d := range docs {
+		shingles := Shingles(Normalize(d.Text))
+		if len(shingles) == 0 {
+			continue
+		}
+		entries = append(entries, entry{id: d.ID, shingles: shingles, sig: minHash(shingles)})
+	}
+
+	// Documents sharing a band bucket are candidates
+	rows := bandRows(threshold)
+	type pairKey struct{ a, b int }
+	candidates := map[pairKey]bool{}
+	for band := 0; band < numHashes/rows; band++ {
+		buckets := map[uint64][]int{}
+		for i := range entries {
+			key := bandKey(&entries[i].sig, band, rows)
+			for _, j := range buckets[key] {
+				candidates[pairKey{j, i}] = true
+			}
+			buckets[key] = append(buckets[key], i)
+		}
+	}
+
+	// Confirm candidates with their exact similarity, joining the pairs
+	// into clusters
+	parent := make([]int, len(entries))
+	for i := range parent {
+		parent[i] = i
+	}
+	var root func(i int) int
+	root = func(i int) int {
+		if parent[i] != i {
+			parent[i] = root(parent[i])
+		}
+		return parent[i]
+	}
+
+	var pairs []pairKey
+	for k := range candidates {
+		pairs = append(pairs, k)
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].a != pairs[j].a {
+			return pairs[i].a < pairs[j].a
+		}
+		return pairs[i].b < pairs[j].b
+	})
+
+	similar := map[int][]Pair{}
+	linked := make([]bool, len(entries))
+	for _, k := range pairs {
+		sim := Jaccard(entries[k.a].shingles, entries[k.b].shingles)
+		if sim < threshold {
+			continue
+		}
+		ra, rb := root(k.a), root(k.b)
+		if ra != rb {
+			parent[rb] = ra
+		}
+		similar[k.a] = append(similar[k.a], Pair{A: entries[k.a].id, B: entries[k.b].id, Similarity: sim})
+		linked[k.a], linked[k.b] = true, true
+	}
+
+	byRoot := map[int]*Cluster{}
+	var order []int
+	for i := range entries {
+		if !linked[i] {
+			continue
+		}
+		r := root(i)
+		c, ok := byRoot[r]
+		if !ok {
+			c = &Cluster{}
+			byRoot[r] = c
+			order = append(order, r)
+		}
+		c.IDs = append(c.IDs, entries[i].id)
+		c.Pairs = append(c.Pairs, similar[i]...)
+	}
+
+	clusters := make([]Cluster, 0, len(order))
+	for _, r := range order {
+		clusters = append(clusters, *byRoot[r])
+	}
+	sort.SliceStable(clusters, func(i, j int) bool {
+		if len(clusters[i].IDs) != len(clusters[j].IDs) {
+			return len(clusters[i].IDs) > len(clusters[j].IDs)
+		}
+		return clusters[i].MaxSimilarity() > clusters[j].MaxSimilarity()
+	})
+	return clusters
+}
+
+// Compare returns the similarity of two texts, as Find measures it
+func Compare(a, b string) float64 {
+	return Jaccard(Shingles(Normalize(a)), Shingles(Normalize(b)))
+}
+
+// Jaccard returns the size of the intersection of two shingle sets over
+// the size of their union
+func Jaccard(a, b map[uint64]bool) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	shared := 0
+	for s := range a {
+		if b[s] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
@@ -0,0 +1,188 @@
+package dupes
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nerveband/mochi-cli/pkg/mochi/models"
+)
+
+// words are distinct words to build texts with a known overlap from
+var words = strings.Fields(`apple bridge candle dolphin engine forest guitar harbor
+	island jacket kettle lantern mirror needle orange pepper quartz rabbit saddle
+	tunnel umbrella velvet window yogurt zipper anchor blossom canyon meadow puzzle`)
+
+// text joins words[from:to]
+func text(from, to int) string {
+	return strings.Join(words[from:to], " ")
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"Hello,   World!", "hello world"},
+		{"**bold** and _italic_", "bold and italic"},
+		{"see [the docs](https://example.com)", "see the docs"},
+		{"a ![diagram](@media/x.png) b", "a b"},
+		{"<b>tag</b>\n---\nback", "tag back"},
+		{"{{c1::Paris}} is the capital", "paris is the capital"},
+		{"{{Paris}}", "paris"},
+		{"Ça VA?", "ça va"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := Normalize(tt.in); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	if got := Compare("# Hola\n---\nhello", "hola — HELLO"); got != 1 {
+		t.Errorf("texts differing only in formatting have similarity %v, want 1", got)
+	}
+	if got := Compare(text(0, 10), text(10, 20)); got > 0.1 {
+		t.Errorf("unrelated texts have similarity %v, want about 0", got)
+	}
+	if got := Compare("abc", "abc"); got != 1 {
+		t.Errorf("short identical texts have similarity %v, want 1", got)
+	}
+}
+
+func TestFindThreshold(t *testing.T) {
+	docs := []Doc{
+		{ID: "a", Text: text(0, 20)},
+		{ID: "b", Text: text(2, 22)},
+		{ID: "other", Text: text(22, 30)},
+	}
+	sim := Compare(docs[0].Text, docs[1].Text)
+	if sim < 0.5 || sim > 0.95 {
+		t.Fatalf("test texts have similarity %v, want between 0.5 and 0.95", sim)
+	}
+
+	clusters := Find(docs, sim)
+	if len(clusters) != 1 || !reflect.DeepEqual(clusters[0].IDs, []string{"a", "b"}) {
+		t.Fatalf("Find at the pair's similarity = %+v, want one cluster of a and b", clusters)
+	}
+	if got := clusters[0].MaxSimilarity(); got != sim {
+		t.Errorf("MaxSimilarity = %v, want %v", got, sim)
+	}
+
+	if clusters := Find(docs, sim+0.01); len(clusters) != 0 {
+		t.Errorf("Find above the pair's similarity = %+v, want no clusters", clusters)
+	}
+}
+
+func TestFindTransitive(t *testing.T) {
+	// a and c only share a third of their words, but both are close to b
+	docs := []Doc{
+		{ID: "a", Text: text(0, 20)},
+		{ID: "b", Text: text(5, 25)},
+		{ID: "c", Text: text(10, 30)},
+	}
+	const threshold = 0.5
+	if Compare(docs[0].Text, docs[1].Text) < threshold || Compare(docs[1].Text, docs[2].Text) < threshold {
+		t.Fatal("neighbouring test texts aren't similar enough")
+	}
+	if Compare(docs[0].Text, docs[2].Text) >= threshold {
+		t.Fatal("a and c are similar on their own")
+	}
+
+	clusters := Find(docs, threshold)
+	if len(clusters) != 1 {
+		t.Fatalf("Find = %+v, want a single cluster", clusters)
+	}
+	if !reflect.DeepEqual(clusters[0].IDs, []string{"a", "b", "c"}) {
+		t.Errorf("cluster IDs = %v, want [a b c]", clusters[0].IDs)
+	}
+	for _, p := range clusters[0].Pairs {
+		if p.Similarity < threshold {
+			t.Errorf("pair %s-%s has similarity %v below the threshold", p.A, p.B, p.Similarity)
+		}
+		if (p.A == "a" && p.B == "c") || (p.A == "c" && p.B == "a") {
+			t.Errorf("cluster pairs a with c, which aren't similar")
+		}
+	}
+}
+
+func TestFindOrderAndEmpty(t *testing.T) {
+	docs := []Doc{
+		{ID: "pair1", Text: text(0, 10)},
+		{ID: "empty1", Text: ""},
+		{ID: "trio1", Text: text(12, 22)},
+		{ID: "pair2", Text: "**" + text(0, 10) + "**"},
+		{ID: "trio2", Text: text(12, 22) + "!"},
+		{ID: "empty2", Text: "<br>"},
+		{ID: "trio3", Text: strings.ToUpper(text(12, 22))},
+	}
+	clusters := Find(docs, 0.9)
+	if len(clusters) != 2 {
+		t.Fatalf("Find = %+v, want two clusters", clusters)
+	}
+	if !reflect.DeepEqual(clusters[0].IDs, []string{"trio1", "trio2", "trio3"}) {
+		t.Errorf("first cluster = %v, want the largest one, [trio1 trio2 trio3]", clusters[0].IDs)
+	}
+	if !reflect.DeepEqual(clusters[1].IDs, []string{"pair1", "pair2"}) {
+		t.Errorf("second cluster = %v, want [pair1 pair2]", clusters[1].IDs)
+	}
+}
+
+func TestKeeper(t *testing.T) {
+	at := func(day int) *models.MochiTime {
+		return &models.MochiTime{Time: time.Date(2025, time.January, day, 0, 0, 0, 0, time.UTC)}
+	}
+	reviews := func(n int) []models.Review {
+		return make([]models.Review, n)
+	}
+
+	tests := []struct {
+		name  string
+		cards []*models.Card
+		want  string
+	}{
+		{
+			name: "most reviews, even if newer",
+			cards: []*models.Card{
+				{ID: "old", CreatedAt: at(1), Reviews: reviews(1)},
+				{ID: "new", CreatedAt: at(9), Reviews: reviews(5)},
+				{ID: "mid", CreatedAt: at(5), Reviews: reviews(2)},
+			},
+			want: "new",
+		},
+		{
+			name: "oldest on equal reviews",
+			cards: []*models.Card{
+				{ID: "b", CreatedAt: at(3), Reviews: reviews(2)},
+				{ID: "c", CreatedAt: at(2), Reviews: reviews(2)},
+				{ID: "a", CreatedAt: at(4), Reviews: reviews(2)},
+			},
+			want: "c",
+		},
+		{
+			name: "creation time unknown sorts last",
+			cards: []*models.Card{
+				{ID: "a"},
+				{ID: "b", CreatedAt: at(9)},
+			},
+			want: "b",
+		},
+		{
+			name: "smallest ID on a tie",
+			cards: []*models.Card{
+				{ID: "zz", CreatedAt: at(1)},
+				{ID: "mm", CreatedAt: at(1)},
+			},
+			want: "mm",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Keeper(tt.cards); got.ID != tt.want {
+				t.Errorf("Keeper kept %s, want %s", got.ID, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package dupes
+
+import (
+	"math"
+
+	"github.com/nerveband/mochi-cli/pkg/mochi/models"
+)
+
+// Keeper picks the card of a duplicate cluster to keep: the one with the
+// most reviews, then the oldest, then the one with the smallest ID
+func Keeper(cards []*models.Card) *models.Card {
+	keep := cards[0]
+	for _, card := range cards[1:] {
+		if len(card.Reviews) != len(keep.Reviews) {
+			if len(card.Reviews) > len(keep.Reviews) {
+				keep = card
+			}
+			continue
+		}
+		if c, k := createdUnix(card), createdUnix(keep); c != k {
+			if c < k {
+				keep = card
+			}
+			continue
+		}
+		if card.ID < keep.ID {
+			keep = card
+		}
+	}
+	return keep
+}
+
+// createdUnix returns when a card was created, for ordering; cards without
+// a creation time sort last
+func createdUnix(card *models.Card) int64 {
+	if card.CreatedAt == nil || card.CreatedAt.IsZero() {
+		return math.MaxInt64
+	}
+	return card.CreatedAt.UnixNano()
+}
@@ -0,0 +1,109 @@
+package dupes
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"math"
+)
+
+// ShingleSize is the number of characters in a shingle
+const ShingleSize = 5
+
+// numHashes is the length of a MinHash signature
+const numHashes = 128
+
+// seeds are the salts of the signature's hash functions
+var seeds [numHashes]uint64
+
+func init() {
+	state := uint64(0x6d6f636869) // fixed, so signatures are reproducible
+	for i := range seeds {
+		state += 0x9e3779b97f4a7c15
+		seeds[i] = mix(state)
+	}
+}
+
+// mix is the splitmix64 finalizer, a fast and well-distributed hash of a
+// 64-bit value
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// Shingles returns the hashes of the overlapping ShingleSize-character
+// pieces of normalized text. Text shorter than a shingle is a single
+// shingle; empty text has none.
+func Shingles(text string) map[uint64]bool {
+	set := map[uint64]bool{}
+	runes := []rune(text)
+	if len(runes) == 0 {
+		return set
+	}
+	if len(runes) <= ShingleSize {
+		set[hashString(text)] = true
+		return set
+	}
+	for i := 0; i+ShingleSize <= len(runes); i++ {
+		set[hashString(string(runes[i:i+ShingleSize]))] = true
+	}
+	return set
+}
+
+// hashString hashes a shingle
+func hashString(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return h.Sum64()
+}
+
+// signature is the MinHash signature of a shingle set: for each hash
+// function, the smallest hash of any shingle. The share of positions two
+// signatures agree on estimates the Jaccard similarity of their sets.
+type signature [numHashes]uint64
+
+// minHash computes the signature of a shingle set
+func minHash(shingles map[uint64]bool) signature {
+	var sig signature
+	for i := range sig {
+		sig[i] = ^uint64(0)
+	}
+	for s := range shingles {
+		for i, seed := range seeds {
+			if h := mix(s ^ seed); h < sig[i] {
+				sig[i] = h
+			}
+		}
+	}
+	return sig
+}
+
+// bandKey hashes the rows of one band of a signature
+func bandKey(sig *signature, band, rows int) uint64 {
+	h := fnv.New64a()
+	var buf [8]byte
+	for _, v := range sig[band*rows : (band+1)*rows] {
+		binary.LittleEndian.PutUint64(buf[:], v)
+		h.Write(buf[:])
+	}
+	return h.Sum64()
+}
+
+// bandRows picks how many signature rows make up a band for locality
+// sensitive hashing. Documents become candidates when one band matches
+// exactly, which happens readily above roughly (1/bands)^(1/rows); the
+// widest bands whose cut-off stays well below threshold are used, so
+// few real duplicates are missed.
+func bandRows(threshold float64) int {
+	best := 1
+	for _, rows := range []int{2, 4, 8} {
+		bands := float64(numHashes / rows)
+		if math.Pow(1/bands, 1/float64(rows)) <= threshold-0.1 {
+			best = rows
+		}
+	}
+	return best
+}
@@ -0,0 +1,38 @@
+package dupes
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+var (
+	// imageRe matches a markdown image, which is dropped
+	imageRe = regexp.MustCompile(`!\[[^\]]*\]\([^)]*\)`)
+	// linkRe matches a markdown link, which is replaced by its text
+	linkRe = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+	// htmlTagRe matches an HTML tag
+	htmlTagRe = regexp.MustCompile(`<[^>]+>`)
+	// clozeRe matches a cloze deletion, {{text}} or {{1::text}}, which is
+	// replaced by its text
+	clozeRe = regexp.MustCompile(`\{\{(?:[^:{}]*::)?([^{}]*)\}\}`)
+)
+
+// Normalize reduces card markdown to the words it shows: images, link
+// targets, HTML tags, cloze markers and punctuation are dropped, letters
+// are lower-cased and runs of whitespace become single spaces. Cards that
+// only differ in formatting normalize to the same text.
+func Normalize(markdown string) string {
+	text := imageRe.ReplaceAllString(markdown, " ")
+	text = linkRe.ReplaceAllString(text, "$1")
+	text = htmlTagRe.ReplaceAllString(text, " ")
+	text = clozeRe.ReplaceAllString(text, "$1")
+
+	text = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return ' '
+	}, text)
+	return strings.Join(strings.Fields(text), " ")
+}
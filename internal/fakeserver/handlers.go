@@ -0,0 +1,459 @@
+package fakeserver
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/nerveband/mochi-cli/pkg/mochi/models"
+)
+
+// === Card Handlers ===
+
+func (s *Server) listCards(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	deckID := r.URL.Query().Get("deck-id")
+	ids := make([]string, 0, len(s.cardOrder))
+	for _, id := range s.cardOrder {
+		if deckID == "" || s.cards[id].DeckID == deckID {
+			ids = append(ids, id)
+		}
+	}
+
+	pageIDs, bookmark, err := page(r, ids)
+	if err != nil {
+		writeErrors(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	docs := make([]models.Card, len(pageIDs))
+	for i, id := range pageIDs {
+		docs[i] = s.cardView(s.cards[id])
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"docs":     docs,
+		"bookmark": bookmark,
+	})
+}
+
+func (s *Server) getCard(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	card, ok := s.cards[r.PathValue("id")]
+	if !ok {
+		writeErrors(w, http.StatusNotFound, "card not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, s.cardView(card))
+}
+
+func (s *Server) createCard(w http.ResponseWriter, r *http.Request) {
+	payload, err := decodePayload(r)
+	if err != nil {
+		writeErrors(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var card models.Card
+	delete(payload, "attachments")
+	if err := merge(&card, payload); err != nil {
+		writeErrors(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.mu.RLock()
+	_, deckExists := s.decks[card.DeckID]
+	s.mu.RUnlock()
+
+	if !deckExists {
+		writeErrors(w, http.StatusBadRequest, "deck-id must reference an existing deck")
+		return
+	}
+
+	card.ID = ""
+	card.CreatedAt = nil
+	card.UpdatedAt = nil
+	card.Reviews = nil
+
+	writeJSON(w, http.StatusOK, s.AddCard(card))
+}
+
+func (s *Server) updateCard(w http.ResponseWriter, r *http.Request) {
+	payload, err := decodePayload(r)
+	if err != nil {
+		writeErrors(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.cards[r.PathValue("id")]
+	if !ok {
+		writeErrors(w, http.StatusNotFound, "card not found")
+		return
+	}
+
+	updated := *existing
+	delete(payload, "id")
+	delete(payload, "attachments")
+	if err := merge(&updated, payload); err != nil {
+		writeErrors(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if _, ok := s.decks[updated.DeckID]; !ok {
+		writeErrors(w, http.StatusBadRequest, "deck-id must reference an existing deck")
+		return
+	}
+
+	updated.UpdatedAt = &models.MochiTime{Time: s.now().UTC()}
+	s.cards[updated.ID] = &updated
+
+	writeJSON(w, http.StatusOK, s.cardView(&updated))
+}
+
+func (s *Server) deleteCard(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := r.PathValue("id")
+	if _, ok := s.cards[id]; !ok {
+		writeErrors(w, http.StatusNotFound, "card not found")
+		return
+	}
+
+	delete(s.cards, id)
+	delete(s.attachments, id)
+	s.cardOrder = removeID(s.cardOrder, id)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// === Attachment Handlers ===
+
+func (s *Server) addAttachment(w http.ResponseWriter, r *http.Request) {
+	cardID := r.PathValue("id")
+	filename := r.PathValue("filename")
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		writeErrors(w, http.StatusBadRequest, "multipart field 'file' is required")
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		writeErrors(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	contentType := header.Header.Get("Content-Type")
+	if contentType == "" || contentType == "application/octet-stream" {
+		contentType = http.DetectContentType(data)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.cards[cardID]; !ok {
+		writeErrors(w, http.StatusNotFound, "card not found")
+		return
+	}
+
+	if s.attachments[cardID] == nil {
+		s.attachments[cardID] = make(map[string]attachment)
+	}
+	s.attachments[cardID][filename] = attachment{ContentType: contentType, Data: data}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) getAttachment(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	a, ok := s.attachments[r.PathValue("id")][r.PathValue("filename")]
+	if !ok {
+		writeErrors(w, http.StatusNotFound, "attachment not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", a.ContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(a.Data)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(a.Data)
+}
+
+func (s *Server) deleteAttachment(w http.ResponseWriter, r *http.Request) {
+	cardID := r.PathValue("id")
+	filename := r.PathValue("filename")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.attachments[cardID][filename]; !ok {
+		writeErrors(w, http.StatusNotFound, "attachment not found")
+		return
+	}
+
+	delete(s.attachments[cardID], filename)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// === Deck Handlers ===
+
+func (s *Server) listDecks(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	pageIDs, bookmark, err := page(r, s.deckOrder)
+	if err != nil {
+		writeErrors(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	docs := make([]models.Deck, len(pageIDs))
+	for i, id := range pageIDs {
+		docs[i] = *s.decks[id]
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"docs":     docs,
+		"bookmark": bookmark,
+	})
+}
+
+func (s *Server) getDeck(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	deck, ok := s.decks[r.PathValue("id")]
+	if !ok {
+		writeErrors(w, http.StatusNotFound, "deck not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, deck)
+}
+
+func (s *Server) createDeck(w http.ResponseWriter, r *http.Request) {
+	payload, err := decodePayload(r)
+	if err != nil {
+		writeErrors(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var deck models.Deck
+	if err := merge(&deck, payload); err != nil {
+		writeErrors(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if deck.Name == "" {
+		writeErrors(w, http.StatusBadRequest, "name is required")
+		return
+	}
+
+	deck.ID = ""
+	writeJSON(w, http.StatusOK, s.AddDeck(deck))
+}
+
+func (s *Server) updateDeck(w http.ResponseWriter, r *http.Request) {
+	payload, err := decodePayload(r)
+	if err != nil {
+		writeErrors(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.decks[r.PathValue("id")]
+	if !ok {
+		writeErrors(w, http.StatusNotFound, "deck not found")
+		return
+	}
+
+	updated := *existing
+	delete(payload, "id")
+	if err := merge(&updated, payload); err != nil {
+		writeErrors(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.decks[updated.ID] = &updated
+
+	writeJSON(w, http.StatusOK, updated)
+}
+
+func (s *Server) deleteDeck(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := r.PathValue("id")
+	if _, ok := s.decks[id]; !ok {
+		writeErrors(w, http.StatusNotFound, "deck not found")
+		return
+	}
+
+	delete(s.decks, id)
+	s.deckOrder = removeID(s.deckOrder, id)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// === Template Handlers ===
+
+func (s *Server) listTemplates(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	pageIDs, bookmark, err := page(r, s.tmplOrder)
+	if err != nil {
+		writeErrors(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	docs := make([]models.Template, len(pageIDs))
+	for i, id := range pageIDs {
+		docs[i] = *s.templates[id]
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"docs":     docs,
+		"bookmark": bookmark,
+	})
+}
+
+func (s *Server) getTemplate(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tmpl, ok := s.templates[r.PathValue("id")]
+	if !ok {
+		writeErrors(w, http.StatusNotFound, "template not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, tmpl)
+}
+
+func (s *Server) createTemplate(w http.ResponseWriter, r *http.Request) {
+	payload, err := decodePayload(r)
+	if err != nil {
+		writeErrors(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var tmpl models.Template
+	if err := merge(&tmpl, payload); err != nil {
+		writeErrors(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if tmpl.Name == "" {
+		writeErrors(w, http.StatusBadRequest, "name is required")
+		return
+	}
+
+	tmpl.ID = ""
+	writeJSON(w, http.StatusOK, s.AddTemplate(tmpl))
+}
+
+func (s *Server) updateTemplate(w http.ResponseWriter, r *http.Request) {
+	payload, err := decodePayload(r)
+	if err != nil {
+		writeErrors(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.templates[r.PathValue("id")]
+	if !ok {
+		writeErrors(w, http.StatusNotFound, "template not found")
+		return
+	}
+
+	updated := *existing
+	delete(payload, "id")
+	if err := merge(&updated, payload); err != nil {
+		writeErrors(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if updated.Name == "" {
+		writeErrors(w, http.StatusBadRequest, "name is required")
+		return
+	}
+
+	s.templates[updated.ID] = &updated
+
+	writeJSON(w, http.StatusOK, updated)
+}
+
+func (s *Server) deleteTemplate(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := r.PathValue("id")
+	if _, ok := s.templates[id]; !ok {
+		writeErrors(w, http.StatusNotFound, "template not found")
+		return
+	}
+
+	delete(s.templates, id)
+	s.tmplOrder = removeID(s.tmplOrder, id)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// === Due Handlers ===
+
+// listDue returns cards whose most recent review is due on or before the
+// requested date (today by default)
+func (s *Server) listDue(w http.ResponseWriter, r *http.Request) {
+	day := s.now().UTC()
+	if v := r.URL.Query().Get("date"); v != "" {
+		parsed, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			writeErrors(w, http.StatusBadRequest, "date must be YYYY-MM-DD")
+			return
+		}
+		day = parsed
+	}
+	cutoff := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, 1)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	deckID := r.PathValue("deck")
+	if deckID != "" {
+		if _, ok := s.decks[deckID]; !ok {
+			writeErrors(w, http.StatusNotFound, "deck not found")
+			return
+		}
+	}
+
+	cards := []models.Card{}
+	for _, id := range s.cardOrder {
+		card := s.cards[id]
+		if deckID != "" && card.DeckID != deckID {
+			continue
+		}
+		if card.Archived || card.Trashed != nil || len(card.Reviews) == 0 {
+			continue
+		}
+		last := card.Reviews[len(card.Reviews)-1]
+		if last.Due != nil && last.Due.Before(cutoff) {
+			cards = append(cards, *card)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, models.DueResponse{Cards: cards})
+}
@@ -0,0 +1,345 @@
+// Package fakeserver provides an in-memory implementation of the Mochi API.
+//
+// It serves the same /cards, /decks, /templates, /due and attachment
+// endpoints as app.mochi.cards, including bookmark pagination, so the CLI and
+// scripts built on it can be exercised fully offline. Mount it with
+// httptest.NewServer or behind `mochi dev fake-server`.
+package fakeserver
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nerveband/mochi-cli/pkg/mochi/models"
+)
+
+const (
+	// DefaultPageSize is the page size used when a list request has no limit
+	DefaultPageSize = 10
+	// MaxPageSize is the largest page size a list request may ask for
+	MaxPageSize = 100
+)
+
+// attachment is a stored attachment file
+type attachment struct {
+	ContentType string
+	Data        []byte
+}
+
+// Server is an in-memory Mochi API. The zero value is not usable; create one
+// with New.
+type Server struct {
+	// APIKey, when set, is required as the basic auth username on every request
+	APIKey string
+
+	mu          sync.RWMutex
+	mux         *http.ServeMux
+	cards       map[string]*models.Card
+	cardOrder   []string
+	decks       map[string]*models.Deck
+	deckOrder   []string
+	templates   map[string]*models.Template
+	tmplOrder   []string
+	attachments map[string]map[string]attachment
+	now         func() time.Time
+}
+
+// New creates an empty fake server
+func New() *Server {
+	s := &Server{
+		mux:         http.NewServeMux(),
+		cards:       make(map[string]*models.Card),
+		decks:       make(map[string]*models.Deck),
+		templates:   make(map[string]*models.Template),
+		attachments: make(map[string]map[string]attachment),
+		now:         time.Now,
+	}
+
+	s.mux.HandleFunc("GET /cards", s.listCards)
+	s.mux.HandleFunc("POST /cards", s.createCard)
+	s.mux.HandleFunc("GET /cards/{id}", s.getCard)
+	s.mux.HandleFunc("POST /cards/{id}", s.updateCard)
+	s.mux.HandleFunc("DELETE /cards/{id}", s.deleteCard)
+	s.mux.HandleFunc("POST /cards/{id}/attachments/{filename}", s.addAttachment)
+	s.mux.HandleFunc("GET /cards/{id}/attachments/{filename}", s.getAttachment)
+	s.mux.HandleFunc("DELETE /cards/{id}/attachments/{filename}", s.deleteAttachment)
+
+	s.mux.HandleFunc("GET /decks", s.listDecks)
+	s.mux.HandleFunc("POST /decks", s.createDeck)
+	s.mux.HandleFunc("GET /decks/{id}", s.getDeck)
+	s.mux.HandleFunc("POST /decks/{id}", s.updateDeck)
+	s.mux.HandleFunc("DELETE /decks/{id}", s.deleteDeck)
+
+	s.mux.HandleFunc("GET /templates", s.listTemplates)
+	s.mux.HandleFunc("POST /templates", s.createTemplate)
+	s.mux.HandleFunc("GET /templates/{id}", s.getTemplate)
+	s.mux.HandleFunc("POST /templates/{id}", s.updateTemplate)
+	s.mux.HandleFunc("DELETE /templates/{id}", s.deleteTemplate)
+
+	s.mux.HandleFunc("GET /due", s.listDue)
+	s.mux.HandleFunc("GET /due/{deck}", s.listDue)
+
+	return s
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.APIKey != "" {
+		user, _, ok := r.BasicAuth()
+		if !ok || user != s.APIKey {
+			writeErrors(w, http.StatusUnauthorized, "invalid API key")
+			return
+		}
+	}
+
+	s.mux.ServeHTTP(w, r)
+}
+
+// Seed populates the server with a small demo collection and returns it
+func (s *Server) Seed() *Server {
+	spanish := s.AddDeck(models.Deck{Name: "Spanish"})
+	verbs := s.AddDeck(models.Deck{Name: "Verbs", ParentID: spanish.ID})
+	tmpl := s.AddTemplate(models.Template{
+		Name:    "Basic",
+		Content: "# << Front >>\n---\n<< Back >>",
+		Fields: map[string]models.TemplateField{
+			"name": {ID: "name", Name: "Front", Pos: "a"},
+			"back": {ID: "back", Name: "Back", Pos: "b"},
+		},
+	})
+
+	hola := s.AddCard(models.Card{DeckID: spanish.ID, Name: "hola", Content: "hola\n\n![wave](@media/wave.png)\n---\nhello", ManualTags: []string{"greetings"}})
+	s.AddAttachment(hola.ID, "wave.png", "image/png", samplePNG())
+	s.AddCard(models.Card{DeckID: spanish.ID, Name: "adiós", Content: "adiós\n---\ngoodbye", ManualTags: []string{"greetings"}})
+	s.AddCard(models.Card{DeckID: verbs.ID, Name: "ser", Content: "ser\n---\nto be (permanent)", ManualTags: []string{"verbs", "irregular"}})
+	s.AddCard(models.Card{DeckID: verbs.ID, Name: "estar", Content: "estar\n---\nto be (temporary)", ManualTags: []string{"verbs", "irregular"}})
+	s.AddCard(models.Card{
+		DeckID:     verbs.ID,
+		TemplateID: tmpl.ID,
+		Content:    "",
+		Fields: map[string]models.Field{
+			"name": {ID: "name", Value: "hablar"},
+			"back": {ID: "back", Value: "to speak"},
+		},
+		ManualTags: []string{"verbs"},
+	})
+
+	return s
+}
+
+// AddDeck stores a deck directly, assigning an ID if it has none
+func (s *Server) AddDeck(deck models.Deck) *models.Deck {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if deck.ID == "" {
+		deck.ID = newID()
+	}
+	if _, ok := s.decks[deck.ID]; !ok {
+		s.deckOrder = append(s.deckOrder, deck.ID)
+	}
+	s.decks[deck.ID] = &deck
+	return &deck
+}
+
+// AddCard stores a card directly, assigning an ID and timestamps as needed
+func (s *Server) AddCard(card models.Card) *models.Card {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if card.ID == "" {
+		card.ID = newID()
+	}
+	now := &models.MochiTime{Time: s.now().UTC()}
+	if card.CreatedAt == nil {
+		card.CreatedAt = now
+	}
+	if card.UpdatedAt == nil {
+		card.UpdatedAt = now
+	}
+	if card.Reviews == nil {
+		card.New = true
+	}
+	if _, ok := s.cards[card.ID]; !ok {
+		s.cardOrder = append(s.cardOrder, card.ID)
+	}
+	s.cards[card.ID] = &card
+	return &card
+}
+
+// AddAttachment stores an attachment on a card directly
+func (s *Server) AddAttachment(cardID, filename, contentType string, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.attachments[cardID] == nil {
+		s.attachments[cardID] = make(map[string]attachment)
+	}
+	s.attachments[cardID][filename] = attachment{ContentType: contentType, Data: data}
+}
+
+// AddTemplate stores a template directly, assigning an ID if it has none
+func (s *Server) AddTemplate(tmpl models.Template) *models.Template {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if tmpl.ID == "" {
+		tmpl.ID = newID()
+	}
+	if _, ok := s.templates[tmpl.ID]; !ok {
+		s.tmplOrder = append(s.tmplOrder, tmpl.ID)
+	}
+	s.templates[tmpl.ID] = &tmpl
+	return &tmpl
+}
+
+// === Helpers ===
+
+// cardView returns a card as the API serves it, with its attachments listed.
+// The caller must hold s.mu.
+func (s *Server) cardView(card *models.Card) models.Card {
+	view := *card
+	view.Attachments = nil
+	for name, a := range s.attachments[card.ID] {
+		if view.Attachments == nil {
+			view.Attachments = make(map[string]models.Attachment)
+		}
+		view.Attachments[name] = models.Attachment{ContentType: a.ContentType, Size: int64(len(a.Data))}
+	}
+	return view
+}
+
+// samplePNG returns a small generated image for the seeded collection
+func samplePNG() []byte {
+	img := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x * 16), G: uint8(y * 16), B: 160, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	_ = png.Encode(&buf, img)
+	return buf.Bytes()
+}
+
+// writeJSON writes v as a JSON response
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeErrors writes an error response in the shape the Mochi API uses
+func writeErrors(w http.ResponseWriter, status int, msgs ...string) {
+	writeJSON(w, status, models.ErrorResponse{Errors: msgs})
+}
+
+// decodePayload reads a JSON object request body
+func decodePayload(r *http.Request) (map[string]interface{}, error) {
+	var payload map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		return nil, fmt.Errorf("invalid JSON body: %w", err)
+	}
+	return payload, nil
+}
+
+// merge overlays the keys of payload onto the JSON representation of dst.
+// Keys set to null clear the corresponding field.
+func merge(dst interface{}, payload map[string]interface{}) error {
+	data, err := json.Marshal(dst)
+	if err != nil {
+		return err
+	}
+
+	var current map[string]interface{}
+	if err := json.Unmarshal(data, &current); err != nil {
+		return err
+	}
+	for k, v := range payload {
+		if v == nil {
+			delete(current, k)
+		} else {
+			current[k] = v
+		}
+	}
+
+	data, err = json.Marshal(current)
+	if err != nil {
+		return err
+	}
+	v := reflect.ValueOf(dst).Elem()
+	v.Set(reflect.Zero(v.Type()))
+	return json.Unmarshal(data, dst)
+}
+
+// page slices ordered IDs according to the limit and bookmark query params
+func page(r *http.Request, ids []string) ([]string, string, error) {
+	limit := DefaultPageSize
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return nil, "", fmt.Errorf("invalid limit: %s", v)
+		}
+		limit = n
+	}
+	if limit > MaxPageSize {
+		limit = MaxPageSize
+	}
+
+	offset := 0
+	if bm := r.URL.Query().Get("bookmark"); bm != "" {
+		raw, err := base64.RawURLEncoding.DecodeString(bm)
+		if err != nil || !strings.HasPrefix(string(raw), "offset:") {
+			return nil, "", fmt.Errorf("invalid bookmark: %s", bm)
+		}
+		offset, err = strconv.Atoi(strings.TrimPrefix(string(raw), "offset:"))
+		if err != nil || offset < 0 {
+			return nil, "", fmt.Errorf("invalid bookmark: %s", bm)
+		}
+	}
+
+	if offset >= len(ids) {
+		return nil, "", nil
+	}
+
+	end := offset + limit
+	if end >= len(ids) {
+		return ids[offset:], "", nil
+	}
+
+	next := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("offset:%d", end)))
+	return ids[offset:end], next, nil
+}
+
+// newID generates an 8-character alphanumeric ID like Mochi's
+func newID() string {
+	const charset = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	for i := range b {
+		b[i] = charset[int(b[i])%len(charset)]
+	}
+	return string(b)
+}
+
+// removeID removes id from an ordered ID slice
+func removeID(ids []string, id string) []string {
+	for i, v := range ids {
+		if v == id {
+			return append(ids[:i], ids[i+1:]...)
+		}
+	}
+	return ids
+}
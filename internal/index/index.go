@@ -0,0 +1,224 @@
+// Package index keeps a local full-text index of a profile's cards, so
+// card search can rank results without downloading the collection. Cards
+// are tokenized, stemmed and scored with BM25; the index is refreshed
+// incrementally by re-indexing only cards whose updated-at changed.
+package index
+
+import (
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nerveband/mochi-cli/pkg/mochi/models"
+)
+
+// Version is the on-disk format version; indexes of other versions must be
+// rebuilt
+const Version = 1
+
+// ErrNotFound is returned by Load when no index exists
+var ErrNotFound = errors.New("no search index")
+
+// Index is an inverted index of cards
+type Index struct {
+	Version int
+	// BaseURL is the API the cards came from, and KeyFingerprint identifies
+	// the API key that read them, so one account never searches another's
+	// cards
+	BaseURL        string
+	KeyFingerprint string
+	CreatedAt      time.Time
+	RefreshedAt    time.Time
+	// Docs holds the indexed cards by ID
+	Docs map[string]*Doc
+	// Postings lists the cards containing each term
+	Postings map[string][]Posting
+	// TotalLen is the sum of all document lengths, for BM25
+	TotalLen int
+	// DeckPaths and TemplateNames let queries refer to decks and templates
+	// by name without asking the API
+	DeckPaths     map[string]string
+	TemplateNames map[string]string
+
+	sets map[string]map[string]bool // posting sets built for ContainsWord
+}
+
+// Doc is an indexed card
+type Doc struct {
+	Card models.Card
+	// Len is the number of terms in the card
+	Len int
+}
+
+// Posting is a card containing a term, with the term's frequency
+type Posting struct {
+	CardID string
+	TF     int
+}
+
+// New returns an empty index of the cards an API key reads from an API
+func New(baseURL, keyFingerprint string) *Index {
+	now := time.Now()
+	return &Index{
+		Version:        Version,
+		BaseURL:        baseURL,
+		KeyFingerprint: keyFingerprint,
+		CreatedAt:      now,
+		Docs:           map[string]*Doc{},
+		Postings:       map[string][]Posting{},
+		DeckPaths:      map[string]string{},
+		TemplateNames:  map[string]string{},
+	}
+}
+
+// Load reads an index from path. It returns ErrNotFound when there is none.
+func Load(path string) (*Index, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var ix Index
+	if err := gob.NewDecoder(f).Decode(&ix); err != nil {
+		return nil, fmt.Errorf("search index %s is unreadable (rebuild it with mochi index rebuild --full): %w", path, err)
+	}
+	if ix.Version != Version {
+		return nil, fmt.Errorf("search index %s has format version %d, expected %d (rebuild it with mochi index rebuild --full)", path, ix.Version, Version)
+	}
+	return &ix, nil
+}
+
+// Fingerprint returns a short, irreversible identifier of an API key
+func Fingerprint(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Belongs reports whether the index holds the cards an API key reads from
+// an API
+func (ix *Index) Belongs(baseURL, keyFingerprint string) bool {
+	return ix.BaseURL == baseURL && ix.KeyFingerprint == keyFingerprint
+}
+
+// Save writes the index to path through a temporary file, so readers never
+// see a partial index
+func (ix *Index) Save(path string) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, ".index-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := gob.NewEncoder(tmp).Encode(ix); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Unchanged reports whether the index holds the card as it is now
+func (ix *Index) Unchanged(card *models.Card) bool {
+	doc, ok := ix.Docs[card.ID]
+	return ok && sameTime(doc.Card.UpdatedAt, card.UpdatedAt)
+}
+
+// Put adds a card to the index, replacing an older version of it
+func (ix *Index) Put(card models.Card) {
+	ix.Remove(card.ID)
+
+	counts := map[string]int{}
+	n := 0
+	for _, t := range tokenize(documentText(&card)) {
+		counts[t.term]++
+		n++
+	}
+	for term, tf := range counts {
+		ix.Postings[term] = append(ix.Postings[term], Posting{CardID: card.ID, TF: tf})
+	}
+
+	ix.Docs[card.ID] = &Doc{Card: card, Len: n}
+	ix.TotalLen += n
+	ix.sets = nil
+}
+
+// Remove drops a card from the index
+func (ix *Index) Remove(id string) {
+	doc, ok := ix.Docs[id]
+	if !ok {
+		return
+	}
+
+	for _, term := range Terms(documentText(&doc.Card)) {
+		postings := ix.Postings[term]
+		for i, p := range postings {
+			if p.CardID == id {
+				postings = append(postings[:i], postings[i+1:]...)
+				break
+			}
+		}
+		if len(postings) == 0 {
+			delete(ix.Postings, term)
+		} else {
+			ix.Postings[term] = postings
+		}
+	}
+
+	ix.TotalLen -= doc.Len
+	delete(ix.Docs, id)
+	ix.sets = nil
+}
+
+// IDs returns the IDs of the indexed cards
+func (ix *Index) IDs() []string {
+	ids := make([]string, 0, len(ix.Docs))
+	for id := range ix.Docs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// documentText is the text of a card that gets indexed. The name counts
+// twice, so cards named after a word rank above cards mentioning it.
+func documentText(card *models.Card) string {
+	parts := []string{card.Name, card.Name, cleanText(card.Content)}
+
+	ids := make([]string, 0, len(card.Fields))
+	for id := range card.Fields {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		parts = append(parts, cleanText(card.Fields[id].Value))
+	}
+
+	parts = append(parts, card.ManualTags...)
+	return strings.Join(parts, "\n")
+}
+
+// sameTime reports whether two optional times are equal
+func sameTime(a, b *models.MochiTime) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Equal(b.Time)
+}
@@ -0,0 +1,241 @@
+package index
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/nerveband/mochi-cli/pkg/mochi/models"
+)
+
+func TestStem(t *testing.T) {
+	// Examples from Porter's paper and its reference vocabulary
+	tests := map[string]string{
+		"caresses": "caress", "ponies": "poni", "ties": "ti", "caress": "caress",
+		"cats": "cat", "feed": "feed", "agreed": "agre", "plastered": "plaster",
+		"bled": "bled", "motoring": "motor", "sing": "sing", "conflated": "conflat",
+		"troubled": "troubl", "sized": "size", "hopping": "hop", "tanned": "tan",
+		"falling": "fall", "hissing": "hiss", "fizzed": "fizz", "failing": "fail",
+		"filing": "file", "happy": "happi", "sky": "sky", "relational": "relat",
+		"conditional": "condit", "rational": "ration", "digitizer": "digit",
+		"operator": "oper", "feudalism": "feudal", "decisiveness": "decis",
+		"hopefulness": "hope", "callousness": "callous", "triplicate": "triplic",
+		"formative": "form", "formalize": "formal", "electrical": "electr",
+		"hopeful": "hope", "goodness": "good", "revival": "reviv",
+		"allowance": "allow", "inference": "infer", "airliner": "airlin",
+		"gyroscopic": "gyroscop", "adjustable": "adjust", "defensible": "defens",
+		"irritant": "irrit", "replacement": "replac", "adjustment": "adjust",
+		"dependent": "depend", "adoption": "adopt", "communism": "commun",
+		"activate": "activ", "effective": "effect", "bowdlerize": "bowdler",
+		"probate": "probat", "rate": "rate", "cease": "ceas", "controlling": "control",
+		"roll": "roll", "generalizations": "gener", "oscillators": "oscil",
+		"connection": "connect", "connections": "connect", "connected": "connect",
+		"connecting": "connect", "verbs": "verb",
+		// Short and non-ASCII words are left alone
+		"is": "is", "añejo": "añejo", "mp3": "mp3",
+	}
+	for word, want := range tests {
+		if got := Stem(word); got != want {
+			t.Errorf("Stem(%q) = %q, want %q", word, got, want)
+		}
+	}
+}
+
+// card returns a card for the index tests
+func card(id, name, content string, updated int) models.Card {
+	at := &models.MochiTime{Time: time.Date(2024, 1, 1, 0, 0, updated, 0, time.UTC)}
+	return models.Card{ID: id, Name: name, Content: content, UpdatedAt: at}
+}
+
+// sortedPostings returns the postings of ix in a comparable order
+func sortedPostings(ix *Index) map[string][]Posting {
+	out := map[string][]Posting{}
+	for term, postings := range ix.Postings {
+		ps := append([]Posting(nil), postings...)
+		sort.Slice(ps, func(i, j int) bool { return ps[i].CardID < ps[j].CardID })
+		out[term] = ps
+	}
+	return out
+}
+
+// sameIndex fails the test unless got holds what want holds
+func sameIndex(t *testing.T, step string, got, want *Index) {
+	t.Helper()
+	if !reflect.DeepEqual(sortedPostings(got), sortedPostings(want)) {
+		t.Errorf("%s: postings = %v, want %v", step, sortedPostings(got), sortedPostings(want))
+	}
+	if got.TotalLen != want.TotalLen {
+		t.Errorf("%s: TotalLen = %d, want %d", step, got.TotalLen, want.TotalLen)
+	}
+	if !reflect.DeepEqual(got.IDs(), want.IDs()) {
+		t.Errorf("%s: IDs = %v, want %v", step, got.IDs(), want.IDs())
+	}
+}
+
+func TestPutAndRemove(t *testing.T) {
+	old := card("a", "hablar", "to speak, to talk", 1)
+	updated := card("a", "hablar", "to speak ![x](@media/speak.png)", 2)
+	updated.ManualTags = []string{"verbs"}
+	other := card("b", "comer", "to eat; hablar is another verb", 1)
+
+	ix := New("", "")
+	ix.Put(old)
+	ix.Put(other)
+	ix.Put(updated)
+
+	fresh := New("", "")
+	fresh.Put(other)
+	fresh.Put(updated)
+	sameIndex(t, "after Put of a new version", ix, fresh)
+
+	ix.Put(updated)
+	sameIndex(t, "after Put of the same version", ix, fresh)
+
+	ix.Remove("a")
+	fresh = New("", "")
+	fresh.Put(other)
+	sameIndex(t, "after Remove", ix, fresh)
+
+	ix.Remove("missing")
+	sameIndex(t, "after Remove of a missing card", ix, fresh)
+
+	ix.Remove("b")
+	if len(ix.Postings) != 0 || ix.TotalLen != 0 || len(ix.Docs) != 0 {
+		t.Errorf("empty index holds postings %v, TotalLen %d", ix.Postings, ix.TotalLen)
+	}
+}
+
+// resultIDs returns the card IDs of search results in order
+func resultIDs(results []Result) []string {
+	ids := make([]string, len(results))
+	for i, r := range results {
+		ids[i] = r.Card.ID
+	}
+	return ids
+}
+
+func TestSearchRanking(t *testing.T) {
+	ix := New("", "")
+	for _, c := range []models.Card{
+		card("mention", "", "comer is a verb, and so is hablar", 5),
+		card("named", "hablar", "to speak, a verb", 1),
+		card("rare", "", "verb for a casa, or house", 2),
+		card("common1", "", "a verb one", 3),
+		card("common2", "", "a verb two", 4),
+		card("unrelated", "", "la playa", 6),
+	} {
+		ix.Put(c)
+	}
+
+	got := resultIDs(ix.Search(Terms("hablar"), nil))
+	if got[0] != "named" || got[1] != "mention" {
+		t.Errorf("search for hablar = %v, want the card named hablar first", got)
+	}
+
+	// casa is on one card, verb on five, so casa decides the order
+	got = resultIDs(ix.Search(Terms("verbs casa"), nil))
+	if got[0] != "rare" {
+		t.Errorf("search for verbs casa = %v, want the card with the rare term first", got)
+	}
+	// Cards without a match come last, most recently updated first
+	if got[len(got)-1] != "unrelated" {
+		t.Errorf("search for verbs casa = %v, want the unrelated card last", got)
+	}
+
+	keep := func(c *models.Card) bool { return c.ID != "named" }
+	if got := resultIDs(ix.Search(Terms("hablar"), keep)); got[0] != "mention" || len(got) != 5 {
+		t.Errorf("filtered search = %v, want the named card left out", got)
+	}
+
+	if got := resultIDs(ix.Search(nil, nil)); !reflect.DeepEqual(got,
+		[]string{"unrelated", "mention", "common2", "common1", "rare", "named"}) {
+		t.Errorf("search without terms = %v, want cards by most recent update", got)
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	// Words of two- and three-byte runes, so byte offsets fall inside them
+	words := strings.Fields(strings.Repeat("añejo café über 東京 naïve ", 20) + "target " +
+		strings.Repeat("crème brûlée déjà vu ", 20))
+	text := strings.Join(words, "  ")
+	known := map[string]bool{}
+	for _, w := range words {
+		known[w] = true
+	}
+	mark := func(w string) string { return "[" + w + "]" }
+
+	for width := 10; width <= 90; width += 7 {
+		got := Snippet(text, Terms("target"), width, mark)
+		if !utf8.ValidString(got) {
+			t.Fatalf("width %d: snippet %q splits a character", width, got)
+		}
+		if !strings.HasPrefix(got, "…") || !strings.HasSuffix(got, "…") {
+			t.Errorf("width %d: snippet %q lacks ellipses around a match mid-text", width, got)
+		}
+		if !strings.Contains(got, "[target]") {
+			t.Errorf("width %d: snippet %q lacks the marked match", width, got)
+		}
+		// width, plus the ellipses and the brackets of the mark
+		if n := utf8.RuneCountInString(got); n > width+4 {
+			t.Errorf("width %d: snippet %q is %d characters", width, got, n)
+		}
+		// Every word is whole: cut at spaces, never inside a word
+		body := strings.Trim(got, "…")
+		body = strings.NewReplacer("[", "", "]", "").Replace(body)
+		for _, w := range strings.Fields(body) {
+			if !known[w] {
+				t.Errorf("width %d: snippet %q has the partial word %q", width, got, w)
+			}
+		}
+	}
+
+	// No match gives the start of the text
+	if got := Snippet("déjà vu again and again", []string{"missing"}, 10, mark); got != "déjà vu…" {
+		t.Errorf("snippet without a match = %q, want %q", got, "déjà vu…")
+	}
+	// Short texts are kept whole, with link targets dropped
+	if got := Snippet("see ![the map](@media/map.png) of España", Terms("espana"), 80, mark); got != "see ![the map] of [España]" {
+		t.Errorf("short snippet = %q", got)
+	}
+}
+
+func TestContainsWord(t *testing.T) {
+	ix := New("", "")
+	other := card("other", "", "The other things", 1)
+	hola := card("hola", "hola", "hello there", 1)
+	ix.Put(other)
+	ix.Put(hola)
+
+	tests := []struct {
+		card *models.Card
+		word string
+		want bool
+	}{
+		// Stop words only: a substring of the name or content
+		{&other, "the", true},
+		{&other, "THE", true},
+		{&hola, "the", true},
+		{&hola, "it is", false},
+		{&other, "it", false},
+		// Otherwise every term must be indexed for the card
+		{&other, "thing", true},
+		{&other, "things other", true},
+		{&other, "other hello", false},
+		{&hola, "hola hello", true},
+		{&hola, "hol", false},
+	}
+	for _, tt := range tests {
+		if got := ix.ContainsWord(tt.card, tt.word); got != tt.want {
+			t.Errorf("ContainsWord(%s, %q) = %v, want %v", tt.card.ID, tt.word, got, tt.want)
+		}
+	}
+
+	// The cached posting sets follow changes to the index
+	ix.Remove("other")
+	if ix.ContainsWord(&other, "thing") {
+		t.Error("ContainsWord matches a removed card")
+	}
+}
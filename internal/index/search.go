@@ -0,0 +1,106 @@
+package index
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/nerveband/mochi-cli/pkg/mochi/models"
+)
+
+// BM25 parameters: k1 limits how much repeating a term helps, b how much
+// long cards are penalized
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// Result is a card found by Search
+type Result struct {
+	Card  *models.Card
+	Score float64
+}
+
+// Search scores the cards keep accepts against the terms with BM25 and
+// returns them best first. Cards scoring 0, such as those matched only by a
+// filter, follow in order of most recent update. keep may be nil.
+func (ix *Index) Search(terms []string, keep func(card *models.Card) bool) []Result {
+	scores := map[string]float64{}
+	if len(ix.Docs) > 0 {
+		n := float64(len(ix.Docs))
+		avgLen := float64(ix.TotalLen) / n
+		if avgLen == 0 {
+			avgLen = 1
+		}
+		for _, term := range terms {
+			postings := ix.Postings[term]
+			if len(postings) == 0 {
+				continue
+			}
+			df := float64(len(postings))
+			idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+			for _, p := range postings {
+				tf := float64(p.TF)
+				norm := 1 - bm25B + bm25B*float64(ix.Docs[p.CardID].Len)/avgLen
+				scores[p.CardID] += idf * tf * (bm25K1 + 1) / (tf + bm25K1*norm)
+			}
+		}
+	}
+
+	var results []Result
+	for id, doc := range ix.Docs {
+		if keep != nil && !keep(&doc.Card) {
+			continue
+		}
+		results = append(results, Result{Card: &doc.Card, Score: scores[id]})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if ua, ub := updatedUnix(a.Card), updatedUnix(b.Card); ua != ub {
+			return ua > ub
+		}
+		return a.Card.ID < b.Card.ID
+	})
+	return results
+}
+
+// ContainsWord reports whether a card has every term of word. Words made
+// only of stop words fall back to a plain substring match.
+func (ix *Index) ContainsWord(card *models.Card, word string) bool {
+	terms := Terms(word)
+	if len(terms) == 0 {
+		word = strings.ToLower(word)
+		return strings.Contains(strings.ToLower(card.Name), word) ||
+			strings.Contains(strings.ToLower(card.Content), word)
+	}
+
+	if ix.sets == nil {
+		ix.sets = map[string]map[string]bool{}
+	}
+	for _, term := range terms {
+		set, ok := ix.sets[term]
+		if !ok {
+			set = make(map[string]bool, len(ix.Postings[term]))
+			for _, p := range ix.Postings[term] {
+				set[p.CardID] = true
+			}
+			ix.sets[term] = set
+		}
+		if !set[card.ID] {
+			return false
+		}
+	}
+	return true
+}
+
+// updatedUnix returns when a card was last updated, for ordering
+func updatedUnix(card *models.Card) int64 {
+	if card.UpdatedAt == nil {
+		return 0
+	}
+	return card.UpdatedAt.UnixNano()
+}
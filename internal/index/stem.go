@@ -0,0 +1,227 @@
+package index
+
+// Stem reduces an English word to its stem with the Porter stemming
+// algorithm, so "verbs", "verbal" and "verb" index alike. Words that aren't
+// plain lower-case ASCII are returned unchanged.
+func Stem(word string) string {
+	if len(word) <= 2 {
+		return word
+	}
+	for i := 0; i < len(word); i++ {
+		if word[i] < 'a' || word[i] > 'z' {
+			return word
+		}
+	}
+
+	b := []byte(word)
+	b = step1a(b)
+	b = step1b(b)
+	b = step1c(b)
+	b = step2(b)
+	b = step3(b)
+	b = step4(b)
+	b = step5(b)
+	return string(b)
+}
+
+// isCons reports whether b[i] is a consonant. Y is a consonant at the start
+// of a word and after a vowel.
+func isCons(b []byte, i int) bool {
+	switch b[i] {
+	case 'a', 'e', 'i', 'o', 'u':
+		return false
+	case 'y':
+		return i == 0 || !isCons(b, i-1)
+	}
+	return true
+}
+
+// measure counts the vowel-consonant sequences in b, the m of [C](VC)^m[V]
+func measure(b []byte) int {
+	n, i := 0, 0
+	for i < len(b) && isCons(b, i) {
+		i++
+	}
+	for i < len(b) {
+		for i < len(b) && !isCons(b, i) {
+			i++
+		}
+		if i >= len(b) {
+			break
+		}
+		for i < len(b) && isCons(b, i) {
+			i++
+		}
+		n++
+	}
+	return n
+}
+
+// hasVowel reports whether b contains a vowel
+func hasVowel(b []byte) bool {
+	for i := range b {
+		if !isCons(b, i) {
+			return true
+		}
+	}
+	return false
+}
+
+// endsDouble reports whether b ends with a double consonant
+func endsDouble(b []byte) bool {
+	l := len(b)
+	return l >= 2 && b[l-1] == b[l-2] && isCons(b, l-1)
+}
+
+// endsCVC reports whether b ends consonant-vowel-consonant, where the last
+// consonant isn't w, x or y (as in "hop" but not "snow")
+func endsCVC(b []byte) bool {
+	l := len(b)
+	if l < 3 || !isCons(b, l-3) || isCons(b, l-2) || !isCons(b, l-1) {
+		return false
+	}
+	c := b[l-1]
+	return c != 'w' && c != 'x' && c != 'y'
+}
+
+// hasSuffix reports whether b ends with s
+func hasSuffix(b []byte, s string) bool {
+	return len(b) >= len(s) && string(b[len(b)-len(s):]) == s
+}
+
+// replace swaps the suffix of b for to
+func replace(b []byte, suffix, to string) []byte {
+	return append(b[:len(b)-len(suffix)], to...)
+}
+
+// rule is a suffix replacement of steps 2 to 4
+type rule struct {
+	suffix, to string
+}
+
+// applyRules applies the rule for the longest matching suffix if the stem
+// before it measures more than minM. Rules are listed so longer suffixes
+// come before the shorter ones they end with.
+func applyRules(b []byte, rules []rule, minM int) []byte {
+	for _, r := range rules {
+		if hasSuffix(b, r.suffix) {
+			if measure(b[:len(b)-len(r.suffix)]) > minM {
+				return replace(b, r.suffix, r.to)
+			}
+			return b
+		}
+	}
+	return b
+}
+
+// step1a handles plurals
+func step1a(b []byte) []byte {
+	switch {
+	case hasSuffix(b, "sses"):
+		return replace(b, "sses", "ss")
+	case hasSuffix(b, "ies"):
+		return replace(b, "ies", "i")
+	case hasSuffix(b, "ss"):
+		return b
+	case hasSuffix(b, "s"):
+		return b[:len(b)-1]
+	}
+	return b
+}
+
+// step1b handles -ed and -ing
+func step1b(b []byte) []byte {
+	if hasSuffix(b, "eed") {
+		if measure(b[:len(b)-3]) > 0 {
+			return b[:len(b)-1]
+		}
+		return b
+	}
+
+	var stem []byte
+	switch {
+	case hasSuffix(b, "ed") && hasVowel(b[:len(b)-2]):
+		stem = b[:len(b)-2]
+	case hasSuffix(b, "ing") && hasVowel(b[:len(b)-3]):
+		stem = b[:len(b)-3]
+	default:
+		return b
+	}
+
+	switch {
+	case hasSuffix(stem, "at"), hasSuffix(stem, "bl"), hasSuffix(stem, "iz"):
+		return append(stem, 'e')
+	case endsDouble(stem):
+		if c := stem[len(stem)-1]; c != 'l' && c != 's' && c != 'z' {
+			return stem[:len(stem)-1]
+		}
+	case measure(stem) == 1 && endsCVC(stem):
+		return append(stem, 'e')
+	}
+	return stem
+}
+
+// step1c turns a final y into i when the stem has a vowel
+func step1c(b []byte) []byte {
+	if hasSuffix(b, "y") && hasVowel(b[:len(b)-1]) {
+		b[len(b)-1] = 'i'
+	}
+	return b
+}
+
+var step2Rules = []rule{
+	{"ational", "ate"}, {"tional", "tion"}, {"enci", "ence"}, {"anci", "ance"},
+	{"izer", "ize"}, {"abli", "able"}, {"alli", "al"}, {"entli", "ent"},
+	{"eli", "e"}, {"ousli", "ous"}, {"ization", "ize"}, {"ation", "ate"},
+	{"ator", "ate"}, {"alism", "al"}, {"iveness", "ive"}, {"fulness", "ful"},
+	{"ousness", "ous"}, {"aliti", "al"}, {"iviti", "ive"}, {"biliti", "ble"},
+}
+
+// step2 maps double suffixes to single ones
+func step2(b []byte) []byte {
+	return applyRules(b, step2Rules, 0)
+}
+
+var step3Rules = []rule{
+	{"icate", "ic"}, {"ative", ""}, {"alize", "al"}, {"iciti", "ic"},
+	{"ical", "ic"}, {"ful", ""}, {"ness", ""},
+}
+
+// step3 handles -ic-, -full, -ness and the like
+func step3(b []byte) []byte {
+	return applyRules(b, step3Rules, 0)
+}
+
+var step4Rules = []rule{
+	{"al", ""}, {"ance", ""}, {"ence", ""}, {"er", ""}, {"ic", ""},
+	{"able", ""}, {"ible", ""}, {"ant", ""}, {"ement", ""}, {"ment", ""},
+	{"ent", ""}, {"ou", ""}, {"ism", ""}, {"ate", ""}, {"iti", ""},
+	{"ous", ""}, {"ive", ""}, {"ize", ""},
+}
+
+// step4 removes suffixes from stems long enough to keep their meaning
+func step4(b []byte) []byte {
+	// -ion only goes after s or t
+	if hasSuffix(b, "ion") {
+		stem := b[:len(b)-3]
+		if measure(stem) > 1 && (hasSuffix(stem, "s") || hasSuffix(stem, "t")) {
+			return stem
+		}
+		return b
+	}
+	return applyRules(b, step4Rules, 1)
+}
+
+// step5 removes a final -e and reduces a final -ll
+func step5(b []byte) []byte {
+	if hasSuffix(b, "e") {
+		stem := b[:len(b)-1]
+		if m := measure(stem); m > 1 || (m == 1 && !endsCVC(stem)) {
+			b = stem
+		}
+	}
+	if measure(b) > 1 && endsDouble(b) && hasSuffix(b, "l") {
+		b = b[:len(b)-1]
+	}
+	return b
+}
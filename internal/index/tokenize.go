@@ -0,0 +1,156 @@
+package index
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// token is a term and where its word sits in the text
+type token struct {
+	term       string
+	start, end int
+}
+
+// folder strips common diacritics, so "adios" finds "adiós"
+var folder = strings.NewReplacer(
+	"á", "a", "à", "a", "â", "a", "ä", "a", "ã", "a", "å", "a",
+	"é", "e", "è", "e", "ê", "e", "ë", "e",
+	"í", "i", "ì", "i", "î", "i", "ï", "i",
+	"ó", "o", "ò", "o", "ô", "o", "ö", "o", "õ", "o", "ø", "o",
+	"ú", "u", "ù", "u", "û", "u", "ü", "u",
+	"ñ", "n", "ç", "c", "ý", "y", "ÿ", "y",
+	"ß", "ss", "æ", "ae", "œ", "oe",
+)
+
+// stopWords are common English words left out of the index
+var stopWords = map[string]bool{}
+
+func init() {
+	for _, w := range strings.Fields(`a an and are as at be but by for from has have
+		if in into is it its no not of on or such that the their then there these
+		they this to was were will with`) {
+		stopWords[w] = true
+	}
+}
+
+// linkTargetRe matches the target of a markdown link or image, which says
+// nothing about the card's subject
+var linkTargetRe = regexp.MustCompile(`\]\([^)]*\)`)
+
+// cleanText prepares card markdown for indexing and snippets: link targets
+// are dropped and runs of whitespace become single spaces
+func cleanText(text string) string {
+	text = linkTargetRe.ReplaceAllString(text, "]")
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// normalize turns a word into its index term: lower case, without
+// diacritics and stemmed. Stop words give "".
+func normalize(word string) string {
+	word = folder.Replace(strings.ToLower(word))
+	if stopWords[word] {
+		return ""
+	}
+	return Stem(word)
+}
+
+// tokenize splits text into the terms of its words, with their positions
+func tokenize(text string) []token {
+	var tokens []token
+	start := -1
+	for i, r := range text {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		switch {
+		case isWord && start < 0:
+			start = i
+		case !isWord && start >= 0:
+			if term := normalize(text[start:i]); term != "" {
+				tokens = append(tokens, token{term, start, i})
+			}
+			start = -1
+		}
+	}
+	if start >= 0 {
+		if term := normalize(text[start:]); term != "" {
+			tokens = append(tokens, token{term, start, len(text)})
+		}
+	}
+	return tokens
+}
+
+// Terms returns the distinct index terms of text, e.g. of a search query
+func Terms(text string) []string {
+	var terms []string
+	seen := map[string]bool{}
+	for _, t := range tokenize(text) {
+		if !seen[t.term] {
+			seen[t.term] = true
+			terms = append(terms, t.term)
+		}
+	}
+	return terms
+}
+
+// Snippet returns the part of text around the first word matching one of
+// terms, about width characters long, with every matching word wrapped by
+// mark. Without a match the start of the text is returned.
+func Snippet(text string, terms []string, width int, mark func(word string) string) string {
+	text = cleanText(text)
+	want := map[string]bool{}
+	for _, t := range terms {
+		want[t] = true
+	}
+
+	tokens := tokenize(text)
+	first := -1
+	for _, t := range tokens {
+		if want[t.term] {
+			first = t.start
+			break
+		}
+	}
+
+	// Start a little before the first match, at a word boundary
+	start := 0
+	if first > width/4 {
+		start = first - width/4
+		for start < first && text[start] != ' ' {
+			start++
+		}
+		if start < first {
+			start++
+		}
+	}
+	end := len(text)
+	if utf8.RuneCountInString(text[start:]) > width {
+		end = start
+		for n := 0; n < width && end < len(text); n++ {
+			_, size := utf8.DecodeRuneInString(text[end:])
+			end += size
+		}
+		if i := strings.LastIndexByte(text[start:end], ' '); i > 0 {
+			end = start + i
+		}
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	pos := start
+	for _, t := range tokens {
+		if t.start < start || t.end > end || !want[t.term] {
+			continue
+		}
+		b.WriteString(text[pos:t.start])
+		b.WriteString(mark(text[t.start:t.end]))
+		pos = t.end
+	}
+	b.WriteString(text[pos:end])
+	if end < len(text) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
@@ -0,0 +1,130 @@
+package media
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ManifestFile is the name of the manifest kept in a pull directory
+const ManifestFile = ".mochi-media.json"
+
+// SafeName reports whether an attachment filename can be used as a local
+// file name: not empty and without path separators or parent references
+func SafeName(name string) bool {
+	return name != "" && name != "." && name != ".." &&
+		!strings.ContainsAny(name, `/\`) && filepath.Base(name) == name
+}
+
+// WriteFile streams r to path through a temporary file in the same
+// directory, so an interrupted download never leaves a partial file. It
+// returns the number of bytes written and their SHA-256.
+func WriteFile(path string, r io.Reader) (int64, string, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".download-*")
+	if err != nil {
+		return 0, "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := sha256.New()
+	n, err := io.Copy(io.MultiWriter(tmp, hash), r)
+	if err != nil {
+		tmp.Close()
+		return 0, "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, "", err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return 0, "", err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return 0, "", err
+	}
+	return n, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// HashFile returns the size and SHA-256 of a file
+func HashFile(path string) (int64, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, "", err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	n, err := io.Copy(hash, f)
+	if err != nil {
+		return 0, "", err
+	}
+	return n, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Manifest records the attachments downloaded into a directory, so a later
+// pull can skip files that haven't changed
+type Manifest struct {
+	Version int                      `json:"version"`
+	Files   map[string]ManifestEntry `json:"files"`
+}
+
+// ManifestEntry describes one downloaded attachment, keyed in the manifest
+// by its path relative to the pull directory
+type ManifestEntry struct {
+	CardID      string `json:"card-id"`
+	Size        int64  `json:"size"`
+	SHA256      string `json:"sha256"`
+	ContentType string `json:"content-type,omitempty"`
+	// CardUpdatedAt is the card's updated-at when the file was downloaded
+	CardUpdatedAt string `json:"card-updated-at,omitempty"`
+}
+
+// LoadManifest reads the manifest of dir. A missing manifest is empty.
+func LoadManifest(dir string) (*Manifest, error) {
+	m := &Manifest{Version: 1, Files: map[string]ManifestEntry{}}
+
+	data, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read media manifest: %w", err)
+	}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("invalid media manifest %s: %w", filepath.Join(dir, ManifestFile), err)
+	}
+	if m.Files == nil {
+		m.Files = map[string]ManifestEntry{}
+	}
+	return m, nil
+}
+
+// Save writes the manifest into dir
+func (m *Manifest) Save(dir string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, _, err = WriteFile(filepath.Join(dir, ManifestFile), strings.NewReader(string(data)+"\n"))
+	return err
+}
+
+// Unchanged reports whether the file at key (relative to dir) was downloaded
+// from the card as it is now and is still intact on disk. size is the
+// attachment size the API reports, or 0 when unknown.
+func (m *Manifest) Unchanged(dir, key, cardUpdatedAt string, size int64) bool {
+	entry, ok := m.Files[key]
+	if !ok || entry.CardUpdatedAt != cardUpdatedAt {
+		return false
+	}
+	if size > 0 && size != entry.Size {
+		return false
+	}
+
+	n, sum, err := HashFile(filepath.Join(dir, filepath.FromSlash(key)))
+	return err == nil && n == entry.Size && sum == entry.SHA256
+}
@@ -0,0 +1,215 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+)
+
+// maxPixels bounds the images processImage decodes, so a small file
+// claiming huge dimensions can't exhaust memory
+const maxPixels = 100_000_000
+
+// imageResult is a downscaled or re-encoded image
+type imageResult struct {
+	data          []byte
+	resized       bool
+	width, height int
+}
+
+// processImage downscales and re-encodes a PNG or JPEG according to opts.
+// It returns nil when the original should be uploaded unchanged: nothing
+// needed doing, the image couldn't be decoded, or re-encoding alone didn't
+// make it smaller.
+func processImage(data []byte, contentType string, opts PrepareOptions) (*imageResult, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil
+	}
+	if cfg.Width*cfg.Height > maxPixels {
+		return nil, fmt.Errorf("image is %dx%d, too large to process", cfg.Width, cfg.Height)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil
+	}
+
+	// Re-encoding drops EXIF, so bake a JPEG's orientation into the pixels
+	if contentType == "image/jpeg" {
+		img = applyOrientation(img, jpegOrientation(data))
+	}
+
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	resized := false
+	if opts.MaxDim > 0 && (w > opts.MaxDim || h > opts.MaxDim) {
+		if w >= h {
+			w, h = opts.MaxDim, max(1, h*opts.MaxDim/w)
+		} else {
+			w, h = max(1, w*opts.MaxDim/h), opts.MaxDim
+		}
+		img = downscale(img, w, h)
+		resized = true
+	}
+	if !resized && contentType != "image/jpeg" {
+		return nil, nil
+	}
+
+	var buf bytes.Buffer
+	switch contentType {
+	case "image/jpeg":
+		quality := opts.JPEGQuality
+		if quality <= 0 {
+			quality = DefaultJPEGQuality
+		}
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality})
+	default:
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+
+	if !resized && buf.Len() >= len(data) {
+		return nil, nil
+	}
+	return &imageResult{data: buf.Bytes(), resized: resized, width: w, height: h}, nil
+}
+
+// downscale resizes src to w x h by averaging the source pixels each
+// destination pixel covers (a box filter), which avoids the aliasing of
+// nearest-neighbor sampling when shrinking
+func downscale(src image.Image, w, h int) image.Image {
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	sb := src.Bounds()
+	sw, sh := sb.Dx(), sb.Dy()
+
+	for y := 0; y < h; y++ {
+		y0 := sb.Min.Y + y*sh/h
+		y1 := max(y0+1, sb.Min.Y+(y+1)*sh/h)
+		for x := 0; x < w; x++ {
+			x0 := sb.Min.X + x*sw/w
+			x1 := max(x0+1, sb.Min.X+(x+1)*sw/w)
+
+			// Sum alpha-premultiplied channels, then average
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(cr), g+uint64(cg), b+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			dst.Set(x, y, color.RGBA64{
+				R: uint16(r / n),
+				G: uint16(g / n),
+				B: uint16(b / n),
+				A: uint16(a / n),
+			})
+		}
+	}
+	return dst
+}
+
+// jpegOrientation returns the EXIF orientation (1-8) of a JPEG, or 1 when
+// it has none
+func jpegOrientation(data []byte) int {
+	// Walk the JPEG markers up to the start of scan looking for APP1 "Exif"
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return 1
+		}
+		marker := data[i+1]
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		if marker == 0xDA || length < 2 || i+2+length > len(data) {
+			return 1
+		}
+		segment := data[i+4 : i+2+length]
+		if marker == 0xE1 && len(segment) > 6 && string(segment[:6]) == "Exif\x00\x00" {
+			return tiffOrientation(segment[6:])
+		}
+		i += 2 + length
+	}
+	return 1
+}
+
+// tiffOrientation reads the orientation tag from the first IFD of a TIFF
+// header, as embedded in EXIF
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd+2 > len(tiff) {
+		return 1
+	}
+	entries := int(order.Uint16(tiff[ifd:]))
+	for e := 0; e < entries; e++ {
+		off := ifd + 2 + e*12
+		if off+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[off:]) == 0x0112 {
+			if o := int(order.Uint16(tiff[off+8:])); o >= 1 && o <= 8 {
+				return o
+			}
+			return 1
+		}
+	}
+	return 1
+}
+
+// applyOrientation transforms img so it displays upright without its EXIF
+// orientation
+func applyOrientation(img image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // mirrored horizontally
+				dx, dy = w-1-x, y
+			case 3: // rotated 180
+				dx, dy = w-1-x, h-1-y
+			case 4: // mirrored vertically
+				dx, dy = x, h-1-y
+			case 5: // mirrored along the top-left diagonal
+				dx, dy = y, x
+			case 6: // rotated 90 clockwise
+				dx, dy = h-1-y, x
+			case 7: // mirrored along the top-right diagonal
+				dx, dy = h-1-y, w-1-x
+			case 8: // rotated 90 counter-clockwise
+				dx, dy = y, w-1-x
+			}
+			dst.Set(dx, dy, img.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return dst
+}
@@ -0,0 +1,103 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestJPEGOrientation(t *testing.T) {
+	plain := encodeJPEG(t, noise(4, 4), 90)
+	tests := []struct {
+		name string
+		data []byte
+		want int
+	}{
+		{"no EXIF", plain, 1},
+		{"little endian 6", withOrientation(plain, 6, binary.LittleEndian), 6},
+		{"big endian 8", withOrientation(plain, 8, binary.BigEndian), 8},
+		{"big endian 3", withOrientation(plain, 3, binary.BigEndian), 3},
+		{"out of range", withOrientation(plain, 9, binary.LittleEndian), 1},
+		{"truncated", withOrientation(plain, 6, binary.LittleEndian)[:20], 1},
+		{"not a JPEG", []byte("GIF89a......"), 1},
+	}
+	for _, tt := range tests {
+		if got := jpegOrientation(tt.data); got != tt.want {
+			t.Errorf("%s: orientation %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestApplyOrientation(t *testing.T) {
+	// A 3x2 image with a red top-left corner
+	src := image.NewNRGBA(image.Rect(0, 0, 3, 2))
+	red := color.NRGBA{255, 0, 0, 255}
+	src.Set(0, 0, red)
+
+	tests := []struct {
+		orientation      int
+		w, h             int
+		cornerX, cornerY int
+	}{
+		{1, 3, 2, 0, 0},
+		{2, 3, 2, 2, 0},
+		{3, 3, 2, 2, 1},
+		{4, 3, 2, 0, 1},
+		{5, 2, 3, 0, 0},
+		{6, 2, 3, 1, 0},
+		{7, 2, 3, 1, 2},
+		{8, 2, 3, 0, 2},
+	}
+	for _, tt := range tests {
+		dst := applyOrientation(src, tt.orientation)
+		b := dst.Bounds()
+		if b.Dx() != tt.w || b.Dy() != tt.h {
+			t.Errorf("orientation %d: %dx%d, want %dx%d", tt.orientation, b.Dx(), b.Dy(), tt.w, tt.h)
+			continue
+		}
+		if got := color.NRGBAModel.Convert(dst.At(tt.cornerX, tt.cornerY)); got != red {
+			t.Errorf("orientation %d: the red corner isn't at %d,%d", tt.orientation, tt.cornerX, tt.cornerY)
+		}
+	}
+}
+
+func TestPrepareAppliesOrientation(t *testing.T) {
+	plain := encodeJPEG(t, noise(40, 20), 100)
+	for _, orientation := range []int{6, 8} {
+		data := withOrientation(plain, orientation, binary.LittleEndian)
+		p, err := Prepare(bytes.NewReader(data), int64(len(data)), "photo.jpg", PrepareOptions{JPEGQuality: 20})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !p.Reencoded {
+			t.Fatalf("orientation %d: image wasn't re-encoded", orientation)
+		}
+		if w, h := decodedSize(t, p.Body); w != 20 || h != 40 {
+			t.Errorf("orientation %d: uploaded image is %dx%d, want 20x40", orientation, w, h)
+		}
+	}
+}
+
+func TestDownscaleAverages(t *testing.T) {
+	// Alternating black and white columns average to grey
+	src := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			if x%2 == 0 {
+				src.Set(x, y, color.White)
+			} else {
+				src.Set(x, y, color.Black)
+			}
+		}
+	}
+	dst := downscale(src, 2, 2)
+	if b := dst.Bounds(); b.Dx() != 2 || b.Dy() != 2 {
+		t.Fatalf("downscaled to %dx%d, want 2x2", b.Dx(), b.Dy())
+	}
+	r, g, b, a := dst.At(1, 1).RGBA()
+	if r>>8 != 127 || g>>8 != 127 || b>>8 != 127 || a>>8 != 255 {
+		t.Errorf("pixel = %d,%d,%d,%d, want mid grey", r>>8, g>>8, b>>8, a>>8)
+	}
+}
@@ -0,0 +1,149 @@
+package media
+
+import (
+	"fmt"
+	"mime"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// LocalFile is a local file linked from card markdown, to be uploaded as an
+// attachment
+type LocalFile struct {
+	// Path is the file's path on disk
+	Path string
+	// Name is the attachment filename: a hash of the content plus the
+	// original extension, so identical files share one attachment
+	Name string
+	// Targets are the link targets in the markdown that point at the file
+	Targets []string
+}
+
+// linkRe matches a markdown image or link; group 1 is "!" for images, group
+// 2 the link text and group 3 the target, optionally wrapped in <> and
+// followed by a title
+var linkRe = regexp.MustCompile(`(!?)\[([^\]]*)\]\(\s*(<[^>]+>|[^)\s]+)(?:\s+"[^"]*")?\s*\)`)
+
+// schemeRe matches a URL scheme such as "https:" or "data:"
+var schemeRe = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*:`)
+
+// FindLocal returns the local files referenced by markdown images and links
+// in content, resolving relative paths against baseDir. Images must point at
+// an existing file. Links are only picked up when they point at an existing
+// image, audio, video or PDF file, so links to other documents are left
+// alone. Remote URLs and @media/ references are ignored.
+func FindLocal(content, baseDir string) ([]LocalFile, error) {
+	var files []LocalFile
+	byName := map[string]int{}
+	seenTarget := map[string]bool{}
+
+	for _, m := range linkRe.FindAllStringSubmatch(content, -1) {
+		isImage := m[1] == "!"
+		target := m[3]
+		if seenTarget[target] || !isLocalTarget(target) {
+			continue
+		}
+
+		path := strings.TrimSuffix(strings.TrimPrefix(target, "<"), ">")
+		if i := strings.IndexAny(path, "?#"); i >= 0 {
+			path = path[:i]
+		}
+		if decoded, err := url.PathUnescape(path); err == nil {
+			path = decoded
+		}
+		if strings.HasPrefix(path, "~/") {
+			if home, err := os.UserHomeDir(); err == nil {
+				path = filepath.Join(home, path[2:])
+			}
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(baseDir, filepath.FromSlash(path))
+		}
+
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			if isImage {
+				return nil, fmt.Errorf("image %s: file not found at %s", target, path)
+			}
+			continue
+		}
+		if !isImage && !isMediaFile(path) {
+			continue
+		}
+		seenTarget[target] = true
+
+		_, sum, err := HashFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		name := sum[:16] + strings.ToLower(filepath.Ext(path))
+
+		// Files with the same content share one attachment
+		if i, ok := byName[name]; ok {
+			files[i].Targets = append(files[i].Targets, target)
+			continue
+		}
+		files = append(files, LocalFile{Path: path, Name: name, Targets: []string{target}})
+		byName[name] = len(files) - 1
+	}
+
+	return files, nil
+}
+
+// RewriteLocal replaces the link targets of the given files in content with
+// @media/ references to their attachment names
+func RewriteLocal(content string, files []LocalFile) string {
+	targets := map[string]string{}
+	for _, f := range files {
+		for _, t := range f.Targets {
+			targets[t] = Prefix + f.Name
+		}
+	}
+	if len(targets) == 0 {
+		return content
+	}
+
+	return linkRe.ReplaceAllStringFunc(content, func(link string) string {
+		m := linkRe.FindStringSubmatchIndex(link)
+		target := link[m[6]:m[7]]
+		ref, ok := targets[target]
+		if !ok {
+			return link
+		}
+		return link[:m[6]] + ref + link[m[7]:]
+	})
+}
+
+// isLocalTarget reports whether a link target may name a local file
+func isLocalTarget(target string) bool {
+	target = strings.TrimPrefix(target, "<")
+	switch {
+	case target == "", strings.HasPrefix(target, "#"), strings.HasPrefix(target, Prefix):
+		return false
+	case strings.HasPrefix(target, "//"):
+		return false
+	case schemeRe.MatchString(target) && !isWindowsDrive(target):
+		return false
+	}
+	return true
+}
+
+// isWindowsDrive reports whether a target starts with a drive letter like C:
+func isWindowsDrive(target string) bool {
+	return len(target) >= 3 && target[1] == ':' && (target[2] == '\\' || target[2] == '/')
+}
+
+// isMediaFile reports whether a file is an image, audio, video or PDF file
+// judging by its extension
+func isMediaFile(path string) bool {
+	typ := mime.TypeByExtension(strings.ToLower(filepath.Ext(path)))
+	for _, prefix := range []string{"image/", "audio/", "video/", "application/pdf"} {
+		if strings.HasPrefix(typ, prefix) {
+			return true
+		}
+	}
+	return false
+}
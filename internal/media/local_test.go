@@ -0,0 +1,136 @@
+package media
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// localDir creates files for FindLocal to discover and returns the
+// directory and the attachment name each file's content gets
+func localDir(t *testing.T) (string, map[string]string) {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"photo.png":         "png data",
+		"my pics/copy.png":  "png data",
+		"my pics/other.PNG": "other png data",
+		"doc.pdf":           "pdf data",
+		"notes.txt":         "some notes",
+	}
+	names := map[string]string{}
+	for path, content := range files {
+		full := filepath.Join(dir, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		sum := sha256.Sum256([]byte(content))
+		names[path] = hex.EncodeToString(sum[:])[:16] + strings.ToLower(filepath.Ext(path))
+	}
+	return dir, names
+}
+
+func TestFindLocal(t *testing.T) {
+	dir, names := localDir(t)
+
+	// found lists the expected files as path, then targets
+	tests := []struct {
+		name    string
+		content string
+		found   [][]string
+	}{
+		{"image", "![a](photo.png)", [][]string{{"photo.png", "photo.png"}}},
+		{"title", `![a](photo.png "A photo")`, [][]string{{"photo.png", "photo.png"}}},
+		{"angle brackets", "![a](<my pics/copy.png>)", [][]string{{"my pics/copy.png", "<my pics/copy.png>"}}},
+		{"percent-encoded", "![a](my%20pics/other.PNG)", [][]string{{"my pics/other.PNG", "my%20pics/other.PNG"}}},
+		{"query and fragment", "![a](photo.png?v=2#top)", [][]string{{"photo.png", "photo.png?v=2#top"}}},
+		{"absolute", "![a](" + filepath.ToSlash(filepath.Join(dir, "doc.pdf")) + ")",
+			[][]string{{"doc.pdf", filepath.ToSlash(filepath.Join(dir, "doc.pdf"))}}},
+		{
+			"same content shares an attachment",
+			"![a](photo.png) ![b](<my pics/copy.png>) ![c](photo.png)",
+			[][]string{{"photo.png", "photo.png", "<my pics/copy.png>"}},
+		},
+		{
+			"different content",
+			"![a](photo.png) ![b](my%20pics/other.PNG)",
+			[][]string{{"photo.png", "photo.png"}, {"my pics/other.PNG", "my%20pics/other.PNG"}},
+		},
+		{"link to a media file", "[the doc](doc.pdf)", [][]string{{"doc.pdf", "doc.pdf"}}},
+		{"link to another file", "[notes](notes.txt)", nil},
+		{"link to a missing file", "[gone](gone.pdf)", nil},
+		{"link to a directory", "[pics](<my pics>)", nil},
+		{
+			"remote and attached",
+			"![r](https://example.com/x.png) ![p](//cdn.example.com/x.png) ![m](@media/x.png) " +
+				"[h](#section) [mail](mailto:a@example.com) ![d](data:image/png;base64,AAAA)",
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := FindLocal(tt.content, dir)
+			if err != nil {
+				t.Fatalf("FindLocal: %v", err)
+			}
+			var got [][]string
+			for _, f := range files {
+				got = append(got, append([]string{f.Path}, f.Targets...))
+			}
+			var want [][]string
+			for _, f := range tt.found {
+				want = append(want, append([]string{filepath.Join(dir, filepath.FromSlash(f[0]))}, f[1:]...))
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("FindLocal = %q, want %q", got, want)
+			}
+			for i, f := range files {
+				if f.Name != names[tt.found[i][0]] {
+					t.Errorf("%s named %s, want %s", f.Path, f.Name, names[tt.found[i][0]])
+				}
+			}
+		})
+	}
+}
+
+func TestFindLocalMissingImage(t *testing.T) {
+	dir, _ := localDir(t)
+	if _, err := FindLocal("![a](photo.png) ![b](missing.png)", dir); err == nil ||
+		!strings.Contains(err.Error(), "missing.png") {
+		t.Errorf("FindLocal error = %v, want one naming missing.png", err)
+	}
+}
+
+func TestRewriteLocal(t *testing.T) {
+	dir, names := localDir(t)
+	photo, other := Prefix+names["photo.png"], Prefix+names["my pics/other.PNG"]
+
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{"![a](photo.png)", "![a](" + photo + ")"},
+		{`![a](photo.png "A photo")`, `![a](` + photo + ` "A photo")`},
+		{"![a](<my pics/copy.png>) and ![b](photo.png?v=2)", "![a](" + photo + ") and ![b](" + photo + ")"},
+		{"![a](my%20pics/other.PNG)", "![a](" + other + ")"},
+		{"[the doc](doc.pdf)", "[the doc](" + Prefix + names["doc.pdf"] + ")"},
+		{"[notes](notes.txt) ![r](https://example.com/photo.png)", "[notes](notes.txt) ![r](https://example.com/photo.png)"},
+		{"no links", "no links"},
+	}
+	for _, tt := range tests {
+		files, err := FindLocal(tt.content, dir)
+		if err != nil {
+			t.Fatalf("FindLocal(%q): %v", tt.content, err)
+		}
+		if got := RewriteLocal(tt.content, files); got != tt.want {
+			t.Errorf("RewriteLocal(%q) = %q, want %q", tt.content, got, tt.want)
+		}
+	}
+}
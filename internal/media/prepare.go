@@ -0,0 +1,266 @@
+package media
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/nerveband/mochi-cli/pkg/mochi"
+)
+
+// DefaultJPEGQuality is used when a JPEG is re-encoded without an explicit
+// quality
+const DefaultJPEGQuality = 85
+
+// PrepareOptions controls how a file is prepared for upload. Zero values
+// disable the corresponding step.
+type PrepareOptions struct {
+	// MaxSize rejects files larger than this many bytes after processing
+	MaxSize int64
+	// MaxDim downscales PNG and JPEG images whose width or height exceeds it
+	MaxDim int
+	// JPEGQuality re-encodes JPEG images at this quality (1-100). Without a
+	// resize the result is only kept if it is smaller than the original.
+	JPEGQuality int
+}
+
+// Prepared is a file ready to upload
+type Prepared struct {
+	Filename    string
+	ContentType string
+	// Body supplies the content to upload
+	Body io.Reader
+	// Size is the size of Body, or -1 when unknown
+	Size int64
+	// OriginalSize is the size before processing, or -1 when unknown
+	OriginalSize int64
+	// Resized is set when an image was downscaled
+	Resized bool
+	// Reencoded is set when an image was decoded and encoded again
+	Reencoded bool
+	// Width and Height are the final dimensions of a processed image
+	Width, Height int
+
+	closer io.Closer
+}
+
+// Close releases the file opened by PrepareFile
+func (p *Prepared) Close() error {
+	if p.closer != nil {
+		return p.closer.Close()
+	}
+	return nil
+}
+
+// Describe summarizes what preparing did to the file, e.g.
+// "image/jpeg, 4.1 MB -> 612.0 KB, resized to 1600x1200"
+func (p *Prepared) Describe() string {
+	parts := []string{p.ContentType}
+	switch {
+	case p.Reencoded && p.OriginalSize >= 0:
+		parts = append(parts, fmt.Sprintf("%s -> %s", FormatSize(p.OriginalSize), FormatSize(p.Size)))
+	case p.Size >= 0:
+		parts = append(parts, FormatSize(p.Size))
+	}
+	if p.Resized {
+		parts = append(parts, fmt.Sprintf("resized to %dx%d", p.Width, p.Height))
+	} else if p.Reencoded {
+		parts = append(parts, "re-encoded")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// PrepareFile opens a file and prepares it for upload under its base name.
+// The caller must close the result.
+func PrepareFile(path string, opts PrepareOptions) (*Prepared, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	p, err := Prepare(f, info.Size(), filepath.Base(path), opts)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	p.closer = f
+	return p, nil
+}
+
+// Prepare sniffs the content type of r and applies opts: PNG and JPEG
+// images are downscaled and re-encoded as configured, then the size limit is
+// enforced. size is the length of r, or -1 when unknown. Files that need no
+// processing are streamed rather than read into memory, and a seekable r
+// stays seekable so the upload can be retried.
+func Prepare(r io.Reader, size int64, filename string, opts PrepareOptions) (*Prepared, error) {
+	head, body, err := peek(r)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Prepared{
+		Filename:     filename,
+		ContentType:  mochi.DetectContentType(filename, head),
+		Body:         body,
+		Size:         size,
+		OriginalSize: size,
+	}
+
+	if wantsProcessing(p.ContentType, opts) {
+		data, err := io.ReadAll(body)
+		if err != nil {
+			return nil, err
+		}
+		p.OriginalSize = int64(len(data))
+
+		result, err := processImage(data, p.ContentType, opts)
+		if err != nil {
+			return nil, err
+		}
+		if result != nil {
+			data = result.data
+			p.Resized, p.Reencoded = result.resized, true
+			p.Width, p.Height = result.width, result.height
+		}
+		p.Body = bytes.NewReader(data)
+		p.Size = int64(len(data))
+	}
+
+	if opts.MaxSize > 0 {
+		if p.Size > opts.MaxSize {
+			return nil, &SizeError{Size: p.Size, Limit: opts.MaxSize}
+		}
+		if p.Size < 0 {
+			p.Body = &limitReader{r: p.Body, limit: opts.MaxSize, remaining: opts.MaxSize}
+		}
+	}
+
+	return p, nil
+}
+
+// peek returns the first 512 bytes of r and a reader for all of r
+func peek(r io.Reader) ([]byte, io.Reader, error) {
+	// Pipes are files too, but can't seek
+	if rs, ok := r.(io.ReadSeeker); ok && canSeek(rs) {
+		head := make([]byte, 512)
+		n, err := io.ReadFull(rs, head)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return nil, nil, err
+		}
+		if _, err := rs.Seek(int64(-n), io.SeekCurrent); err != nil {
+			return nil, nil, err
+		}
+		return head[:n], rs, nil
+	}
+
+	br := bufio.NewReader(r)
+	head, err := br.Peek(512)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, nil, err
+	}
+	return head, br, nil
+}
+
+// canSeek reports whether s really supports seeking
+func canSeek(s io.Seeker) bool {
+	_, err := s.Seek(0, io.SeekCurrent)
+	return err == nil
+}
+
+// wantsProcessing reports whether a file of the given type is an image that
+// opts asks to resize or re-encode
+func wantsProcessing(contentType string, opts PrepareOptions) bool {
+	switch contentType {
+	case "image/jpeg":
+		return opts.MaxDim > 0 || opts.JPEGQuality > 0
+	case "image/png":
+		return opts.MaxDim > 0
+	}
+	return false
+}
+
+// SizeError reports a file larger than PrepareOptions.MaxSize
+type SizeError struct {
+	// Size is the file size, or -1 when the file was a stream
+	Size  int64
+	Limit int64
+}
+
+// Error implements the error interface
+func (e *SizeError) Error() string {
+	if e.Size < 0 {
+		return fmt.Sprintf("file is larger than the %s limit", FormatSize(e.Limit))
+	}
+	return fmt.Sprintf("file is %s, larger than the %s limit", FormatSize(e.Size), FormatSize(e.Limit))
+}
+
+// limitReader fails once more than the allowed number of bytes is read,
+// enforcing the size limit on streams of unknown length
+type limitReader struct {
+	r         io.Reader
+	limit     int64
+	remaining int64
+}
+
+// Read implements io.Reader
+func (l *limitReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return n, &SizeError{Size: -1, Limit: l.limit}
+	}
+	return n, err
+}
+
+// sizeUnits are the suffixes accepted by ParseSize, largest first
+var sizeUnits = []struct {
+	suffix string
+	factor int64
+}{
+	{"gib", 1 << 30}, {"gb", 1 << 30}, {"g", 1 << 30},
+	{"mib", 1 << 20}, {"mb", 1 << 20}, {"m", 1 << 20},
+	{"kib", 1 << 10}, {"kb", 1 << 10}, {"k", 1 << 10},
+	{"b", 1},
+}
+
+// ParseSize parses a size such as "10MB", "512k" or "2048". Units are
+// binary: 1 KB is 1024 bytes.
+func ParseSize(s string) (int64, error) {
+	value := strings.ToLower(strings.TrimSpace(s))
+	factor := int64(1)
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(value, u.suffix) {
+			value = strings.TrimSpace(strings.TrimSuffix(value, u.suffix))
+			factor = u.factor
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q (expected e.g. 10MB, 512KB or a number of bytes)", s)
+	}
+	return int64(n * float64(factor)), nil
+}
+
+// FormatSize formats a byte count for humans, e.g. "1.5 MB"
+func FormatSize(n int64) string {
+	switch {
+	case n < 1024:
+		return fmt.Sprintf("%d B", n)
+	case n < 1024*1024:
+		return fmt.Sprintf("%.1f KB", float64(n)/1024)
+	default:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1024*1024))
+	}
+}
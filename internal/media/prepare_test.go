@@ -0,0 +1,250 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+// noise returns a w x h image of reproducible random pixels, which
+// compresses poorly enough for re-encoding to make a difference
+func noise(w, h int) *image.NRGBA {
+	rng := rand.New(rand.NewSource(int64(w*1000 + h)))
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.NRGBA{uint8(rng.Intn(256)), uint8(rng.Intn(256)), uint8(rng.Intn(256)), 255})
+		}
+	}
+	return img
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func encodeJPEG(t *testing.T, img image.Image, quality int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// withOrientation inserts an EXIF APP1 segment with the given orientation
+// after the start marker of a JPEG, in the given TIFF byte order
+func withOrientation(data []byte, orientation int, order binary.ByteOrder) []byte {
+	tiff := make([]byte, 8+2+12+4)
+	if order == binary.LittleEndian {
+		copy(tiff, "II")
+	} else {
+		copy(tiff, "MM")
+	}
+	order.PutUint16(tiff[2:], 42)
+	order.PutUint32(tiff[4:], 8)       // first IFD
+	order.PutUint16(tiff[8:], 1)       // one entry
+	order.PutUint16(tiff[10:], 0x0112) // orientation tag
+	order.PutUint16(tiff[12:], 3)      // SHORT
+	order.PutUint32(tiff[14:], 1)      // count
+	order.PutUint16(tiff[18:], uint16(orientation))
+
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	segment := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(2+len(payload)))
+	segment = append(segment, payload...)
+
+	out := append([]byte{}, data[:2]...)
+	out = append(out, segment...)
+	return append(out, data[2:]...)
+}
+
+// decodedSize returns the dimensions of an encoded image
+func decodedSize(t *testing.T, r io.Reader) (int, int) {
+	t.Helper()
+	cfg, _, err := image.DecodeConfig(r)
+	if err != nil {
+		t.Fatalf("decoding the prepared image: %v", err)
+	}
+	return cfg.Width, cfg.Height
+}
+
+func TestPrepareDownscaleKeepsAspectRatio(t *testing.T) {
+	tests := []struct {
+		name         string
+		w, h         int
+		maxDim       int
+		wantW, wantH int
+	}{
+		{"landscape", 400, 200, 100, 100, 50},
+		{"portrait", 150, 300, 100, 50, 100},
+		{"square", 120, 120, 60, 60, 60},
+		{"thin", 1000, 3, 100, 100, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := encodePNG(t, noise(tt.w, tt.h))
+			p, err := Prepare(bytes.NewReader(data), int64(len(data)), "pic.png", PrepareOptions{MaxDim: tt.maxDim})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !p.Resized || p.Width != tt.wantW || p.Height != tt.wantH {
+				t.Errorf("resized %v to %dx%d, want %dx%d", p.Resized, p.Width, p.Height, tt.wantW, tt.wantH)
+			}
+			if w, h := decodedSize(t, p.Body); w != tt.wantW || h != tt.wantH {
+				t.Errorf("uploaded image is %dx%d, want %dx%d", w, h, tt.wantW, tt.wantH)
+			}
+		})
+	}
+}
+
+func TestPrepareSmallImageUnchanged(t *testing.T) {
+	data := encodePNG(t, noise(50, 40))
+	p, err := Prepare(bytes.NewReader(data), int64(len(data)), "pic.png", PrepareOptions{MaxDim: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Resized || p.Reencoded {
+		t.Errorf("an image within MaxDim was processed: %s", p.Describe())
+	}
+	got, _ := io.ReadAll(p.Body)
+	if !bytes.Equal(got, data) {
+		t.Error("an image within MaxDim was changed")
+	}
+}
+
+func TestPrepareReencodeNotSmaller(t *testing.T) {
+	data := encodeJPEG(t, noise(64, 64), 40)
+	p, err := Prepare(bytes.NewReader(data), int64(len(data)), "photo.jpg", PrepareOptions{JPEGQuality: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Reencoded || p.Size != int64(len(data)) {
+		t.Errorf("re-encoding at a higher quality was kept: %s", p.Describe())
+	}
+	got, _ := io.ReadAll(p.Body)
+	if !bytes.Equal(got, data) {
+		t.Error("the original wasn't uploaded unchanged")
+	}
+}
+
+func TestPrepareReencodeSmaller(t *testing.T) {
+	data := encodeJPEG(t, noise(64, 64), 100)
+	p, err := Prepare(bytes.NewReader(data), int64(len(data)), "photo.jpg", PrepareOptions{JPEGQuality: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.Reencoded || p.Resized || p.Size >= int64(len(data)) || p.OriginalSize != int64(len(data)) {
+		t.Errorf("re-encoding at a lower quality: %s, want a smaller re-encoded file", p.Describe())
+	}
+}
+
+// stream hides any io.Seeker of the reader it wraps, like a pipe
+type stream struct {
+	io.Reader
+}
+
+func TestPrepareMaxSize(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 100)
+
+	// A known size is checked up front
+	_, err := Prepare(bytes.NewReader(data), int64(len(data)), "notes.txt", PrepareOptions{MaxSize: 999})
+	var sizeErr *SizeError
+	if !errors.As(err, &sizeErr) || sizeErr.Size != 1000 {
+		t.Errorf("Prepare of a file over the limit: error %v, want a SizeError of 1000 bytes", err)
+	}
+
+	// A stream of unknown size fails while it is read
+	p, err := Prepare(stream{bytes.NewReader(data)}, -1, "notes.txt", PrepareOptions{MaxSize: 999})
+	if err != nil {
+		t.Fatalf("Prepare of a stream: %v", err)
+	}
+	if _, err := io.ReadAll(p.Body); !errors.As(err, &sizeErr) || sizeErr.Size != -1 {
+		t.Errorf("reading a stream over the limit: error %v, want a SizeError", err)
+	}
+
+	// A stream within the limit reads in full
+	p, err = Prepare(stream{bytes.NewReader(data)}, -1, "notes.txt", PrepareOptions{MaxSize: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := io.ReadAll(p.Body); err != nil || !bytes.Equal(got, data) {
+		t.Errorf("reading a stream at the limit: %d bytes, error %v", len(got), err)
+	}
+}
+
+func TestPrepareSniffing(t *testing.T) {
+	png := encodePNG(t, noise(8, 8))
+	text := bytes.Repeat([]byte("plain text "), 100)
+
+	tests := []struct {
+		name     string
+		data     []byte
+		filename string
+		want     string
+	}{
+		{"image without an extension", png, "picture", "image/png"},
+		{"image with a wrong extension", png, "picture.txt", "image/png"},
+		{"text by extension", text, "notes.json", "application/json"},
+		{"short file", []byte("hi"), "hi.txt", "text/plain; charset=utf-8"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A seekable reader is rewound and passed on as is
+			r := bytes.NewReader(tt.data)
+			p, err := Prepare(r, int64(len(tt.data)), tt.filename, PrepareOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.ContentType != tt.want {
+				t.Errorf("content type %q, want %q", p.ContentType, tt.want)
+			}
+			if p.Body != io.Reader(r) {
+				t.Error("a seekable reader was wrapped, so the upload can't be retried")
+			}
+			if pos, _ := r.Seek(0, io.SeekCurrent); pos != 0 {
+				t.Errorf("reader left at offset %d, want 0", pos)
+			}
+			if got, _ := io.ReadAll(p.Body); !bytes.Equal(got, tt.data) {
+				t.Error("body differs from the file")
+			}
+
+			// A stream keeps the sniffed bytes
+			p, err = Prepare(stream{bytes.NewReader(tt.data)}, -1, tt.filename, PrepareOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.ContentType != tt.want {
+				t.Errorf("stream content type %q, want %q", p.ContentType, tt.want)
+			}
+			if got, _ := io.ReadAll(p.Body); !bytes.Equal(got, tt.data) {
+				t.Error("stream body differs from the file")
+			}
+		})
+	}
+}
+
+func TestPrepareSeeksFromCurrentOffset(t *testing.T) {
+	data := []byte("skipped header|the file")
+	r := bytes.NewReader(data)
+	r.Seek(15, io.SeekStart)
+	p, err := Prepare(r, int64(len(data)-15), "f.txt", PrepareOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := io.ReadAll(p.Body); string(got) != "the file" {
+		t.Errorf("body = %q, want the rest of the reader", got)
+	}
+}
@@ -0,0 +1,116 @@
+// Package media finds the attachment references in card markdown and keeps
+// track of attachments downloaded to disk. Mochi links a card's attachments
+// as "@media/<filename>", e.g. "![diagram](@media/diagram.png)".
+package media
+
+import (
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/nerveband/mochi-cli/pkg/mochi/models"
+)
+
+// Prefix starts a link to an attachment of the card
+const Prefix = "@media/"
+
+// refRe matches an @media/ reference up to the end of the link target
+var refRe = regexp.MustCompile(`@media/([^\s)\]"'<>]+)`)
+
+// References returns the distinct attachment filenames referenced in
+// content, in order of first appearance. Percent-encoded names are decoded.
+func References(content string) []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, m := range refRe.FindAllStringSubmatch(content, -1) {
+		name := m[1]
+		if decoded, err := url.PathUnescape(name); err == nil {
+			name = decoded
+		}
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// CardReferences returns the attachment filenames referenced in a card's
+// content and field values
+func CardReferences(card *models.Card) []string {
+	names := References(card.Content)
+	seen := map[string]bool{}
+	for _, name := range names {
+		seen[name] = true
+	}
+
+	ids := make([]string, 0, len(card.Fields))
+	for id := range card.Fields {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		for _, name := range References(card.Fields[id].Value) {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// StripReferences removes the markdown images and links in content whose
+// @media/ reference names one of the given attachments. Images are dropped
+// entirely and links are replaced by their text; lines left blank by the
+// removal are dropped too.
+func StripReferences(content string, names map[string]bool) string {
+	if len(names) == 0 {
+		return content
+	}
+
+	lines := strings.Split(content, "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		stripped := stripLine(line, names)
+		if stripped != line && strings.TrimSpace(stripped) == "" {
+			continue
+		}
+		kept = append(kept, stripped)
+	}
+	return strings.Join(kept, "\n")
+}
+
+// stripLine removes the links to the given attachments from one line
+func stripLine(line string, names map[string]bool) string {
+	var out strings.Builder
+	last := 0
+	for _, m := range linkRe.FindAllStringSubmatchIndex(line, -1) {
+		target := strings.TrimSuffix(strings.TrimPrefix(line[m[6]:m[7]], "<"), ">")
+		if !strings.HasPrefix(target, Prefix) {
+			continue
+		}
+		name := strings.TrimPrefix(target, Prefix)
+		if decoded, err := url.PathUnescape(name); err == nil {
+			name = decoded
+		}
+		if !names[name] {
+			continue
+		}
+
+		out.WriteString(line[last:m[0]])
+		last = m[1]
+		if m[2] != m[3] {
+			// Dropping an image between two spaces would leave both
+			if strings.HasSuffix(out.String(), " ") && strings.HasPrefix(line[last:], " ") {
+				last++
+			}
+			continue
+		}
+		out.WriteString(line[m[4]:m[5]])
+	}
+	out.WriteString(line[last:])
+	return out.String()
+}
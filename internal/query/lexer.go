@@ -0,0 +1,248 @@
+package query
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// tokenKind identifies a token of the query language
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokLParen
+	tokRParen
+	tokAnd
+	tokOr
+	tokNot
+	tokWord   // a bare word
+	tokPhrase // a "quoted phrase"
+	tokRegex  // a /regular expression/
+	tokField  // field:value
+)
+
+// token is one lexed token. Field tokens carry the field, the operator and
+// the value; regex tokens and ~ fields carry the compiled expression.
+type token struct {
+	kind  tokenKind
+	pos   int
+	text  string
+	field string
+	op    string
+	// quoted is set when a field value was a quoted phrase
+	quoted bool
+	re     *regexp.Regexp
+}
+
+// fieldNameRe matches the part of a word before the colon of a field term
+var fieldNameRe = regexp.MustCompile(`^[a-zA-Z][a-zA-Z_-]*$`)
+
+// lexer splits a query into tokens
+type lexer struct {
+	src string
+	pos int
+}
+
+// errorf returns a syntax error at pos
+func (l *lexer) errorf(pos int, msg string) error {
+	return &SyntaxError{Query: l.src, Pos: pos, Msg: msg}
+}
+
+// next returns the next token
+func (l *lexer) next() (token, error) {
+	for l.pos < len(l.src) && isSpace(l.src[l.pos]) {
+		l.pos++
+	}
+	start := l.pos
+	if l.pos >= len(l.src) {
+		return token{kind: tokEOF, pos: start}, nil
+	}
+
+	switch c := l.src[l.pos]; {
+	case c == '(':
+		l.pos++
+		return token{kind: tokLParen, pos: start}, nil
+	case c == ')':
+		l.pos++
+		return token{kind: tokRParen, pos: start}, nil
+	case c == '-' && l.pos+1 < len(l.src) && !isSpace(l.src[l.pos+1]) && l.src[l.pos+1] != ')':
+		l.pos++
+		return token{kind: tokNot, pos: start}, nil
+	case c == '"':
+		text, err := l.phrase()
+		if err != nil {
+			return token{}, err
+		}
+		return token{kind: tokPhrase, pos: start, text: text}, nil
+	case c == '/':
+		re, err := l.regex()
+		if err != nil {
+			return token{}, err
+		}
+		return token{kind: tokRegex, pos: start, re: re}, nil
+	}
+
+	// A word, a keyword or the name of a field
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		if isSpace(c) || c == '(' || c == ')' || c == '"' {
+			break
+		}
+		if c == ':' && fieldNameRe.MatchString(l.src[start:l.pos]) {
+			field := strings.ToLower(l.src[start:l.pos])
+			l.pos++
+			return l.fieldValue(start, field)
+		}
+		l.pos++
+	}
+
+	word := l.src[start:l.pos]
+	switch word {
+	case "AND":
+		return token{kind: tokAnd, pos: start}, nil
+	case "OR":
+		return token{kind: tokOr, pos: start}, nil
+	case "NOT":
+		return token{kind: tokNot, pos: start}, nil
+	}
+	return token{kind: tokWord, pos: start, text: word}, nil
+}
+
+// fieldValue lexes the operator and value following "field:"
+func (l *lexer) fieldValue(start int, field string) (token, error) {
+	t := token{kind: tokField, pos: start, field: field}
+	for _, op := range []string{">=", "<=", ">", "<", "=", "~"} {
+		if strings.HasPrefix(l.src[l.pos:], op) {
+			t.op = op
+			l.pos += len(op)
+			break
+		}
+	}
+
+	valuePos := l.pos
+	switch {
+	case l.pos < len(l.src) && l.src[l.pos] == '"':
+		text, err := l.phrase()
+		if err != nil {
+			return token{}, err
+		}
+		t.text, t.quoted = text, true
+	case l.pos < len(l.src) && l.src[l.pos] == '/' && t.op == "":
+		re, err := l.regex()
+		if err != nil {
+			return token{}, err
+		}
+		t.op, t.re = "~", re
+		return t, nil
+	default:
+		// Parentheses inside a value, as in name:~^(a|b), are kept as long
+		// as they balance
+		depth := 0
+		for l.pos < len(l.src) && !isSpace(l.src[l.pos]) {
+			c := l.src[l.pos]
+			if c == '(' {
+				depth++
+			} else if c == ')' {
+				if depth == 0 {
+					break
+				}
+				depth--
+			}
+			l.pos++
+		}
+		t.text = l.src[valuePos:l.pos]
+	}
+
+	if t.text == "" && !t.quoted {
+		return token{}, l.errorf(valuePos, "missing value for "+field+":")
+	}
+	if t.op == "~" {
+		re, err := compileRegex(t.text)
+		if err != nil {
+			return token{}, l.errorf(valuePos, err.Error())
+		}
+		t.re = re
+	}
+	return t, nil
+}
+
+// phrase lexes a double-quoted string; \" and \\ are escapes
+func (l *lexer) phrase() (string, error) {
+	start := l.pos
+	l.pos++ // opening quote
+
+	var b strings.Builder
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case c == '\\' && l.pos+1 < len(l.src) && (l.src[l.pos+1] == '"' || l.src[l.pos+1] == '\\'):
+			b.WriteByte(l.src[l.pos+1])
+			l.pos += 2
+		case c == '"':
+			l.pos++
+			return b.String(), nil
+		default:
+			b.WriteByte(c)
+			l.pos++
+		}
+	}
+	return "", l.errorf(start, "unterminated quoted phrase")
+}
+
+// regex lexes a /regular expression/; \/ stands for a slash
+func (l *lexer) regex() (*regexp.Regexp, error) {
+	start := l.pos
+	l.pos++ // opening slash
+
+	var b strings.Builder
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case c == '\\' && l.pos+1 < len(l.src) && l.src[l.pos+1] == '/':
+			b.WriteByte('/')
+			l.pos += 2
+		case c == '/':
+			l.pos++
+			re, err := compileRegex(b.String())
+			if err != nil {
+				return nil, l.errorf(start+1, err.Error())
+			}
+			return re, nil
+		default:
+			b.WriteByte(c)
+			l.pos++
+		}
+	}
+	return nil, l.errorf(start, "unterminated regular expression (missing closing /)")
+}
+
+// compileRegex compiles a query regular expression, which ignores case
+// like the rest of the language
+func compileRegex(expr string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile("(?i)" + expr)
+	if err != nil {
+		// Drop the "error parsing regexp: " prefix; the position says it all
+		msg := err.Error()
+		if i := strings.Index(msg, ": "); i >= 0 {
+			msg = msg[i+2:]
+		}
+		return nil, &regexError{msg}
+	}
+	return re, nil
+}
+
+// regexError is an invalid regular expression
+type regexError struct {
+	msg string
+}
+
+// Error implements the error interface
+func (e *regexError) Error() string {
+	return "invalid regular expression: " + e.msg
+}
+
+// isSpace reports whether c separates tokens
+func isSpace(c byte) bool {
+	return c < 0x80 && unicode.IsSpace(rune(c))
+}
@@ -0,0 +1,281 @@
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/nerveband/mochi-cli/pkg/mochi"
+	"github.com/nerveband/mochi-cli/pkg/mochi/models"
+)
+
+// node is a node of the syntax tree
+type node interface {
+	match(card *models.Card, env *Env) bool
+}
+
+type andNode struct{ left, right node }
+type orNode struct{ left, right node }
+type notNode struct{ n node }
+
+func (n *andNode) match(card *models.Card, env *Env) bool {
+	return n.left.match(card, env) && n.right.match(card, env)
+}
+
+func (n *orNode) match(card *models.Card, env *Env) bool {
+	return n.left.match(card, env) || n.right.match(card, env)
+}
+
+func (n *notNode) match(card *models.Card, env *Env) bool {
+	return !n.n.match(card, env)
+}
+
+// walk calls fn for n and every node below it
+func walk(n node, fn func(node)) {
+	fn(n)
+	switch n := n.(type) {
+	case *andNode:
+		walk(n.left, fn)
+		walk(n.right, fn)
+	case *orNode:
+		walk(n.left, fn)
+		walk(n.right, fn)
+	case *notNode:
+		walk(n.n, fn)
+	}
+}
+
+// textTerm is a bare word or quoted phrase, found in the name or content
+type textTerm struct {
+	text   string // lower case
+	phrase bool
+}
+
+func (t *textTerm) match(card *models.Card, env *Env) bool {
+	if !t.phrase && env.Word != nil {
+		return env.Word(card, t.text)
+	}
+	return strings.Contains(strings.ToLower(card.Name), t.text) ||
+		strings.Contains(strings.ToLower(card.Content), t.text)
+}
+
+// regexTerm is a /regular expression/ matched against the name or content
+type regexTerm struct {
+	re *regexp.Regexp
+}
+
+func (t *regexTerm) match(card *models.Card, env *Env) bool {
+	return t.re.MatchString(card.Name) || t.re.MatchString(card.Content)
+}
+
+// stateFlag is a yes/no property of a card, queried with is: or has:
+type stateFlag struct {
+	name string
+	// has is set for has: flags, and unset for is: flags
+	has  bool
+	test func(card *models.Card) bool
+}
+
+// stateFlags are the values of is: and has:, by name
+var stateFlags = map[string]stateFlag{}
+
+func init() {
+	for _, f := range []stateFlag{
+		{name: "archived", test: func(c *models.Card) bool { return c.Archived }},
+		{name: "trashed", test: mochi.IsTrashed},
+		{name: "new", test: func(c *models.Card) bool { return c.New }},
+		{name: "reviewed", test: func(c *models.Card) bool { return len(c.Reviews) > 0 }},
+		{name: "attachments", has: true, test: func(c *models.Card) bool { return len(c.Attachments) > 0 }},
+		{name: "name", has: true, test: func(c *models.Card) bool { return c.Name != "" }},
+		{name: "reviews", has: true, test: func(c *models.Card) bool { return len(c.Reviews) > 0 }},
+		{name: "tags", has: true, test: func(c *models.Card) bool { return len(c.ManualTags) > 0 }},
+		{name: "template", has: true, test: func(c *models.Card) bool { return c.TemplateID != "" }},
+	} {
+		stateFlags[f.name] = f
+	}
+}
+
+// stateTerm tests a state flag
+type stateTerm struct {
+	flag stateFlag
+}
+
+func (t *stateTerm) match(card *models.Card, env *Env) bool {
+	return t.flag.test(card)
+}
+
+// valueKind is the type of value a field compares against
+type valueKind int
+
+const (
+	kindText valueKind = iota
+	kindDate
+	kindNumber
+	kindState
+)
+
+// fieldSpec describes a field of the language
+type fieldSpec struct {
+	name string
+	kind valueKind
+	ops  []string
+}
+
+var (
+	textOps    = []string{":", "=", "~"}
+	compareOps = []string{":", "=", ">", ">=", "<", "<="}
+)
+
+// fields are the known fields by name, including aliases
+var fields = map[string]fieldSpec{
+	"tag":      {"tag", kindText, textOps},
+	"tags":     {"tag", kindText, textOps},
+	"deck":     {"deck", kindText, textOps},
+	"name":     {"name", kindText, textOps},
+	"content":  {"content", kindText, textOps},
+	"template": {"template", kindText, textOps},
+	"id":       {"id", kindText, []string{":", "="}},
+	"created":  {"created", kindDate, compareOps},
+	"updated":  {"updated", kindDate, compareOps},
+	"reviews":  {"reviews", kindNumber, compareOps},
+	"lapses":   {"lapses", kindNumber, compareOps},
+	"is":       {"is", kindState, []string{":"}},
+	"has":      {"has", kindState, []string{":"}},
+}
+
+// fieldTerm compares a field of the card with a value
+type fieldTerm struct {
+	field string
+	op    string
+	raw   string // the value as written
+	text  string // the value in lower case
+	re    *regexp.Regexp
+	// lo and hi bound a date value: [lo, hi)
+	lo, hi time.Time
+	num    int
+}
+
+func (t *fieldTerm) match(card *models.Card, env *Env) bool {
+	switch t.field {
+	case "tag":
+		for _, tag := range card.ManualTags {
+			if t.matchText(strings.TrimPrefix(tag, "#"), true) {
+				return true
+			}
+		}
+		return false
+	case "name":
+		return t.matchText(card.Name, false)
+	case "content":
+		return t.matchText(card.Content, false)
+	case "id":
+		return card.ID == t.raw
+	case "deck":
+		path := ""
+		if env.DeckPath != nil {
+			path = env.DeckPath(card.DeckID)
+		}
+		if t.op == "~" {
+			return t.re.MatchString(path)
+		}
+		if card.DeckID == t.raw || strings.EqualFold(path, t.raw) {
+			return true
+		}
+		// deck:Spanish also matches the decks below Spanish
+		return t.op == "" && strings.HasPrefix(strings.ToLower(path), t.text+"/")
+	case "template":
+		name := ""
+		if env.TemplateName != nil && card.TemplateID != "" {
+			name = env.TemplateName(card.TemplateID)
+		}
+		if t.op == "~" {
+			return t.re.MatchString(name)
+		}
+		return card.TemplateID != "" && (card.TemplateID == t.raw || strings.EqualFold(name, t.raw))
+	case "created":
+		return t.matchTime(card.CreatedAt)
+	case "updated":
+		return t.matchTime(card.UpdatedAt)
+	case "reviews":
+		return t.matchNumber(len(card.Reviews))
+	case "lapses":
+		return t.matchNumber(mochi.Lapses(card))
+	}
+	return false
+}
+
+// matchText matches a text value: ":" looks for the value in it, "=" wants
+// all of it and "~" applies the regular expression. Whole is set for values
+// like tags, where ":" also means all of it.
+func (t *fieldTerm) matchText(value string, whole bool) bool {
+	switch {
+	case t.op == "~":
+		return t.re.MatchString(value)
+	case t.op == "=" || whole:
+		return strings.ToLower(value) == t.text
+	default:
+		return strings.Contains(strings.ToLower(value), t.text)
+	}
+}
+
+// matchTime compares a time with the date range of the value. A card
+// without the time matches nothing.
+func (t *fieldTerm) matchTime(mt *models.MochiTime) bool {
+	if mt == nil || mt.IsZero() {
+		return false
+	}
+	v := mt.Time
+	switch t.op {
+	case ">":
+		return !v.Before(t.hi)
+	case ">=":
+		return !v.Before(t.lo)
+	case "<":
+		return v.Before(t.lo)
+	case "<=":
+		return v.Before(t.hi)
+	default:
+		return !v.Before(t.lo) && v.Before(t.hi)
+	}
+}
+
+// matchNumber compares a count with the value
+func (t *fieldTerm) matchNumber(n int) bool {
+	switch t.op {
+	case ">":
+		return n > t.num
+	case ">=":
+		return n >= t.num
+	case "<":
+		return n < t.num
+	case "<=":
+		return n <= t.num
+	default:
+		return n == t.num
+	}
+}
+
+// parseDateRange parses a date value into the range of time it covers: a
+// year (2025), a month (2025-03) or a day (2025-03-01) in local time, or
+// the instant of an RFC 3339 timestamp
+func parseDateRange(s string) (lo, hi time.Time, err error) {
+	for _, layout := range []struct {
+		format string
+		years  int
+		months int
+		days   int
+	}{
+		{"2006-01-02", 0, 0, 1},
+		{"2006-01", 0, 1, 0},
+		{"2006", 1, 0, 0},
+	} {
+		if t, err := time.ParseInLocation(layout.format, s, time.Local); err == nil {
+			return t, t.AddDate(layout.years, layout.months, layout.days), nil
+		}
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, t.Add(time.Nanosecond), nil
+	}
+	return time.Time{}, time.Time{}, fmt.Errorf("invalid date %q (expected YYYY, YYYY-MM, YYYY-MM-DD or an RFC 3339 timestamp)", s)
+}
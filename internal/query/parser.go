@@ -0,0 +1,257 @@
+package query
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// parser builds the syntax tree of a query by recursive descent:
+//
+//	query   = or EOF
+//	or      = and { "OR" and }
+//	and     = unary { [ "AND" ] unary }
+//	unary   = ( "NOT" | "-" ) unary | primary
+//	primary = "(" or ")" | term
+type parser struct {
+	lex    *lexer
+	tok    token
+	peeked bool
+}
+
+// peek returns the next token without consuming it
+func (p *parser) peek() (token, error) {
+	if !p.peeked {
+		t, err := p.lex.next()
+		if err != nil {
+			return token{}, err
+		}
+		p.tok, p.peeked = t, true
+	}
+	return p.tok, nil
+}
+
+// take consumes the peeked token
+func (p *parser) take() token {
+	p.peeked = false
+	return p.tok
+}
+
+// parse parses the whole query
+func (p *parser) parse() (node, error) {
+	t, err := p.peek()
+	if err != nil {
+		return nil, err
+	}
+	if t.kind == tokEOF {
+		return nil, p.lex.errorf(t.pos, "empty query")
+	}
+
+	n, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t, err = p.peek(); err != nil {
+		return nil, err
+	}
+	if t.kind == tokRParen {
+		return nil, p.lex.errorf(t.pos, "unexpected ) without a matching (")
+	}
+	return n, nil
+}
+
+// parseOr parses terms joined by OR
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t, err := p.peek()
+		if err != nil {
+			return nil, err
+		}
+		if t.kind != tokOr {
+			return left, nil
+		}
+		p.take()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &orNode{left, right}
+	}
+}
+
+// parseAnd parses terms joined by AND or written next to each other
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t, err := p.peek()
+		if err != nil {
+			return nil, err
+		}
+		switch t.kind {
+		case tokEOF, tokRParen, tokOr:
+			return left, nil
+		case tokAnd:
+			p.take()
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &andNode{left, right}
+	}
+}
+
+// parseUnary parses a negation or a primary expression
+func (p *parser) parseUnary() (node, error) {
+	t, err := p.peek()
+	if err != nil {
+		return nil, err
+	}
+	if t.kind == tokNot {
+		p.take()
+		n, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{n}, nil
+	}
+	return p.parsePrimary()
+}
+
+// parsePrimary parses a parenthesized expression or a single term
+func (p *parser) parsePrimary() (node, error) {
+	t, err := p.peek()
+	if err != nil {
+		return nil, err
+	}
+
+	switch t.kind {
+	case tokLParen:
+		p.take()
+		if next, err := p.peek(); err != nil {
+			return nil, err
+		} else if next.kind == tokRParen {
+			return nil, p.lex.errorf(next.pos, "empty parentheses")
+		}
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		closing, err := p.peek()
+		if err != nil {
+			return nil, err
+		}
+		if closing.kind != tokRParen {
+			return nil, p.lex.errorf(t.pos, "missing ) to close this (")
+		}
+		p.take()
+		return n, nil
+	case tokWord:
+		p.take()
+		// Bare state words like archived are short for is:archived
+		if flag, ok := stateFlags[strings.ToLower(t.text)]; ok && !flag.has {
+			return &stateTerm{flag}, nil
+		}
+		return &textTerm{text: strings.ToLower(t.text)}, nil
+	case tokPhrase:
+		p.take()
+		return &textTerm{text: strings.ToLower(t.text), phrase: true}, nil
+	case tokRegex:
+		p.take()
+		return &regexTerm{t.re}, nil
+	case tokField:
+		p.take()
+		return p.fieldNode(t)
+	case tokEOF:
+		return nil, p.lex.errorf(t.pos, "expected a search term at the end of the query")
+	case tokRParen:
+		return nil, p.lex.errorf(t.pos, "unexpected ) without a matching (")
+	default:
+		return nil, p.lex.errorf(t.pos, "expected a search term before "+keyword(t))
+	}
+}
+
+// fieldNode checks a field term and converts its value
+func (p *parser) fieldNode(t token) (node, error) {
+	spec, ok := fields[t.field]
+	if !ok {
+		return nil, p.lex.errorf(t.pos, fmt.Sprintf("unknown field %q (expected one of %s)", t.field, fieldNames()))
+	}
+	if !slices.Contains(spec.ops, opName(t.op)) {
+		return nil, p.lex.errorf(t.pos, fmt.Sprintf("%s: doesn't support the %s operator", t.field, t.op))
+	}
+
+	n := &fieldTerm{field: spec.name, op: t.op, raw: t.text, text: strings.ToLower(t.text), re: t.re}
+	valuePos := t.pos + len(t.field) + 1 + len(t.op)
+
+	switch spec.kind {
+	case kindDate:
+		lo, hi, err := parseDateRange(t.text)
+		if err != nil {
+			return nil, p.lex.errorf(valuePos, err.Error())
+		}
+		n.lo, n.hi = lo, hi
+	case kindNumber:
+		var v int
+		if _, err := fmt.Sscanf(t.text, "%d", &v); err != nil || fmt.Sprint(v) != t.text {
+			return nil, p.lex.errorf(valuePos, fmt.Sprintf("%s: expects a whole number, not %q", t.field, t.text))
+		}
+		n.num = v
+	case kindState:
+		flag, ok := stateFlags[n.text]
+		if !ok || (t.field == "has" && !flag.has) || (t.field == "is" && flag.has) {
+			return nil, p.lex.errorf(valuePos, fmt.Sprintf("%s: expects one of %s", t.field, stateNames(t.field == "has")))
+		}
+		return &stateTerm{flag}, nil
+	}
+	return n, nil
+}
+
+// opName names an operator as listed in fieldSpec.ops; ":" is the plain
+// match
+func opName(op string) string {
+	if op == "" {
+		return ":"
+	}
+	return op
+}
+
+// keyword names an operator token for error messages
+func keyword(t token) string {
+	if t.kind == tokOr {
+		return "OR"
+	}
+	return "AND"
+}
+
+// fieldNames lists the known fields for error messages
+func fieldNames() string {
+	names := make([]string, 0, len(fields))
+	for name, spec := range fields {
+		if name == spec.name {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// stateNames lists the values of is: or has: for error messages
+func stateNames(has bool) string {
+	var names []string
+	for name, flag := range stateFlags {
+		if flag.has == has && name == flag.name {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
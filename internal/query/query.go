@@ -0,0 +1,112 @@
+// Package query parses the card search language used by card search and
+// --where, and matches cards against it:
+//
+//	tag:grammar deck:"Spanish/Verbs" name:~^irregular created:>2025-01-01 -archived "exact phrase" /regex/
+//
+// Terms next to each other must all match; OR, NOT (or a leading -) and
+// parentheses combine them further. NOT binds tightest, then AND, then OR.
+package query
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/nerveband/mochi-cli/pkg/mochi/models"
+)
+
+// Query is a parsed query
+type Query struct {
+	src  string
+	root node
+}
+
+// Env supplies what a query needs to know beyond the card itself. Without
+// DeckPath and TemplateName, decks and templates only match by ID.
+type Env struct {
+	// DeckPath returns the path of a deck, its name prefixed with the names
+	// of its parents, e.g. "Spanish/Verbs"
+	DeckPath func(deckID string) string
+	// TemplateName returns the name of a template
+	TemplateName func(templateID string) string
+	// Word, when set, decides whether a card contains a bare word, e.g.
+	// through a search index that matches stemmed words. By default words
+	// are looked for in the name and content like phrases.
+	Word func(card *models.Card, word string) bool
+}
+
+// Parse parses a query. Errors are *SyntaxError values pointing at the
+// offending part of the query.
+func Parse(src string) (*Query, error) {
+	p := &parser{lex: &lexer{src: src}}
+	root, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+	return &Query{src: src, root: root}, nil
+}
+
+// String returns the query as written
+func (q *Query) String() string {
+	return q.src
+}
+
+// Uses reports whether the query has a term on the given field, such as
+// "deck", so callers only look up what it needs
+func (q *Query) Uses(field string) bool {
+	found := false
+	walk(q.root, func(n node) {
+		if t, ok := n.(*fieldTerm); ok && t.field == field {
+			found = true
+		}
+	})
+	return found
+}
+
+// Text returns the words and phrases the query looks for, leaving out
+// negated ones, e.g. to rank the matches
+func (q *Query) Text() []string {
+	var text []string
+	var collect func(n node)
+	collect = func(n node) {
+		switch n := n.(type) {
+		case *andNode:
+			collect(n.left)
+			collect(n.right)
+		case *orNode:
+			collect(n.left)
+			collect(n.right)
+		case *textTerm:
+			text = append(text, n.text)
+		}
+	}
+	collect(q.root)
+	return text
+}
+
+// Matcher returns a predicate reporting whether a card matches the query
+func (q *Query) Matcher(env Env) func(card *models.Card) bool {
+	return func(card *models.Card) bool {
+		return q.root.match(card, &env)
+	}
+}
+
+// SyntaxError reports a query that can't be parsed
+type SyntaxError struct {
+	Query string
+	// Pos is the byte offset of the problem in Query
+	Pos int
+	Msg string
+}
+
+// Column returns the 1-based column of the problem, counting characters
+func (e *SyntaxError) Column() int {
+	return utf8.RuneCountInString(e.Query[:e.Pos]) + 1
+}
+
+// Error implements the error interface. The message shows the query with a
+// caret under the problem.
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("invalid query at column %d: %s\n  %s\n  %s^",
+		e.Column(), e.Msg, e.Query, strings.Repeat(" ", e.Column()-1))
+}
@@ -0,0 +1,365 @@
+package query
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nerveband/mochi-cli/pkg/mochi/models"
+)
+
+// tree renders a syntax tree as an s-expression, to check how a query was
+// grouped
+func tree(n node) string {
+	switch n := n.(type) {
+	case *andNode:
+		return fmt.Sprintf("(and %s %s)", tree(n.left), tree(n.right))
+	case *orNode:
+		return fmt.Sprintf("(or %s %s)", tree(n.left), tree(n.right))
+	case *notNode:
+		return fmt.Sprintf("(not %s)", tree(n.n))
+	case *textTerm:
+		if n.phrase {
+			return fmt.Sprintf("%q", n.text)
+		}
+		return n.text
+	case *regexTerm:
+		return "/" + n.re.String() + "/"
+	case *stateTerm:
+		if n.flag.has {
+			return "has:" + n.flag.name
+		}
+		return "is:" + n.flag.name
+	case *fieldTerm:
+		if n.op == "~" {
+			return n.field + "~/" + n.re.String() + "/"
+		}
+		return fmt.Sprintf("%s%s%q", n.field, opName(n.op), n.raw)
+	}
+	return fmt.Sprintf("<%T>", n)
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		// Precedence: NOT, then AND, then OR
+		{"a b", "(and a b)"},
+		{"a AND b", "(and a b)"},
+		{"a b c", "(and (and a b) c)"},
+		{"a OR b c", "(or a (and b c))"},
+		{"a b OR c", "(or (and a b) c)"},
+		{"a OR b OR c", "(or (or a b) c)"},
+		{"(a OR b) c", "(and (or a b) c)"},
+		{"a (b OR (c d))", "(and a (or b (and c d)))"},
+
+		// Negation
+		{"NOT a b", "(and (not a) b)"},
+		{"-a OR b", "(or (not a) b)"},
+		{"NOT NOT a", "(not (not a))"},
+		{"-(a b)", "(not (and a b))"},
+		{"-tag:verbs", `(not tag:"verbs")`},
+		{"a-b", "a-b"},
+		{"a - b", "(and (and a -) b)"},
+
+		// Words, phrases and quoting
+		{"Hello", "hello"},
+		{"and or not", "(and (and and or) not)"},
+		{`"Hello World"`, `"hello world"`},
+		{`"say \"hi\""`, `"say \"hi\""`},
+		{`"back\\slash"`, `"back\\slash"`},
+		{`a"b c"`, `(and a "b c")`},
+		{`name:"two words"`, `name:"two words"`},
+		{`name:""`, `name:""`},
+
+		// Regular expressions
+		{"/ab+c/", "/(?i)ab+c/"},
+		{`/a\/b/`, "/(?i)a/b/"},
+		{"name:/^x/", "name~/(?i)^x/"},
+		{"name:~^(a|b)", "name~/(?i)^(a|b)/"},
+		{"(name:~^(a|b))", "name~/(?i)^(a|b)/"},
+
+		// Fields, aliases and states
+		{"tags:verbs", `tag:"verbs"`},
+		{"TAG:Verbs", `tag:"Verbs"`},
+		{"tag:=verbs", `tag="verbs"`},
+		{"tag=verbs", "tag=verbs"},
+		{"deck:Spanish/Verbs", `deck:"Spanish/Verbs"`},
+		{"reviews:>=3", `reviews>="3"`},
+		{"created:<2025-01", `created<"2025-01"`},
+		{"archived", "is:archived"},
+		{"is:new", "is:new"},
+		{"has:attachments", "has:attachments"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			q, err := Parse(tt.query)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.query, err)
+			}
+			if got := tree(q.root); got != tt.want {
+				t.Errorf("Parse(%q) = %s, want %s", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		pos   int
+		msg   string
+	}{
+		{"", 0, "empty query"},
+		{"   ", 3, "empty query"},
+		{"a OR", 4, "expected a search term at the end of the query"},
+		{"OR a", 0, "expected a search term before OR"},
+		{"a AND AND b", 6, "expected a search term before AND"},
+		{"(a b", 0, "missing ) to close this ("},
+		{"a (b (c)", 2, "missing ) to close this ("},
+		{"a )", 2, "unexpected ) without a matching ("},
+		{"()", 1, "empty parentheses"},
+		{`a "abc`, 2, "unterminated quoted phrase"},
+		{`name:"abc`, 5, "unterminated quoted phrase"},
+		{"x /ab", 2, "unterminated regular expression"},
+		{"/a(/", 1, "invalid regular expression"},
+		{"name:~a(", 6, "invalid regular expression"},
+		{"a foo:bar", 2, `unknown field "foo"`},
+		{"tag:>a", 0, "tag: doesn't support the > operator"},
+		{"id:~x", 0, "id: doesn't support the ~ operator"},
+		{"tag:", 4, "missing value for tag:"},
+		{"created:2025-13", 8, "invalid date"},
+		{"created:>=yesterday", 10, "invalid date"},
+		{"reviews:>x", 9, "reviews: expects a whole number"},
+		{"reviews:1.5", 8, "reviews: expects a whole number"},
+		{"is:bogus", 3, "is: expects one of"},
+		{"has:archived", 4, "has: expects one of"},
+		{"is:tags", 3, "is: expects one of"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			_, err := Parse(tt.query)
+			var syntaxErr *SyntaxError
+			if !errors.As(err, &syntaxErr) {
+				t.Fatalf("Parse(%q) error = %v, want a *SyntaxError", tt.query, err)
+			}
+			if syntaxErr.Pos != tt.pos {
+				t.Errorf("Parse(%q) error at %d, want %d: %v", tt.query, syntaxErr.Pos, tt.pos, err)
+			}
+			if !strings.Contains(syntaxErr.Msg, tt.msg) {
+				t.Errorf("Parse(%q) error %q, want it to contain %q", tt.query, syntaxErr.Msg, tt.msg)
+			}
+		})
+	}
+}
+
+func TestSyntaxErrorColumn(t *testing.T) {
+	_, err := Parse("éé )")
+	var syntaxErr *SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		t.Fatalf("Parse error = %v, want a *SyntaxError", err)
+	}
+	if syntaxErr.Pos != 5 || syntaxErr.Column() != 4 {
+		t.Errorf("error at byte %d column %d, want byte 5 column 4", syntaxErr.Pos, syntaxErr.Column())
+	}
+	want := "invalid query at column 4: unexpected ) without a matching (\n  éé )\n     ^"
+	if err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+}
+
+// day returns a time on a day in local time, as date values are read
+func day(year int, month time.Month, d int) *models.MochiTime {
+	return &models.MochiTime{Time: time.Date(year, month, d, 12, 0, 0, 0, time.Local)}
+}
+
+func TestMatcher(t *testing.T) {
+	hola := &models.Card{
+		ID:         "hola0001",
+		Name:       "hola",
+		Content:    "hola\n---\nhello",
+		DeckID:     "spanish1",
+		ManualTags: []string{"greetings", "#basics"},
+		CreatedAt:  day(2025, time.March, 15),
+		UpdatedAt:  day(2025, time.June, 1),
+		Reviews: []models.Review{
+			{Remembered: true},
+			{Remembered: false},
+			{Remembered: true},
+		},
+	}
+	ser := &models.Card{
+		ID:         "ser00001",
+		Name:       "Irregular: ser",
+		Content:    "ser\n---\nto be (permanent)",
+		DeckID:     "verbs001",
+		TemplateID: "basic001",
+		ManualTags: []string{"verbs"},
+		Archived:   true,
+		New:        true,
+		CreatedAt:  day(2024, time.December, 31),
+		Attachments: map[string]models.Attachment{
+			"ser.png": {},
+		},
+	}
+	env := Env{
+		DeckPath: func(id string) string {
+			return map[string]string{"spanish1": "Spanish", "verbs001": "Spanish/Verbs"}[id]
+		},
+		TemplateName: func(id string) string {
+			return map[string]string{"basic001": "Basic"}[id]
+		},
+	}
+
+	tests := []struct {
+		query     string
+		hola, ser bool
+	}{
+		// Text
+		{"hello", true, false},
+		{"HOLA", true, false},
+		{"perm", false, true},
+		{`"to be"`, false, true},
+		{`"be to"`, false, false},
+		{"/^irregular/", false, true},
+		{"/hel+o/", true, false},
+
+		// Boolean operators
+		{"hola ser", false, false},
+		{"hola OR ser", true, true},
+		{"-hola", false, true},
+		{"NOT (hola OR ser)", false, false},
+		{"hola OR ser -archived", true, false},
+		{"(hola OR ser) -archived", true, false},
+
+		// Text fields
+		{"name:irr", false, true},
+		{"name:=hola", true, false},
+		{"name:=hol", false, false},
+		{"name:~^IRR", false, true},
+		{"content:permanent", false, true},
+		{"id:ser00001", false, true},
+		{"id:ser", false, false},
+
+		// Tags match whole, ignoring a leading #
+		{"tag:greetings", true, false},
+		{"tag:greet", false, false},
+		{"tag:basics", true, false},
+		{"tag:~^verb", false, true},
+
+		// Decks by ID or path, including the decks below
+		{"deck:spanish1", true, false},
+		{"deck:Spanish", true, true},
+		{"deck:=Spanish", true, false},
+		{`deck:"spanish/verbs"`, false, true},
+		{"deck:Span", false, false},
+		{"deck:~verbs$", false, true},
+
+		// Templates
+		{"template:Basic", false, true},
+		{"template:basic001", false, true},
+		{"has:template", false, true},
+
+		// Dates cover the day, month or year written
+		{"created:2025-03-15", true, false},
+		{"created:2025-03", true, false},
+		{"created:2024", false, true},
+		{"created:>2025-03-14", true, false},
+		{"created:>2025-03-15", false, false},
+		{"created:>=2025-03-15", true, false},
+		{"created:<2025", false, true},
+		{"created:<=2024-12", false, true},
+		{"updated:2025-06", true, false},
+		{"updated:<2030", true, false},
+
+		// Counts
+		{"reviews:3", true, false},
+		{"reviews:>2", true, false},
+		{"reviews:0", false, true},
+		{"lapses:1", true, false},
+		{"lapses:<1", false, true},
+
+		// States
+		{"archived", false, true},
+		{"is:archived", false, true},
+		{"is:new", false, true},
+		{"is:reviewed", true, false},
+		{"is:trashed", false, false},
+		{"has:attachments", false, true},
+		{"has:tags", true, true},
+		{"has:reviews", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			q, err := Parse(tt.query)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.query, err)
+			}
+			match := q.Matcher(env)
+			if got := match(hola); got != tt.hola {
+				t.Errorf("%q matches hola = %v, want %v", tt.query, got, tt.hola)
+			}
+			if got := match(ser); got != tt.ser {
+				t.Errorf("%q matches ser = %v, want %v", tt.query, got, tt.ser)
+			}
+		})
+	}
+}
+
+func TestMatcherWithoutEnv(t *testing.T) {
+	card := &models.Card{DeckID: "verbs001", TemplateID: "basic001"}
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{"deck:verbs001", true},
+		{"deck:Spanish", false},
+		{"template:basic001", true},
+		{"template:Basic", false},
+	}
+	for _, tt := range tests {
+		q, err := Parse(tt.query)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.query, err)
+		}
+		if got := q.Matcher(Env{})(card); got != tt.want {
+			t.Errorf("%q matches = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestMatcherWord(t *testing.T) {
+	q, err := Parse(`run "ran fast"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var words []string
+	env := Env{Word: func(card *models.Card, word string) bool {
+		words = append(words, word)
+		return true
+	}}
+	card := &models.Card{Content: "she ran fast"}
+	if !q.Matcher(env)(card) {
+		t.Error("card doesn't match, want Word to decide bare words")
+	}
+	if strings.Join(words, ",") != "run" {
+		t.Errorf("Word called with %v, want only the bare word run", words)
+	}
+}
+
+func TestUsesAndText(t *testing.T) {
+	q, err := Parse(`hola "buenos días" -adiós deck:Spanish OR tag:x`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !q.Uses("deck") || !q.Uses("tag") || q.Uses("template") {
+		t.Errorf("Uses: deck %v tag %v template %v, want true true false",
+			q.Uses("deck"), q.Uses("tag"), q.Uses("template"))
+	}
+	if got := strings.Join(q.Text(), "|"); got != "hola|buenos días" {
+		t.Errorf("Text() = %q, want hola|buenos días", got)
+	}
+}
@@ -0,0 +1,252 @@
+package templating
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nerveband/mochi-cli/pkg/mochi/models"
+	"gopkg.in/yaml.v3"
+)
+
+// placeholderRe matches a "<< Field Name >>" placeholder in template content
+var placeholderRe = regexp.MustCompile(`<<\s*(.+?)\s*>>`)
+
+// generatedTypes are field types whose values Mochi computes itself
+var generatedTypes = map[string]bool{
+	"ai":            true,
+	"speech":        true,
+	"translate":     true,
+	"transcription": true,
+	"dictionary":    true,
+	"pinyin":        true,
+	"furigana":      true,
+}
+
+// IsGenerated reports whether Mochi computes a field's value itself, so
+// cards can't set it
+func IsGenerated(field models.TemplateField) bool {
+	return generatedTypes[field.Type]
+}
+
+// Placeholders returns the distinct field names referenced in content, in
+// order of first appearance
+func Placeholders(content string) []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, m := range placeholderRe.FindAllStringSubmatch(content, -1) {
+		if !seen[m[1]] {
+			seen[m[1]] = true
+			names = append(names, m[1])
+		}
+	}
+	return names
+}
+
+// SortedFields returns a template's fields by position, then ID
+func SortedFields(tmpl *models.Template) []models.TemplateField {
+	fields := make([]models.TemplateField, 0, len(tmpl.Fields))
+	for _, field := range tmpl.Fields {
+		fields = append(fields, field)
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		if fields[i].Pos != fields[j].Pos {
+			return fields[i].Pos < fields[j].Pos
+		}
+		return fields[i].ID < fields[j].ID
+	})
+	return fields
+}
+
+// FindField looks up a template field by name (case-insensitive) or ID
+func FindField(tmpl *models.Template, nameOrID string) (models.TemplateField, bool) {
+	if field, ok := tmpl.Fields[nameOrID]; ok {
+		return field, true
+	}
+	for _, field := range tmpl.Fields {
+		if strings.EqualFold(field.Name, nameOrID) {
+			return field, true
+		}
+	}
+	return models.TemplateField{}, false
+}
+
+// RequiredFields returns the fields a card must fill in: text fields whose
+// placeholder appears in the template content. Other types are optional or
+// filled in by Mochi.
+func RequiredFields(tmpl *models.Template) []models.TemplateField {
+	referenced := map[string]bool{}
+	for _, name := range Placeholders(tmpl.Content) {
+		if field, ok := FindField(tmpl, name); ok {
+			referenced[field.ID] = true
+		}
+	}
+
+	var required []models.TemplateField
+	for _, field := range SortedFields(tmpl) {
+		if referenced[field.ID] && (field.Type == "" || field.Type == "text") {
+			required = append(required, field)
+		}
+	}
+	return required
+}
+
+// DescribeFields lists a template's fields for error messages, e.g.
+// "Word (word, text), Meaning (meaning, text)"
+func DescribeFields(tmpl *models.Template) string {
+	var parts []string
+	for _, field := range SortedFields(tmpl) {
+		typ := field.Type
+		if typ == "" {
+			typ = "text"
+		}
+		parts = append(parts, fmt.Sprintf("%s (%s, %s)", field.Name, field.ID, typ))
+	}
+	if len(parts) == 0 {
+		return "none"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// ResolveFields maps field values given by name or ID to the card fields of
+// tmpl. It rejects unknown fields, values that don't fit the field type and
+// fields Mochi generates, and, unless allowMissing is set, requires every
+// field listed by RequiredFields.
+func ResolveFields(tmpl *models.Template, values map[string]string, allowMissing bool) (map[string]models.Field, error) {
+	var problems []string
+	fields := map[string]models.Field{}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		field, ok := FindField(tmpl, name)
+		if !ok {
+			problems = append(problems, fmt.Sprintf("unknown field %q", name))
+			continue
+		}
+		if _, dup := fields[field.ID]; dup {
+			problems = append(problems, fmt.Sprintf("field %q is given more than once", field.Name))
+			continue
+		}
+
+		value, err := checkValue(field, values[name])
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		fields[field.ID] = models.Field{ID: field.ID, Value: value}
+	}
+
+	if !allowMissing {
+		for _, field := range RequiredFields(tmpl) {
+			if f, ok := fields[field.ID]; !ok || strings.TrimSpace(f.Value) == "" {
+				problems = append(problems, fmt.Sprintf("missing required field %q", field.Name))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("invalid fields for template %s: %s (available fields: %s)",
+			tmpl.Name, strings.Join(problems, "; "), DescribeFields(tmpl))
+	}
+	return fields, nil
+}
+
+// checkValue validates a value against its field type and returns it in the
+// form Mochi stores
+func checkValue(field models.TemplateField, value string) (string, error) {
+	switch {
+	case IsGenerated(field):
+		return "", fmt.Errorf("field %q is a %s field generated by Mochi and cannot be set", field.Name, field.Type)
+	case field.Type == "number":
+		if _, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err != nil {
+			return "", fmt.Errorf("field %q expects a number, got %q", field.Name, value)
+		}
+		return strings.TrimSpace(value), nil
+	case field.Type == "boolean":
+		b, err := parseBool(value)
+		if err != nil {
+			return "", fmt.Errorf("field %q expects true or false, got %q", field.Name, value)
+		}
+		return strconv.FormatBool(b), nil
+	default:
+		return value, nil
+	}
+}
+
+// parseBool accepts the usual spellings of true and false
+func parseBool(value string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "true", "yes", "y", "on", "1":
+		return true, nil
+	case "false", "no", "n", "off", "0":
+		return false, nil
+	}
+	return false, fmt.Errorf("invalid boolean %q", value)
+}
+
+// ParseFieldFlags parses repeated Name=value flag values
+func ParseFieldFlags(flags []string) (map[string]string, error) {
+	values := map[string]string{}
+	for _, f := range flags {
+		name, value, ok := strings.Cut(f, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid --field %q (expected Name=value)", f)
+		}
+		values[name] = value
+	}
+	return values, nil
+}
+
+// LoadFieldValues reads field values from a JSON or YAML object mapping field
+// names (or IDs) to values, or from stdin when path is "-"
+func LoadFieldValues(path string) (map[string]string, error) {
+	var (
+		data []byte
+		err  error
+	)
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fields file: %w", err)
+	}
+
+	var raw map[string]interface{}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, &raw)
+	} else {
+		err = yaml.Unmarshal(data, &raw)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: expected an object of field values: %w", path, err)
+	}
+
+	values := make(map[string]string, len(raw))
+	for name, v := range raw {
+		switch v := v.(type) {
+		case string:
+			values[name] = v
+		case nil:
+			values[name] = ""
+		case bool, int, int64, float64:
+			values[name] = fmt.Sprint(v)
+		default:
+			return nil, fmt.Errorf("%s: value of field %q must be a string, number or boolean", path, name)
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,74 @@
+// Package templating loads Mochi card template definitions from YAML and
+// JSON files and checks card field values against them, for the template
+// and card commands.
+package templating
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nerveband/mochi-cli/pkg/mochi/models"
+	"gopkg.in/yaml.v3"
+)
+
+// LoadFile reads a template definition from a YAML or JSON file, or from
+// stdin when path is "-". The format is chosen by extension; files without
+// a .json extension are parsed as YAML, which also accepts JSON.
+func LoadFile(path string) (*models.Template, error) {
+	var (
+		data []byte
+		err  error
+	)
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template file: %w", err)
+	}
+
+	isJSON := strings.EqualFold(filepath.Ext(path), ".json")
+	tmpl, err := Parse(data, isJSON)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return tmpl, nil
+}
+
+// Parse decodes a template definition. Field IDs may be left out, in which
+// case the key of the field in the fields map is used.
+func Parse(data []byte, isJSON bool) (*models.Template, error) {
+	if !isJSON {
+		// Go through JSON so the models' field names and decoding apply
+		var doc interface{}
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("invalid YAML: %w", err)
+		}
+		if doc == nil {
+			return nil, fmt.Errorf("template file is empty")
+		}
+		converted, err := json.Marshal(doc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid YAML: %w", err)
+		}
+		data = converted
+	}
+
+	var tmpl models.Template
+	if err := json.Unmarshal(data, &tmpl); err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+
+	for key, field := range tmpl.Fields {
+		if field.ID == "" {
+			field.ID = key
+			tmpl.Fields[key] = field
+		}
+	}
+	return &tmpl, nil
+}
@@ -0,0 +1,113 @@
+package templating
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/nerveband/mochi-cli/pkg/mochi/models"
+)
+
+// FieldMap maps field IDs of a source template to field IDs of a target
+// template
+type FieldMap map[string]string
+
+// ParseFieldMap builds the mapping for migrating cards from one template to
+// another. spec is a comma-separated list of From=To pairs naming fields by
+// name or ID; source fields not listed are matched to a target field with
+// the same name. Source fields left without a target are an error unless
+// dropUnmapped is set, in which case their values are discarded.
+func ParseFieldMap(spec string, from, to *models.Template, dropUnmapped bool) (FieldMap, error) {
+	mapping := FieldMap{}
+	targets := map[string]string{} // target ID -> source ID
+	var problems []string
+
+	for _, pair := range strings.Split(spec, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		src, dst, ok := strings.Cut(pair, "=")
+		src, dst = strings.TrimSpace(src), strings.TrimSpace(dst)
+		if !ok || src == "" || dst == "" {
+			return nil, fmt.Errorf("invalid mapping %q (expected From=To)", pair)
+		}
+
+		srcField, ok := FindField(from, src)
+		if !ok {
+			problems = append(problems, fmt.Sprintf("template %s has no field %q (available: %s)", from.Name, src, DescribeFields(from)))
+			continue
+		}
+		dstField, ok := FindField(to, dst)
+		if !ok {
+			problems = append(problems, fmt.Sprintf("template %s has no field %q (available: %s)", to.Name, dst, DescribeFields(to)))
+			continue
+		}
+		if _, dup := mapping[srcField.ID]; dup {
+			problems = append(problems, fmt.Sprintf("field %q is mapped more than once", srcField.Name))
+			continue
+		}
+		if other, dup := targets[dstField.ID]; dup {
+			problems = append(problems, fmt.Sprintf("fields %q and %q both map to %q", from.Fields[other].Name, srcField.Name, dstField.Name))
+			continue
+		}
+		mapping[srcField.ID] = dstField.ID
+		targets[dstField.ID] = srcField.ID
+	}
+
+	// Fields with the same name map implicitly
+	var unmapped []string
+	for _, srcField := range SortedFields(from) {
+		if _, ok := mapping[srcField.ID]; ok {
+			continue
+		}
+		dstField, ok := FindField(to, srcField.Name)
+		if ok {
+			if _, taken := targets[dstField.ID]; !taken {
+				mapping[srcField.ID] = dstField.ID
+				targets[dstField.ID] = srcField.ID
+				continue
+			}
+		}
+		unmapped = append(unmapped, srcField.Name)
+	}
+	if len(unmapped) > 0 && !dropUnmapped {
+		problems = append(problems, fmt.Sprintf("no target for field(s) %s (map them with --map or use --drop-unmapped)",
+			strings.Join(quoteAll(unmapped), ", ")))
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("invalid field mapping: %s", strings.Join(problems, "; "))
+	}
+	return mapping, nil
+}
+
+// Apply rewrites card fields according to the mapping, dropping fields that
+// have no target
+func (m FieldMap) Apply(fields map[string]models.Field) map[string]models.Field {
+	out := make(map[string]models.Field, len(fields))
+	for id, field := range fields {
+		if dst, ok := m[id]; ok {
+			out[dst] = models.Field{ID: dst, Value: field.Value}
+		}
+	}
+	return out
+}
+
+// Describe lists the mapping as "Front -> Question" lines using field names
+func (m FieldMap) Describe(from, to *models.Template) []string {
+	lines := make([]string, 0, len(m))
+	for src, dst := range m {
+		lines = append(lines, fmt.Sprintf("%s -> %s", from.Fields[src].Name, to.Fields[dst].Name))
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+// quoteAll quotes each string
+func quoteAll(values []string) []string {
+	out := make([]string, len(values))
+	for i, v := range values {
+		out[i] = fmt.Sprintf("%q", v)
+	}
+	return out
+}
@@ -0,0 +1,232 @@
+package templating
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/nerveband/mochi-cli/pkg/mochi/models"
+)
+
+// Rendered is a template filled in with field values
+type Rendered struct {
+	// Sides holds the markdown of each card side
+	Sides []string `json:"sides"`
+	// Unknown lists placeholders that match no template field
+	Unknown []string `json:"unknown-placeholders,omitempty"`
+	// Missing lists fields referenced by the template that have no value
+	Missing []string `json:"missing-fields,omitempty"`
+}
+
+// sideSeparatorRe matches a line holding only "---", which separates sides
+var sideSeparatorRe = regexp.MustCompile(`(?m)^[ \t]*---[ \t]*$`)
+
+// UnknownPlaceholders returns the placeholders in a template's content that
+// reference no field of the template
+func UnknownPlaceholders(tmpl *models.Template) []string {
+	var unknown []string
+	for _, name := range Placeholders(tmpl.Content) {
+		if _, ok := FindField(tmpl, name); !ok {
+			unknown = append(unknown, name)
+		}
+	}
+	return unknown
+}
+
+// Render substitutes field values (keyed by field ID) into the template's
+// placeholders and splits the result into sides. Placeholders of unknown
+// fields are left as they are.
+func Render(tmpl *models.Template, fields map[string]models.Field) *Rendered {
+	r := &Rendered{Unknown: UnknownPlaceholders(tmpl)}
+
+	missing := map[string]bool{}
+	content := placeholderRe.ReplaceAllStringFunc(tmpl.Content, func(m string) string {
+		name := placeholderRe.FindStringSubmatch(m)[1]
+		field, ok := FindField(tmpl, name)
+		if !ok {
+			return m
+		}
+		value, ok := fields[field.ID]
+		if !ok || value.Value == "" {
+			if !missing[field.Name] {
+				missing[field.Name] = true
+				r.Missing = append(r.Missing, field.Name)
+			}
+			return ""
+		}
+		return value.Value
+	})
+
+	for _, side := range sideSeparatorRe.Split(content, -1) {
+		r.Sides = append(r.Sides, strings.Trim(side, "\n"))
+	}
+	return r
+}
+
+// Markdown returns the rendered card as markdown, sides separated by "---"
+func (r *Rendered) Markdown() string {
+	return strings.Join(r.Sides, "\n\n---\n\n") + "\n"
+}
+
+// HTML returns the rendered card as a standalone HTML document with one
+// section per side
+func (r *Rendered) HTML(title string) string {
+	var b strings.Builder
+	b.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	fmt.Fprintf(&b, "<title>%s</title>\n", html.EscapeString(title))
+	b.WriteString("<style>body{font-family:sans-serif;max-width:40em;margin:2em auto}" +
+		".side{border:1px solid #ddd;border-radius:6px;padding:1em;margin-bottom:1em}</style>\n")
+	b.WriteString("</head>\n<body>\n")
+	for i, side := range r.Sides {
+		fmt.Fprintf(&b, "<section class=\"side\" data-side=\"%d\">\n%s</section>\n", i+1, markdownToHTML(side))
+	}
+	b.WriteString("</body>\n</html>\n")
+	return b.String()
+}
+
+// ANSI returns the rendered card for a terminal, with markdown emphasis and
+// headings styled and a rule between sides
+func (r *Rendered) ANSI() string {
+	var b strings.Builder
+	for i, side := range r.Sides {
+		fmt.Fprintf(&b, "%s── side %d ──%s\n", ansiDim, i+1, ansiReset)
+		for _, line := range strings.Split(side, "\n") {
+			b.WriteString(markdownLineToANSI(line))
+			b.WriteByte('\n')
+		}
+	}
+	return b.String()
+}
+
+// ANSI escape sequences used by the terminal renderer
+const (
+	ansiReset  = "\x1b[0m"
+	ansiBold   = "\x1b[1m"
+	ansiDim    = "\x1b[2m"
+	ansiItalic = "\x1b[3m"
+	ansiCyan   = "\x1b[36m"
+	ansiYellow = "\x1b[33m"
+)
+
+// Inline markdown patterns shared by the HTML and ANSI renderers
+var (
+	headingRe = regexp.MustCompile(`^(#{1,6})\s+(.*)$`)
+	listRe    = regexp.MustCompile(`^\s*[-*+]\s+(.*)$`)
+	imageRe   = regexp.MustCompile(`!\[([^\]]*)\]\(([^)\s]+)\)`)
+	linkRe    = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	boldRe    = regexp.MustCompile(`\*\*([^*]+)\*\*|__([^_]+)__`)
+	italicRe  = regexp.MustCompile(`\*([^*]+)\*|\b_([^_]+)_\b`)
+	codeRe    = regexp.MustCompile("`([^`]+)`")
+)
+
+// markdownLineToANSI styles a single markdown line for the terminal
+func markdownLineToANSI(line string) string {
+	if m := headingRe.FindStringSubmatch(line); m != nil {
+		return ansiBold + ansiCyan + m[2] + ansiReset
+	}
+	if m := listRe.FindStringSubmatch(line); m != nil {
+		line = "  • " + m[1]
+	}
+	line = codeRe.ReplaceAllString(line, ansiYellow+"$1"+ansiReset)
+	line = imageRe.ReplaceAllString(line, ansiDim+"[image: $1 $2]"+ansiReset)
+	line = linkRe.ReplaceAllString(line, "$1 "+ansiDim+"($2)"+ansiReset)
+	line = boldRe.ReplaceAllString(line, ansiBold+"$1$2"+ansiReset)
+	line = italicRe.ReplaceAllString(line, ansiItalic+"$1$2"+ansiReset)
+	return line
+}
+
+// markdownToHTML converts the markdown subset used on cards (headings,
+// lists, paragraphs, emphasis, code, links and images) to HTML
+func markdownToHTML(md string) string {
+	var b strings.Builder
+	var para []string
+	inList := false
+
+	flushPara := func() {
+		if len(para) > 0 {
+			fmt.Fprintf(&b, "<p>%s</p>\n", strings.Join(para, "<br>\n"))
+			para = nil
+		}
+	}
+	closeList := func() {
+		if inList {
+			b.WriteString("</ul>\n")
+			inList = false
+		}
+	}
+
+	for _, line := range strings.Split(md, "\n") {
+		switch {
+		case strings.TrimSpace(line) == "":
+			flushPara()
+			closeList()
+		case headingRe.MatchString(line):
+			flushPara()
+			closeList()
+			m := headingRe.FindStringSubmatch(line)
+			fmt.Fprintf(&b, "<h%d>%s</h%d>\n", len(m[1]), inlineHTML(m[2]), len(m[1]))
+		case listRe.MatchString(line):
+			flushPara()
+			if !inList {
+				b.WriteString("<ul>\n")
+				inList = true
+			}
+			fmt.Fprintf(&b, "<li>%s</li>\n", inlineHTML(listRe.FindStringSubmatch(line)[1]))
+		default:
+			closeList()
+			para = append(para, inlineHTML(line))
+		}
+	}
+	flushPara()
+	closeList()
+	return b.String()
+}
+
+// inlineHTML escapes a line and converts inline markdown to HTML
+func inlineHTML(line string) string {
+	line = html.EscapeString(line)
+	line = codeRe.ReplaceAllString(line, "<code>$1</code>")
+	line = imageRe.ReplaceAllStringFunc(line, func(m string) string {
+		sub := imageRe.FindStringSubmatch(m)
+		if !safeURL(sub[2]) {
+			return m
+		}
+		return fmt.Sprintf(`<img src="%s" alt="%s">`, sub[2], sub[1])
+	})
+	line = linkRe.ReplaceAllStringFunc(line, func(m string) string {
+		sub := linkRe.FindStringSubmatch(m)
+		if !safeURL(sub[2]) {
+			return m
+		}
+		return fmt.Sprintf(`<a href="%s">%s</a>`, sub[2], sub[1])
+	})
+	line = boldRe.ReplaceAllString(line, "<strong>$1$2</strong>")
+	line = italicRe.ReplaceAllString(line, "<em>$1$2</em>")
+	return line
+}
+
+// safeURLSchemes are the schemes links and images may use in HTML output
+var safeURLSchemes = map[string]bool{"http": true, "https": true, "mailto": true}
+
+// safeURL reports whether an HTML-escaped link target may be put in an
+// href or src: a relative path (including @media/ attachments) or one of
+// safeURLSchemes. Anything else, such as javascript: or data:, is left as
+// text.
+func safeURL(target string) bool {
+	// Browsers ignore control characters inside a scheme
+	target = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return -1
+		}
+		return r
+	}, html.UnescapeString(target))
+	if strings.HasPrefix(target, "@media/") {
+		return true
+	}
+	i := strings.IndexAny(target, ":/?#")
+	if i < 0 || target[i] != ':' {
+		return true
+	}
+	return safeURLSchemes[strings.ToLower(target[:i])]
+}
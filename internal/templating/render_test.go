@@ -0,0 +1,45 @@
+package templating
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHTMLLinkTargets(t *testing.T) {
+	tests := []struct {
+		md   string
+		want string
+	}{
+		{"[site](https://example.com/a?b=1&c=2)", `<a href="https://example.com/a?b=1&amp;c=2">site</a>`},
+		{"[site](HTTP://example.com)", `<a href="HTTP://example.com">site</a>`},
+		{"[mail](mailto:a@example.com)", `<a href="mailto:a@example.com">mail</a>`},
+		{"[next](other/page.html#top)", `<a href="other/page.html#top">next</a>`},
+		{"[cdn](//cdn.example.com/x)", `<a href="//cdn.example.com/x">cdn</a>`},
+		{"[time](page?at=10:30)", `<a href="page?at=10:30">time</a>`},
+		{"![cat](@media/cat.png)", `<img src="@media/cat.png" alt="cat">`},
+		{"![cat](https://example.com/cat.png)", `<img src="https://example.com/cat.png" alt="cat">`},
+		{"[x](javascript:alert(1)", "[x](javascript:alert(1)"},
+		{"[x](JavaScript:void)", "[x](JavaScript:void)"},
+		{"[x](vbscript:msgbox)", "[x](vbscript:msgbox)"},
+		{"![x](data:image/svg+xml;base64,AAAA)", "![x](data:image/svg+xml;base64,AAAA)"},
+		{"[x](\x01javascript:alert)", "[x](\x01javascript:alert)"},
+	}
+	for _, tt := range tests {
+		got := markdownToHTML(tt.md)
+		if !strings.Contains(got, tt.want) {
+			t.Errorf("markdownToHTML(%q) = %q, want it to contain %q", tt.md, got, tt.want)
+		}
+		for _, attr := range []string{`href="`, `src="`} {
+			if !strings.HasPrefix(tt.want, "<") && strings.Contains(got, attr) {
+				t.Errorf("markdownToHTML(%q) = %q, want no %s", tt.md, got, attr)
+			}
+		}
+	}
+}
+
+func TestHTMLEscapesContent(t *testing.T) {
+	got := markdownToHTML(`<script>alert(1)</script> [a"b](x"onmouseover=y)`)
+	if strings.Contains(got, "<script>") || strings.Contains(got, `"onmouseover`) {
+		t.Errorf("markdownToHTML let markup through: %q", got)
+	}
+}
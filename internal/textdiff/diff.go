@@ -0,0 +1,146 @@
+// Package textdiff produces line-based unified diffs, used to preview the
+// changes bulk commands would make to card content.
+package textdiff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// op is one line of an edit script
+type op struct {
+	kind byte // ' ', '-' or '+'
+	line string
+}
+
+// Unified returns a unified diff from a to b with the given number of
+// context lines, or "" when they are equal. fromName and toName label the
+// two sides in the header.
+func Unified(a, b, fromName, toName string, context int) string {
+	if a == b {
+		return ""
+	}
+
+	ops := diffLines(splitLines(a), splitLines(b))
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromName, toName)
+
+	// Group changes into hunks with up to context unchanged lines around them
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+
+		start := max(0, i-context)
+		end := i
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			// Stop once the unchanged run is longer than two contexts
+			run := end
+			for run < len(ops) && ops[run].kind == ' ' {
+				run++
+			}
+			if run == len(ops) || run-end > 2*context {
+				end = min(len(ops), end+context)
+				break
+			}
+			end = run
+		}
+
+		writeHunk(&out, ops, start, end)
+		i = end
+	}
+	return out.String()
+}
+
+// writeHunk writes ops[start:end] as one hunk with its line ranges
+func writeHunk(out *strings.Builder, ops []op, start, end int) {
+	aStart, bStart := 1, 1
+	for _, o := range ops[:start] {
+		if o.kind != '+' {
+			aStart++
+		}
+		if o.kind != '-' {
+			bStart++
+		}
+	}
+
+	aLen, bLen := 0, 0
+	for _, o := range ops[start:end] {
+		if o.kind != '+' {
+			aLen++
+		}
+		if o.kind != '-' {
+			bLen++
+		}
+	}
+	// An empty range starts at the line before it
+	if aLen == 0 {
+		aStart--
+	}
+	if bLen == 0 {
+		bStart--
+	}
+
+	fmt.Fprintf(out, "@@ -%d,%d +%d,%d @@\n", aStart, aLen, bStart, bLen)
+	for _, o := range ops[start:end] {
+		fmt.Fprintf(out, "%c%s\n", o.kind, o.line)
+	}
+}
+
+// diffLines computes an edit script turning a into b from their longest
+// common subsequence. Card content is short, so the quadratic table is
+// cheap.
+func diffLines(a, b []string) []op {
+	n, m := len(a), len(b)
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var ops []op
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, op{' ', a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, op{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, op{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		ops = append(ops, op{'-', a[i]})
+	}
+	for ; j < m; j++ {
+		ops = append(ops, op{'+', b[j]})
+	}
+	return ops
+}
+
+// splitLines splits text into lines, ignoring a final newline
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
@@ -0,0 +1,294 @@
+package trace
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// HAR is the root of a HAR 1.2 document
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+// HARLog holds the recorded entries
+type HARLog struct {
+	Version string     `json:"version"`
+	Creator HARCreator `json:"creator"`
+	Entries []HAREntry `json:"entries"`
+}
+
+// HARCreator names the application that produced the file
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// HAREntry is one request/response exchange
+type HAREntry struct {
+	StartedDateTime time.Time   `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+	// Error is set when no response was received (a HAR custom field)
+	Error string `json:"_error,omitempty"`
+}
+
+// HARRequest describes the request of an entry
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+// HARResponse describes the response of an entry
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+// HARNameValue is a header, cookie or query parameter
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// HARPostData is a request body
+type HARPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	// Encoding is "base64" for binary bodies (a HAR custom field, as
+	// postData has no encoding of its own)
+	Encoding string `json:"_encoding,omitempty"`
+	// Comment notes a body that was truncated or not captured
+	Comment string `json:"comment,omitempty"`
+}
+
+// HARContent is a response body
+type HARContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+	// Comment notes a body that was truncated
+	Comment string `json:"comment,omitempty"`
+}
+
+// HARTimings breaks down the time of an entry. Only the wait for the
+// response is measured; the other phases are reported as unknown (-1) or 0.
+type HARTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+	SSL     float64 `json:"ssl"`
+}
+
+// Recorder is an http.RoundTripper that records every exchange for a HAR
+// file. Credential headers are scrubbed; bodies are kept up to
+// MaxCapturedBody bytes, and multipart or binary request bodies are left out
+// so uploads keep streaming. It is safe for concurrent use.
+type Recorder struct {
+	// Base performs the requests; nil uses http.DefaultTransport
+	Base http.RoundTripper
+
+	creator HARCreator
+	mu      sync.Mutex
+	entries []HAREntry
+}
+
+// NewRecorder creates a Recorder whose HAR file names the given application
+func NewRecorder(base http.RoundTripper, name, version string) *Recorder {
+	return &Recorder{
+		Base:    base,
+		creator: HARCreator{Name: name, Version: version},
+	}
+}
+
+// RoundTrip implements http.RoundTripper
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, send, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	resp, err := base(r.Base).RoundTrip(send)
+
+	var respBody capturedBody
+	if err == nil {
+		if respBody, err = readResponseBody(resp); err != nil {
+			resp = nil
+		}
+	}
+	elapsed := msSince(start)
+
+	entry := HAREntry{
+		StartedDateTime: start,
+		Time:            elapsed,
+		Request:         harRequest(req, reqBody),
+		Timings:         HARTimings{Blocked: -1, DNS: -1, Connect: -1, SSL: -1, Wait: elapsed},
+	}
+	if err != nil {
+		entry.Error = err.Error()
+		entry.Response = HARResponse{
+			HTTPVersion: req.Proto,
+			Cookies:     []HARNameValue{},
+			Headers:     []HARNameValue{},
+			HeadersSize: -1,
+			BodySize:    -1,
+		}
+	} else {
+		entry.Response = harResponse(resp, respBody)
+	}
+
+	r.mu.Lock()
+	r.entries = append(r.entries, entry)
+	r.mu.Unlock()
+
+	return resp, err
+}
+
+// Len returns the number of recorded entries
+func (r *Recorder) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.entries)
+}
+
+// HAR returns the recorded session
+func (r *Recorder) HAR() *HAR {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entries := make([]HAREntry, len(r.entries))
+	copy(entries, r.entries)
+	return &HAR{Log: HARLog{Version: "1.2", Creator: r.creator, Entries: entries}}
+}
+
+// WriteFile writes the recorded session to path as a HAR file
+func (r *Recorder) WriteFile(path string) error {
+	data, err := json.MarshalIndent(r.HAR(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0600)
+}
+
+// harRequest converts a request and its body to a HAR request
+func harRequest(req *http.Request, body capturedBody) HARRequest {
+	out := HARRequest{
+		Method:      req.Method,
+		URL:         req.URL.String(),
+		HTTPVersion: req.Proto,
+		Cookies:     []HARNameValue{},
+		Headers:     harHeaders(req.Header),
+		QueryString: []HARNameValue{},
+		HeadersSize: -1,
+		BodySize:    int(body.size),
+	}
+	for _, name := range sortedKeys(req.URL.Query()) {
+		for _, value := range req.URL.Query()[name] {
+			out.QueryString = append(out.QueryString, HARNameValue{Name: name, Value: value})
+		}
+	}
+	switch {
+	case body.skipped:
+		out.PostData = &HARPostData{
+			MimeType: req.Header.Get("Content-Type"),
+			Comment:  fmt.Sprintf("body of %s not captured", body.describeSize()),
+		}
+	case len(body.data) > 0:
+		out.PostData = &HARPostData{MimeType: req.Header.Get("Content-Type")}
+		out.PostData.Text, out.PostData.Encoding, out.PostData.Comment = encodeBody(out.PostData.MimeType, body)
+	}
+	return out
+}
+
+// harResponse converts a response and its body to a HAR response
+func harResponse(resp *http.Response, body capturedBody) HARResponse {
+	out := HARResponse{
+		Status:      resp.StatusCode,
+		StatusText:  http.StatusText(resp.StatusCode),
+		HTTPVersion: resp.Proto,
+		Cookies:     []HARNameValue{},
+		Headers:     harHeaders(resp.Header),
+		Content: HARContent{
+			Size:     int(body.size),
+			MimeType: resp.Header.Get("Content-Type"),
+		},
+		RedirectURL: resp.Header.Get("Location"),
+		HeadersSize: -1,
+		BodySize:    int(body.size),
+	}
+	out.Content.Text, out.Content.Encoding, out.Content.Comment = encodeBody(out.Content.MimeType, body)
+	return out
+}
+
+// harHeaders converts headers to sorted name/value pairs with credentials
+// scrubbed
+func harHeaders(h http.Header) []HARNameValue {
+	h = RedactHeaders(h)
+	out := []HARNameValue{}
+	for _, name := range sortedKeys(h) {
+		for _, value := range h[name] {
+			out = append(out, HARNameValue{Name: name, Value: value})
+		}
+	}
+	return out
+}
+
+// encodeBody returns a body as text, or base64 with its encoding for
+// binary data, and a comment when it was truncated. Truncated JSON can't be
+// parsed to redact it, so its text is left out.
+func encodeBody(contentType string, body capturedBody) (string, string, string) {
+	comment := ""
+	if body.truncated {
+		if isJSON(contentType) {
+			return "", "", fmt.Sprintf("body of %s not captured", body.describeSize())
+		}
+		comment = fmt.Sprintf("truncated to the first %d of %s", len(body.data), body.describeSize())
+	}
+	if isText(contentType) && utf8.Valid(body.data) {
+		return string(RedactBody(body.data)), "", comment
+	}
+	return base64.StdEncoding.EncodeToString(body.data), "base64", comment
+}
+
+// sortedKeys returns the keys of a header or query map in order
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// msSince returns the milliseconds elapsed since start
+func msSince(start time.Time) float64 {
+	return float64(time.Since(start).Microseconds()) / 1000
+}
@@ -0,0 +1,295 @@
+// Package trace provides HTTP transports for debugging API interactions: a
+// Logger that prints each request to a writer and a Recorder that captures
+// the session as a HAR file. Credentials are never written out.
+package trace
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MaxLoggedBody is the number of body bytes printed by the Logger
+const MaxLoggedBody = 4096
+
+// MaxCapturedBody is the number of body bytes read for the Logger or a HAR
+// file. Longer bodies are still sent and received in full.
+const MaxCapturedBody = 1 << 20
+
+// redacted replaces secret values in logs and HAR files
+const redacted = "[REDACTED]"
+
+// sensitiveHeaders are scrubbed from every logged or recorded request
+var sensitiveHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+}
+
+// sensitiveKeys are JSON object keys whose values are scrubbed from bodies
+var sensitiveKeys = []string{"api_key", "api-key", "apikey", "password", "secret", "token"}
+
+// Logger is an http.RoundTripper that writes one line per request with its
+// method, URL, status and latency, optionally followed by the bodies
+type Logger struct {
+	// Base performs the requests; nil uses http.DefaultTransport
+	Base http.RoundTripper
+	// Out receives the log lines
+	Out io.Writer
+	// Bodies also logs redacted request and response bodies
+	Bodies bool
+
+	mu sync.Mutex
+}
+
+// NewLogger creates a Logger writing to out
+func NewLogger(base http.RoundTripper, out io.Writer, bodies bool) *Logger {
+	return &Logger{Base: base, Out: out, Bodies: bodies}
+}
+
+// RoundTrip implements http.RoundTripper
+func (l *Logger) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody capturedBody
+	send := req
+	if l.Bodies {
+		var err error
+		if reqBody, send, err = readRequestBody(req); err != nil {
+			return nil, err
+		}
+	}
+
+	start := time.Now()
+	resp, err := base(l.Base).RoundTrip(send)
+	elapsed := time.Since(start).Round(time.Millisecond)
+
+	var respBody capturedBody
+	if err == nil && l.Bodies {
+		if respBody, err = readResponseBody(resp); err != nil {
+			resp = nil
+		}
+	}
+
+	var b strings.Builder
+	if err != nil {
+		fmt.Fprintf(&b, "[trace] %s %s -> error: %v (%s)\n", req.Method, req.URL.Redacted(), err, elapsed)
+	} else {
+		fmt.Fprintf(&b, "[trace] %s %s -> %s (%s)\n", req.Method, req.URL.Redacted(), resp.Status, elapsed)
+	}
+	if l.Bodies {
+		if len(reqBody.data) > 0 || reqBody.skipped {
+			fmt.Fprintf(&b, "[trace]   request body: %s\n", formatBody(req.Header.Get("Content-Type"), reqBody))
+		}
+		if resp != nil && len(respBody.data) > 0 {
+			fmt.Fprintf(&b, "[trace]   response body: %s\n", formatBody(resp.Header.Get("Content-Type"), respBody))
+		}
+	}
+
+	l.mu.Lock()
+	io.WriteString(l.Out, b.String())
+	l.mu.Unlock()
+
+	return resp, err
+}
+
+// base returns rt, or http.DefaultTransport when rt is nil
+func base(rt http.RoundTripper) http.RoundTripper {
+	if rt == nil {
+		return http.DefaultTransport
+	}
+	return rt
+}
+
+// capturedBody is the part of a request or response body kept for the log
+// or a HAR file
+type capturedBody struct {
+	data []byte
+	// size is the full length of the body, or -1 when unknown
+	size int64
+	// truncated is set when data holds only the first MaxCapturedBody bytes
+	truncated bool
+	// skipped is set when the body wasn't read at all
+	skipped bool
+}
+
+// describeSize returns the length of the body for messages
+func (c capturedBody) describeSize() string {
+	switch {
+	case c.size >= 0:
+		return fmt.Sprintf("%d bytes", c.size)
+	case c.truncated:
+		return fmt.Sprintf("over %d bytes", len(c.data))
+	default:
+		return "unknown size"
+	}
+}
+
+// readRequestBody returns a copy of the start of the request body and the
+// request to send in place of req. A body that can't be read again through
+// GetBody is consumed, so the returned request is a clone replaying it;
+// req itself is never modified, as http.RoundTripper requires. Multipart
+// and binary bodies, such as attachment uploads, are skipped so they keep
+// streaming.
+func readRequestBody(req *http.Request) (capturedBody, *http.Request, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return capturedBody{}, req, nil
+	}
+	if !isText(req.Header.Get("Content-Type")) {
+		return capturedBody{size: req.ContentLength, skipped: true}, req, nil
+	}
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return capturedBody{}, nil, err
+		}
+		captured, rest, err := captureBody(body, req.ContentLength)
+		if err != nil {
+			return capturedBody{}, nil, err
+		}
+		rest.Close()
+		return captured, req, nil
+	}
+
+	captured, body, err := captureBody(req.Body, req.ContentLength)
+	if err != nil {
+		return capturedBody{}, nil, err
+	}
+	clone := req.Clone(req.Context())
+	clone.Body = body
+	return captured, clone, nil
+}
+
+// readResponseBody reads the start of the response body and replaces the
+// body with one the caller can still consume in full
+func readResponseBody(resp *http.Response) (capturedBody, error) {
+	captured, body, err := captureBody(resp.Body, resp.ContentLength)
+	if err != nil {
+		return capturedBody{}, err
+	}
+	resp.Body = body
+	return captured, nil
+}
+
+// captureBody reads up to MaxCapturedBody bytes of body, whose length is
+// size (-1 if unknown). It returns them and a reader replaying the whole
+// body, which takes over closing it. On error the body is closed.
+func captureBody(body io.ReadCloser, size int64) (capturedBody, io.ReadCloser, error) {
+	data, err := io.ReadAll(io.LimitReader(body, MaxCapturedBody+1))
+	if err != nil {
+		body.Close()
+		return capturedBody{}, nil, err
+	}
+	if len(data) > MaxCapturedBody {
+		captured := capturedBody{data: data[:MaxCapturedBody], size: size, truncated: true}
+		rest := struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(data), body), body}
+		return captured, rest, nil
+	}
+	body.Close()
+	return capturedBody{data: data, size: int64(len(data))}, io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// RedactHeaders returns a copy of h with credentials replaced
+func RedactHeaders(h http.Header) http.Header {
+	out := h.Clone()
+	for name := range out {
+		if sensitiveHeaders[http.CanonicalHeaderKey(name)] {
+			out[name] = []string{redacted}
+		}
+	}
+	return out
+}
+
+// RedactBody scrubs secret-looking values from a JSON body. Other bodies are
+// returned unchanged.
+func RedactBody(data []byte) []byte {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return data
+	}
+	if !redactValue(v) {
+		return data
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		return data
+	}
+	return out
+}
+
+// redactValue scrubs sensitive keys in place and reports whether any were found
+func redactValue(v interface{}) bool {
+	found := false
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			if isSensitiveKey(k) {
+				v[k] = redacted
+				found = true
+			} else if redactValue(child) {
+				found = true
+			}
+		}
+	case []interface{}:
+		for _, child := range v {
+			if redactValue(child) {
+				found = true
+			}
+		}
+	}
+	return found
+}
+
+// isSensitiveKey reports whether a JSON key names a secret
+func isSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, s := range sensitiveKeys {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// isText reports whether a content type holds human-readable data
+func isText(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return contentType == ""
+	}
+	return strings.HasPrefix(mediaType, "text/") ||
+		strings.HasSuffix(mediaType, "json") ||
+		strings.HasSuffix(mediaType, "xml") ||
+		mediaType == "application/x-www-form-urlencoded"
+}
+
+// isJSON reports whether a content type holds JSON
+func isJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && strings.HasSuffix(mediaType, "json")
+}
+
+// formatBody renders a body for the log: redacted and truncated text, or a
+// size summary for binary data. Truncated JSON can't be parsed to redact it,
+// so it is summarized too.
+func formatBody(contentType string, body capturedBody) string {
+	if body.skipped || !isText(contentType) || (body.truncated && isJSON(contentType)) {
+		return fmt.Sprintf("<%s of %s>", body.describeSize(), contentType)
+	}
+	data := bytes.TrimSpace(RedactBody(body.data))
+	if len(data) > MaxLoggedBody || body.truncated {
+		if len(data) > MaxLoggedBody {
+			data = data[:MaxLoggedBody]
+		}
+		return fmt.Sprintf("%s... (%s total)", data, body.describeSize())
+	}
+	return string(data)
+}
@@ -0,0 +1,77 @@
+package trace
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// roundTripFunc adapts a function to http.RoundTripper
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// echo answers every request with its body, so tests see what was sent
+var echo = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		StatusCode: 200,
+		Status:     "200 OK",
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader(body)),
+		Request:    req,
+	}, nil
+})
+
+func TestRoundTripLeavesRequestAlone(t *testing.T) {
+	transports := map[string]http.RoundTripper{
+		"logger":   NewLogger(echo, io.Discard, true),
+		"recorder": NewRecorder(echo, "test", "1"),
+	}
+	for name, rt := range transports {
+		for _, withGetBody := range []bool{false, true} {
+			const payload = `{"content":"hola"}`
+			req, _ := http.NewRequest("POST", "http://example.com/api/cards", strings.NewReader(payload))
+			req.Header.Set("Content-Type", "application/json")
+			if !withGetBody {
+				req.Body = io.NopCloser(strings.NewReader(payload))
+				req.GetBody = nil
+			}
+			original := req.Body
+
+			resp, err := rt.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			if got, _ := io.ReadAll(resp.Body); string(got) != payload {
+				t.Errorf("%s, GetBody %v: sent %q, want %q", name, withGetBody, got, payload)
+			}
+			if req.Body != original {
+				t.Errorf("%s, GetBody %v: the caller's request body was replaced", name, withGetBody)
+			}
+		}
+	}
+}
+
+func TestLoggerRedacts(t *testing.T) {
+	var out strings.Builder
+	req, _ := http.NewRequest("POST", "http://example.com/api/cards", strings.NewReader(`{"content":"hola","token":"s3cret"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Basic s3cret")
+	if _, err := NewLogger(echo, &out, true).RoundTrip(req); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out.String(), "s3cret") {
+		t.Errorf("log contains the secret:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "hola") || !strings.Contains(out.String(), "200 OK") {
+		t.Errorf("log lacks the body or status:\n%s", out.String())
+	}
+}
@@ -0,0 +1,1279 @@
+package mochi
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/nerveband/mochi-cli/pkg/mochi/models"
+)
+
+// DefaultBaseURL is the production Mochi API endpoint
+const DefaultBaseURL = "https://app.mochi.cards/api"
+
+const (
+	// DefaultTimeout bounds a single HTTP request, including reading the body
+	DefaultTimeout = 30 * time.Second
+	// DefaultUserAgent identifies SDK requests unless WithUserAgent is used
+	DefaultUserAgent = "mochi-go"
+)
+
+// Client represents the Mochi API client. It is safe for concurrent use once
+// configured.
+type Client struct {
+	apiKey     string
+	baseURL    string
+	userAgent  string
+	httpClient *http.Client
+	ctx        context.Context
+	retry      RetryPolicy
+	limiter    *RateLimiter
+	logf       func(format string, args ...interface{})
+	onWarning  func(w models.DecodeWarning)
+}
+
+// NewClient creates a new API client authenticating with apiKey. Options
+// are applied in order.
+func NewClient(apiKey string, opts ...Option) *Client {
+	c := &Client{
+		apiKey:    apiKey,
+		baseURL:   DefaultBaseURL,
+		userAgent: DefaultUserAgent,
+		httpClient: &http.Client{
+			Timeout: DefaultTimeout,
+		},
+		retry: DefaultRetryPolicy(),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// SetBaseURL points the client at a different API host, such as a staging
+// server, a recording proxy or a local fake server. An empty string restores
+// the default.
+func (c *Client) SetBaseURL(baseURL string) {
+	baseURL = strings.TrimRight(baseURL, "/")
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	c.baseURL = baseURL
+}
+
+// BaseURL returns the API base URL the client sends requests to
+func (c *Client) BaseURL() string {
+	return c.baseURL
+}
+
+// SetTransport replaces the HTTP transport requests are sent through, for
+// example to trace or record them. nil restores http.DefaultTransport.
+func (c *Client) SetTransport(rt http.RoundTripper) {
+	c.httpClient.Transport = rt
+}
+
+// Transport returns the HTTP transport requests are sent through
+func (c *Client) Transport() http.RoundTripper {
+	if c.httpClient.Transport == nil {
+		return http.DefaultTransport
+	}
+	return c.httpClient.Transport
+}
+
+// SetRetryPolicy replaces the client's retry policy
+func (c *Client) SetRetryPolicy(policy RetryPolicy) {
+	c.retry = policy
+}
+
+// RetryPolicy returns the client's retry policy
+func (c *Client) RetryPolicy() RetryPolicy {
+	return c.retry
+}
+
+// SetRateLimit limits the client to rate requests per second with bursts of
+// up to burst requests. A rate of 0 removes the limit.
+func (c *Client) SetRateLimit(rate float64, burst int) {
+	if rate <= 0 {
+		c.limiter = nil
+		return
+	}
+	c.limiter = NewRateLimiter(rate, burst)
+}
+
+// SetLogger sets a function that receives diagnostic messages about retries
+// and rate limiting. A nil logger disables them.
+func (c *Client) SetLogger(logf func(format string, args ...interface{})) {
+	c.logf = logf
+}
+
+// SetWarningHandler sets a function called for each listed document that
+// could not be decoded. Such documents are left out of the page and also
+// recorded in its Warnings.
+func (c *Client) SetWarningHandler(fn func(w models.DecodeWarning)) {
+	c.onWarning = fn
+}
+
+// reportWarnings passes decode warnings to the warning handler, if any
+func (c *Client) reportWarnings(warnings []models.DecodeWarning) {
+	if c.onWarning == nil {
+		return
+	}
+	for _, w := range warnings {
+		c.onWarning(w)
+	}
+}
+
+// debugf sends a diagnostic message to the logger, if any
+func (c *Client) debugf(format string, args ...interface{}) {
+	if c.logf != nil {
+		c.logf(format, args...)
+	}
+}
+
+// WithContext returns a shallow copy of the client whose methods without a
+// context argument use ctx, so cancelling ctx aborts their in-flight requests
+func (c *Client) WithContext(ctx context.Context) *Client {
+	if ctx == nil {
+		panic("nil context")
+	}
+	c2 := *c
+	c2.ctx = ctx
+	return &c2
+}
+
+// context returns the client's default context
+func (c *Client) context() context.Context {
+	if c.ctx != nil {
+		return c.ctx
+	}
+	return context.Background()
+}
+
+// setAuth sets the Authorization header for basic auth
+func (c *Client) setAuth(req *http.Request) {
+	// Basic auth: username is API key, no password
+	auth := base64.StdEncoding.EncodeToString([]byte(c.apiKey + ":"))
+	req.Header.Set("Authorization", "Basic "+auth)
+}
+
+// doRequest performs an HTTP request and returns the response. Requests wait
+// for the rate limiter and are retried according to the retry policy.
+func (c *Client) doRequest(req *http.Request) (*http.Response, error) {
+	req.Header.Set("User-Agent", c.userAgent)
+	if req.Header.Get("Accept") == "" {
+		req.Header.Set("Accept", "application/json")
+	}
+
+	for attempt := 0; ; attempt++ {
+		waited, err := c.limiter.Wait(req.Context())
+		if err != nil {
+			return nil, err
+		}
+		if waited > 0 {
+			c.debugf("rate limit: waited %s before %s %s", waited.Round(time.Millisecond), req.Method, req.URL.Path)
+		}
+
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+
+		resp, err := c.httpClient.Do(req)
+
+		delay, retry := c.retry.shouldRetry(req, resp, err, attempt)
+		if !retry {
+			return resp, err
+		}
+
+		var reason string
+		if err != nil {
+			reason = err.Error()
+		} else {
+			reason = resp.Status
+			discard(resp)
+		}
+		c.debugf("retry %d/%d: %s %s failed (%s), waiting %s",
+			attempt+1, c.retry.MaxRetries, req.Method, req.URL.Path, reason, delay.Round(time.Millisecond))
+
+		if err := sleep(req.Context(), delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// handleError converts a non-2xx response into an *Error
+func handleError(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+
+	apiErr := &Error{
+		StatusCode: resp.StatusCode,
+		Category:   categorize(resp.StatusCode),
+	}
+	if resp.Request != nil {
+		apiErr.Method = resp.Request.Method
+		apiErr.Path = resp.Request.URL.Path
+	}
+
+	var errResp models.ErrorResponse
+	if err := json.Unmarshal(body, &errResp); err == nil && errResp.Errors != nil {
+		apiErr.Errors = errResp.Errors
+	} else {
+		apiErr.Body = string(body)
+	}
+
+	return apiErr
+}
+
+// === Card Operations ===
+
+// ListCards is like ListCardsContext using the client's default context
+func (c *Client) ListCards(deckID string, limit int, bookmark string) (*models.CardPage, error) {
+	return c.ListCardsContext(c.context(), deckID, limit, bookmark)
+}
+
+// ListCardsContext lists cards with optional filtering
+func (c *Client) ListCardsContext(ctx context.Context, deckID string, limit int, bookmark string) (*models.CardPage, error) {
+	params := url.Values{}
+	if deckID != "" {
+		params.Set("deck-id", deckID)
+	}
+	if limit > 0 {
+		params.Set("limit", fmt.Sprintf("%d", limit))
+	}
+	if bookmark != "" {
+		params.Set("bookmark", bookmark)
+	}
+
+	url := c.baseURL + "/cards"
+	if len(params) > 0 {
+		url = url + "?" + params.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	c.setAuth(req)
+
+	resp, err := c.doRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := handleError(resp); err != nil {
+		return nil, err
+	}
+
+	var result models.CardPage
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	c.reportWarnings(result.Warnings)
+
+	return &result, nil
+}
+
+// GetCard is like GetCardContext using the client's default context
+func (c *Client) GetCard(cardID string) (*models.Card, error) {
+	return c.GetCardContext(c.context(), cardID)
+}
+
+// GetCardContext retrieves a specific card
+func (c *Client) GetCardContext(ctx context.Context, cardID string) (*models.Card, error) {
+	url := c.baseURL + "/cards/" + cardID
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	c.setAuth(req)
+
+	resp, err := c.doRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := handleError(resp); err != nil {
+		return nil, err
+	}
+
+	return decodeCard(resp)
+}
+
+// CreateCard is like CreateCardContext using the client's default context
+func (c *Client) CreateCard(card *models.Card) (*models.Card, error) {
+	return c.CreateCardContext(c.context(), card)
+}
+
+// CreateCardContext creates a new card
+func (c *Client) CreateCardContext(ctx context.Context, card *models.Card) (*models.Card, error) {
+	url := c.baseURL + "/cards"
+
+	payload := map[string]interface{}{
+		"deck-id": card.DeckID,
+		"content": card.Content,
+	}
+	if card.Name != "" {
+		payload["name"] = card.Name
+	}
+	if card.TemplateID != "" {
+		payload["template-id"] = card.TemplateID
+	}
+	if len(card.Fields) > 0 {
+		payload["fields"] = card.Fields
+	}
+	if len(card.ManualTags) > 0 {
+		payload["manual-tags"] = card.ManualTags
+	}
+	if card.Pos != "" {
+		payload["pos"] = card.Pos
+	}
+	if card.ReviewReverse {
+		payload["review-reverse?"] = true
+	}
+	if card.Archived {
+		payload["archived?"] = true
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+
+	c.setAuth(req)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.doRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := handleError(resp); err != nil {
+		return nil, err
+	}
+
+	return decodeCard(resp)
+}
+
+// UpdateCard is like UpdateCardContext using the client's default context
+func (c *Client) UpdateCard(cardID string, patch models.CardPatch) (*models.Card, error) {
+	return c.UpdateCardContext(c.context(), cardID, patch)
+}
+
+// UpdateCardContext applies a partial update to a card. Fields not set in
+// patch are left unchanged.
+func (c *Client) UpdateCardContext(ctx context.Context, cardID string, patch models.CardPatch) (*models.Card, error) {
+	return c.UpdateCardFieldsContext(ctx, cardID, patch.Payload())
+}
+
+// UpdateCardFields is like UpdateCardFieldsContext using the client's default context
+func (c *Client) UpdateCardFields(cardID string, payload map[string]interface{}) (*models.Card, error) {
+	return c.UpdateCardFieldsContext(c.context(), cardID, payload)
+}
+
+// UpdateCardFieldsContext sends a raw update payload for a card
+func (c *Client) UpdateCardFieldsContext(ctx context.Context, cardID string, payload map[string]interface{}) (*models.Card, error) {
+	url := c.baseURL + "/cards/" + cardID
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+
+	c.setAuth(req)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.doRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := handleError(resp); err != nil {
+		return nil, err
+	}
+
+	return decodeCard(resp)
+}
+
+// decodeCard decodes the complete card returned by a card endpoint
+func decodeCard(resp *http.Response) (*models.Card, error) {
+	var card models.Card
+	if err := json.NewDecoder(resp.Body).Decode(&card); err != nil {
+		return nil, fmt.Errorf("failed to decode card: %w", err)
+	}
+	return &card, nil
+}
+
+// DeleteCard is like DeleteCardContext using the client's default context
+func (c *Client) DeleteCard(cardID string) error {
+	return c.DeleteCardContext(c.context(), cardID)
+}
+
+// DeleteCardContext permanently deletes a card
+func (c *Client) DeleteCardContext(ctx context.Context, cardID string) error {
+	url := c.baseURL + "/cards/" + cardID
+
+	req, err := http.NewRequestWithContext(ctx, "DELETE", url, nil)
+	if err != nil {
+		return err
+	}
+
+	c.setAuth(req)
+
+	resp, err := c.doRequest(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return handleError(resp)
+}
+
+// AttachmentUpload is a file to attach to a card
+type AttachmentUpload struct {
+	// Filename is the attachment's name on the card
+	Filename string
+	// ContentType is sent with the file; when empty it is detected from the
+	// content and the filename
+	ContentType string
+	// Body supplies the file content. It is streamed, not buffered. If it
+	// is an io.Seeker the upload can be retried; otherwise it is sent once.
+	Body io.Reader
+	// Size is the length of Body, or 0 when unknown. A known size lets the
+	// request declare its Content-Length and Progress report a total.
+	Size int64
+	// Progress, when set, is called as the content is sent with the bytes
+	// sent so far and Size
+	Progress func(sent, total int64)
+}
+
+// AddAttachment is like AddAttachmentContext using the client's default context
+func (c *Client) AddAttachment(cardID string, filename string, fileData []byte) error {
+	return c.AddAttachmentContext(c.context(), cardID, filename, fileData)
+}
+
+// AddAttachmentContext adds an attachment to a card
+func (c *Client) AddAttachmentContext(ctx context.Context, cardID string, filename string, fileData []byte) error {
+	return c.UploadAttachmentContext(ctx, cardID, AttachmentUpload{
+		Filename: filename,
+		Body:     bytes.NewReader(fileData),
+		Size:     int64(len(fileData)),
+	})
+}
+
+// UploadAttachment is like UploadAttachmentContext using the client's default context
+func (c *Client) UploadAttachment(cardID string, upload AttachmentUpload) error {
+	return c.UploadAttachmentContext(c.context(), cardID, upload)
+}
+
+// UploadAttachmentContext adds an attachment to a card, sending its content
+// type with the file. The multipart body is produced through an io.Pipe as
+// the request is sent, so the file is never held in memory.
+func (c *Client) UploadAttachmentContext(ctx context.Context, cardID string, upload AttachmentUpload) error {
+	endpoint := fmt.Sprintf("%s/cards/%s/attachments/%s", c.baseURL, cardID, url.PathEscape(upload.Filename))
+
+	// A seekable body is rewound to where it started for each attempt
+	seeker, offset, seekable := seekableBody(upload.Body)
+
+	src, contentType, err := sniffUpload(upload, seekable)
+	if err != nil {
+		return err
+	}
+
+	// Every attempt must use the same boundary as the Content-Type header
+	boundary := multipart.NewWriter(io.Discard).Boundary()
+	newBody := func() io.ReadCloser {
+		return &pipeBody{start: func() (io.ReadCloser, error) {
+			if seekable {
+				if _, err := seeker.Seek(offset, io.SeekStart); err != nil {
+					return nil, err
+				}
+			}
+			pr, pw := io.Pipe()
+			go func() {
+				pw.CloseWithError(writeMultipart(pw, boundary, upload, contentType, src))
+			}()
+			return pr, nil
+		}}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, newBody())
+	if err != nil {
+		return err
+	}
+	if seekable {
+		req.GetBody = func() (io.ReadCloser, error) { return newBody(), nil }
+	}
+	if upload.Size > 0 {
+		req.ContentLength = multipartLength(boundary, upload.Filename, contentType, upload.Size)
+	}
+
+	c.setAuth(req)
+	req.Header.Set("Content-Type", "multipart/form-data; boundary="+boundary)
+
+	resp, err := c.doRequest(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return handleError(resp)
+}
+
+// sniffUpload returns the upload's content and content type, detecting the
+// type from the first bytes when none is given. Seekable bodies are moved
+// back after sniffing; others are buffered just enough to peek.
+func sniffUpload(upload AttachmentUpload, seekable bool) (io.Reader, string, error) {
+	if upload.ContentType != "" {
+		return upload.Body, upload.ContentType, nil
+	}
+
+	if rs, ok := upload.Body.(io.ReadSeeker); ok && seekable {
+		head := make([]byte, 512)
+		n, err := io.ReadFull(rs, head)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return nil, "", err
+		}
+		if _, err := rs.Seek(int64(-n), io.SeekCurrent); err != nil {
+			return nil, "", err
+		}
+		return rs, DetectContentType(upload.Filename, head[:n]), nil
+	}
+
+	br := bufio.NewReader(upload.Body)
+	head, err := br.Peek(512)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, "", err
+	}
+	return br, DetectContentType(upload.Filename, head), nil
+}
+
+// seekableBody returns r as a Seeker with its current offset, if it can
+// actually seek; files such as a piped stdin implement io.Seeker but fail
+func seekableBody(r io.Reader) (io.Seeker, int64, bool) {
+	seeker, ok := r.(io.Seeker)
+	if !ok {
+		return nil, 0, false
+	}
+	offset, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, 0, false
+	}
+	return seeker, offset, true
+}
+
+// writeMultipart writes the multipart form holding the upload to w
+func writeMultipart(w io.Writer, boundary string, upload AttachmentUpload, contentType string, src io.Reader) error {
+	mw := multipart.NewWriter(w)
+	if err := mw.SetBoundary(boundary); err != nil {
+		return err
+	}
+
+	part, err := mw.CreatePart(filePartHeader(upload.Filename, contentType))
+	if err != nil {
+		return err
+	}
+
+	if upload.Progress != nil {
+		src = &progressReader{r: src, total: upload.Size, report: upload.Progress}
+	}
+	if _, err := io.Copy(part, src); err != nil {
+		return err
+	}
+
+	return mw.Close()
+}
+
+// multipartLength returns the length of the form writeMultipart produces
+// for content of the given size
+func multipartLength(boundary, filename, contentType string, size int64) int64 {
+	var framing bytes.Buffer
+	mw := multipart.NewWriter(&framing)
+	_ = mw.SetBoundary(boundary)
+	_, _ = mw.CreatePart(filePartHeader(filename, contentType))
+	_ = mw.Close()
+	return int64(framing.Len()) + size
+}
+
+// pipeBody is a request body that starts producing its content on the first
+// Read. Bodies made for retries or by GetBody callers therefore don't touch
+// the source until they are actually sent.
+type pipeBody struct {
+	start func() (io.ReadCloser, error)
+	r     io.ReadCloser
+}
+
+// Read implements io.Reader
+func (b *pipeBody) Read(p []byte) (int, error) {
+	if b.r == nil {
+		r, err := b.start()
+		if err != nil {
+			return 0, err
+		}
+		b.r = r
+	}
+	return b.r.Read(p)
+}
+
+// Close implements io.Closer, stopping the writer if it was started
+func (b *pipeBody) Close() error {
+	if b.r == nil {
+		return nil
+	}
+	return b.r.Close()
+}
+
+// progressReader reports the bytes read through it
+type progressReader struct {
+	r      io.Reader
+	sent   int64
+	total  int64
+	report func(sent, total int64)
+}
+
+// Read implements io.Reader
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.sent += int64(n)
+		p.report(p.sent, p.total)
+	}
+	return n, err
+}
+
+// AddAttachmentFromFile is like AddAttachmentFromFileContext using the client's default context
+func (c *Client) AddAttachmentFromFile(cardID string, filePath string) error {
+	return c.AddAttachmentFromFileContext(c.context(), cardID, filePath)
+}
+
+// AddAttachmentFromFileContext adds an attachment from a file path, named
+// after the file. The file is streamed.
+func (c *Client) AddAttachmentFromFileContext(ctx context.Context, cardID string, filePath string) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	return c.UploadAttachmentContext(ctx, cardID, AttachmentUpload{
+		Filename: filepath.Base(filePath),
+		Body:     f,
+		Size:     info.Size(),
+	})
+}
+
+// DetectContentType guesses the MIME type of a file from the first 512
+// bytes of its content, falling back to its extension when the content
+// alone is inconclusive
+func DetectContentType(filename string, head []byte) string {
+	sniffed := http.DetectContentType(head)
+	if sniffed != "application/octet-stream" && !strings.HasPrefix(sniffed, "text/plain") {
+		return sniffed
+	}
+	if byExt := mime.TypeByExtension(strings.ToLower(filepath.Ext(filename))); byExt != "" {
+		return byExt
+	}
+	return sniffed
+}
+
+// quoteEscaper escapes a filename for a Content-Disposition header
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// filePartHeader returns the MIME header of the multipart "file" field
+func filePartHeader(filename, contentType string) textproto.MIMEHeader {
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename="%s"`, quoteEscaper.Replace(filename)))
+	h.Set("Content-Type", contentType)
+	return h
+}
+
+// GetAttachment is like GetAttachmentContext using the client's default context
+func (c *Client) GetAttachment(cardID string, filename string) (io.ReadCloser, models.Attachment, error) {
+	return c.GetAttachmentContext(c.context(), cardID, filename)
+}
+
+// GetAttachmentContext downloads an attachment of a card. The body is
+// streamed; the caller must close it. The returned Attachment carries the
+// content type and, when the server sends it, the size.
+func (c *Client) GetAttachmentContext(ctx context.Context, cardID string, filename string) (io.ReadCloser, models.Attachment, error) {
+	endpoint := fmt.Sprintf("%s/cards/%s/attachments/%s", c.baseURL, cardID, url.PathEscape(filename))
+
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, models.Attachment{}, err
+	}
+
+	c.setAuth(req)
+	req.Header.Set("Accept", "*/*")
+
+	resp, err := c.doRequest(req)
+	if err != nil {
+		return nil, models.Attachment{}, err
+	}
+
+	if err := handleError(resp); err != nil {
+		resp.Body.Close()
+		return nil, models.Attachment{}, err
+	}
+
+	info := models.Attachment{ContentType: resp.Header.Get("Content-Type")}
+	if resp.ContentLength > 0 {
+		info.Size = resp.ContentLength
+	}
+	return resp.Body, info, nil
+}
+
+// DeleteAttachment is like DeleteAttachmentContext using the client's default context
+func (c *Client) DeleteAttachment(cardID string, filename string) error {
+	return c.DeleteAttachmentContext(c.context(), cardID, filename)
+}
+
+// DeleteAttachmentContext removes an attachment from a card
+func (c *Client) DeleteAttachmentContext(ctx context.Context, cardID string, filename string) error {
+	endpoint := fmt.Sprintf("%s/cards/%s/attachments/%s", c.baseURL, cardID, url.PathEscape(filename))
+
+	req, err := http.NewRequestWithContext(ctx, "DELETE", endpoint, nil)
+	if err != nil {
+		return err
+	}
+
+	c.setAuth(req)
+
+	resp, err := c.doRequest(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return handleError(resp)
+}
+
+// === Deck Operations ===
+
+// ListDecks is like ListDecksContext using the client's default context
+func (c *Client) ListDecks(bookmark string) (*models.DeckPage, error) {
+	return c.ListDecksContext(c.context(), bookmark)
+}
+
+// ListDecksContext lists all decks
+func (c *Client) ListDecksContext(ctx context.Context, bookmark string) (*models.DeckPage, error) {
+	params := url.Values{}
+	if bookmark != "" {
+		params.Set("bookmark", bookmark)
+	}
+
+	url := c.baseURL + "/decks"
+	if len(params) > 0 {
+		url = url + "?" + params.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	c.setAuth(req)
+
+	resp, err := c.doRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := handleError(resp); err != nil {
+		return nil, err
+	}
+
+	var result models.DeckPage
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	c.reportWarnings(result.Warnings)
+
+	return &result, nil
+}
+
+// GetDeck is like GetDeckContext using the client's default context
+func (c *Client) GetDeck(deckID string) (*models.Deck, error) {
+	return c.GetDeckContext(c.context(), deckID)
+}
+
+// GetDeckContext retrieves a specific deck
+func (c *Client) GetDeckContext(ctx context.Context, deckID string) (*models.Deck, error) {
+	url := c.baseURL + "/decks/" + deckID
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	c.setAuth(req)
+
+	resp, err := c.doRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := handleError(resp); err != nil {
+		return nil, err
+	}
+
+	var deck models.Deck
+	if err := json.NewDecoder(resp.Body).Decode(&deck); err != nil {
+		return nil, err
+	}
+
+	return &deck, nil
+}
+
+// CreateDeck is like CreateDeckContext using the client's default context
+func (c *Client) CreateDeck(deck *models.Deck) (*models.Deck, error) {
+	return c.CreateDeckContext(c.context(), deck)
+}
+
+// CreateDeckContext creates a new deck
+func (c *Client) CreateDeckContext(ctx context.Context, deck *models.Deck) (*models.Deck, error) {
+	url := c.baseURL + "/decks"
+
+	payload := map[string]interface{}{
+		"name": deck.Name,
+	}
+	if deck.ParentID != "" {
+		payload["parent-id"] = deck.ParentID
+	}
+	if deck.Sort != 0 {
+		payload["sort"] = deck.Sort
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+
+	c.setAuth(req)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.doRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := handleError(resp); err != nil {
+		return nil, err
+	}
+
+	var created models.Deck
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		return nil, err
+	}
+
+	return &created, nil
+}
+
+// UpdateDeck is like UpdateDeckContext using the client's default context
+func (c *Client) UpdateDeck(deckID string, patch models.DeckPatch) (*models.Deck, error) {
+	return c.UpdateDeckContext(c.context(), deckID, patch)
+}
+
+// UpdateDeckContext applies a partial update to a deck. Fields not set in
+// patch are left unchanged.
+func (c *Client) UpdateDeckContext(ctx context.Context, deckID string, patch models.DeckPatch) (*models.Deck, error) {
+	url := c.baseURL + "/decks/" + deckID
+
+	body, err := json.Marshal(patch.Payload())
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+
+	c.setAuth(req)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.doRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := handleError(resp); err != nil {
+		return nil, err
+	}
+
+	var updated models.Deck
+	if err := json.NewDecoder(resp.Body).Decode(&updated); err != nil {
+		return nil, err
+	}
+
+	return &updated, nil
+}
+
+// DeleteDeck is like DeleteDeckContext using the client's default context
+func (c *Client) DeleteDeck(deckID string) error {
+	return c.DeleteDeckContext(c.context(), deckID)
+}
+
+// DeleteDeckContext permanently deletes a deck
+func (c *Client) DeleteDeckContext(ctx context.Context, deckID string) error {
+	url := c.baseURL + "/decks/" + deckID
+
+	req, err := http.NewRequestWithContext(ctx, "DELETE", url, nil)
+	if err != nil {
+		return err
+	}
+
+	c.setAuth(req)
+
+	resp, err := c.doRequest(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return handleError(resp)
+}
+
+// === Template Operations ===
+
+// ListTemplates is like ListTemplatesContext using the client's default context
+func (c *Client) ListTemplates(bookmark string) (*models.TemplatePage, error) {
+	return c.ListTemplatesContext(c.context(), bookmark)
+}
+
+// ListTemplatesContext lists all templates
+func (c *Client) ListTemplatesContext(ctx context.Context, bookmark string) (*models.TemplatePage, error) {
+	params := url.Values{}
+	if bookmark != "" {
+		params.Set("bookmark", bookmark)
+	}
+
+	url := c.baseURL + "/templates"
+	if len(params) > 0 {
+		url = url + "?" + params.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	c.setAuth(req)
+
+	resp, err := c.doRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := handleError(resp); err != nil {
+		return nil, err
+	}
+
+	var result models.TemplatePage
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	c.reportWarnings(result.Warnings)
+
+	return &result, nil
+}
+
+// GetTemplate is like GetTemplateContext using the client's default context
+func (c *Client) GetTemplate(templateID string) (*models.Template, error) {
+	return c.GetTemplateContext(c.context(), templateID)
+}
+
+// GetTemplateContext retrieves a specific template
+func (c *Client) GetTemplateContext(ctx context.Context, templateID string) (*models.Template, error) {
+	url := c.baseURL + "/templates/" + templateID
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	c.setAuth(req)
+
+	resp, err := c.doRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := handleError(resp); err != nil {
+		return nil, err
+	}
+
+	var template models.Template
+	if err := json.NewDecoder(resp.Body).Decode(&template); err != nil {
+		return nil, err
+	}
+
+	return &template, nil
+}
+
+// CreateTemplate is like CreateTemplateContext using the client's default context
+func (c *Client) CreateTemplate(template *models.Template) (*models.Template, error) {
+	return c.CreateTemplateContext(c.context(), template)
+}
+
+// CreateTemplateContext creates a new template
+func (c *Client) CreateTemplateContext(ctx context.Context, template *models.Template) (*models.Template, error) {
+	url := c.baseURL + "/templates"
+
+	body, err := json.Marshal(template)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+
+	c.setAuth(req)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.doRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := handleError(resp); err != nil {
+		return nil, err
+	}
+
+	var created models.Template
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		return nil, err
+	}
+
+	return &created, nil
+}
+
+// UpdateTemplate is like UpdateTemplateContext using the client's default context
+func (c *Client) UpdateTemplate(templateID string, template *models.Template) (*models.Template, error) {
+	return c.UpdateTemplateContext(c.context(), templateID, template)
+}
+
+// UpdateTemplateContext replaces a template's name, content, fields and
+// settings with those of template
+func (c *Client) UpdateTemplateContext(ctx context.Context, templateID string, template *models.Template) (*models.Template, error) {
+	url := c.baseURL + "/templates/" + templateID
+
+	// The ID goes in the URL; the template's own ID must not be sent as a
+	// change
+	encoded, err := json.Marshal(template)
+	if err != nil {
+		return nil, err
+	}
+	var payload map[string]json.RawMessage
+	if err := json.Unmarshal(encoded, &payload); err != nil {
+		return nil, err
+	}
+	delete(payload, "id")
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+
+	c.setAuth(req)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.doRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := handleError(resp); err != nil {
+		return nil, err
+	}
+
+	var updated models.Template
+	if err := json.NewDecoder(resp.Body).Decode(&updated); err != nil {
+		return nil, err
+	}
+
+	return &updated, nil
+}
+
+// DeleteTemplate is like DeleteTemplateContext using the client's default context
+func (c *Client) DeleteTemplate(templateID string) error {
+	return c.DeleteTemplateContext(c.context(), templateID)
+}
+
+// DeleteTemplateContext permanently deletes a template
+func (c *Client) DeleteTemplateContext(ctx context.Context, templateID string) error {
+	url := c.baseURL + "/templates/" + templateID
+
+	req, err := http.NewRequestWithContext(ctx, "DELETE", url, nil)
+	if err != nil {
+		return err
+	}
+
+	c.setAuth(req)
+
+	resp, err := c.doRequest(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return handleError(resp)
+}
+
+// === Due Cards Operations ===
+
+// GetDueCards is like GetDueCardsContext using the client's default context
+func (c *Client) GetDueCards(date string, deckID string) (*models.DueResponse, error) {
+	return c.GetDueCardsContext(c.context(), date, deckID)
+}
+
+// GetDueCardsContext retrieves cards due on a specific date
+func (c *Client) GetDueCardsContext(ctx context.Context, date string, deckID string) (*models.DueResponse, error) {
+	params := url.Values{}
+	if date != "" {
+		params.Set("date", date)
+	}
+
+	url := c.baseURL + "/due"
+	if deckID != "" {
+		url = url + "/" + deckID
+	}
+	if len(params) > 0 {
+		url = url + "?" + params.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	c.setAuth(req)
+
+	resp, err := c.doRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := handleError(resp); err != nil {
+		return nil, err
+	}
+
+	var result models.DueResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetAllDueCards is like GetAllDueCardsContext using the client's default context
+func (c *Client) GetAllDueCards(date string) (*models.DueResponse, error) {
+	return c.GetAllDueCardsContext(c.context(), date)
+}
+
+// GetAllDueCardsContext retrieves all cards due across all decks
+func (c *Client) GetAllDueCardsContext(ctx context.Context, date string) (*models.DueResponse, error) {
+	return c.GetDueCardsContext(ctx, date, "")
+}
+
+// SearchCards is like SearchCardsContext using the client's default context
+func (c *Client) SearchCards(query string, deckID string) ([]models.Card, error) {
+	return c.SearchCardsContext(c.context(), query, deckID)
+}
+
+// SearchCardsContext searches for cards matching a query (client-side filtering).
+// If listing fails part way, for example because ctx was cancelled, the
+// matches found so far are returned along with the error.
+func (c *Client) SearchCardsContext(ctx context.Context, query string, deckID string) ([]models.Card, error) {
+	query = strings.ToLower(query)
+
+	// Simple case-insensitive search in content and name
+	return c.FilterCardsContext(ctx, deckID, func(card *models.Card) bool {
+		return strings.Contains(strings.ToLower(card.Content), query) ||
+			strings.Contains(strings.ToLower(card.Name), query)
+	})
+}
+
+// FilterCards is like FilterCardsContext using the client's default context
+func (c *Client) FilterCards(deckID string, keep func(card *models.Card) bool) ([]models.Card, error) {
+	return c.FilterCardsContext(c.context(), deckID, keep)
+}
+
+// FilterCardsContext pages through all cards, or those of one deck, and
+// returns the ones keep accepts. If listing fails part way the cards kept so
+// far are returned with the error.
+func (c *Client) FilterCardsContext(ctx context.Context, deckID string, keep func(card *models.Card) bool) ([]models.Card, error) {
+	var cards []models.Card
+
+	it := c.CardsContext(ctx, deckID, IterOptions{PageSize: 100})
+	for it.Next() {
+		card := it.Item()
+		if keep(&card) {
+			cards = append(cards, card)
+		}
+	}
+
+	return cards, it.Err()
+}
@@ -0,0 +1,166 @@
+package mochi_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/nerveband/mochi-cli/internal/fakeserver"
+	"github.com/nerveband/mochi-cli/pkg/mochi"
+	"github.com/nerveband/mochi-cli/pkg/mochi/models"
+)
+
+const testAPIKey = "test-key"
+
+// newTestClient starts a fake Mochi API and returns a client talking to it
+func newTestClient(t *testing.T) (*mochi.Client, *fakeserver.Server) {
+	t.Helper()
+	srv := fakeserver.New()
+	srv.APIKey = testAPIKey
+	ts := httptest.NewServer(http.StripPrefix("/api", srv))
+	t.Cleanup(ts.Close)
+	return mochi.NewClient(testAPIKey, mochi.WithBaseURL(ts.URL+"/api")), srv
+}
+
+func TestCreateAndGetCard(t *testing.T) {
+	client, srv := newTestClient(t)
+	deck := srv.AddDeck(models.Deck{Name: "Spanish"})
+
+	created, err := client.CreateCard(&models.Card{
+		DeckID:     deck.ID,
+		Name:       "hola",
+		Content:    "hola\n---\nhello",
+		ManualTags: []string{"greetings"},
+	})
+	if err != nil {
+		t.Fatalf("CreateCard: %v", err)
+	}
+	if created.ID == "" {
+		t.Fatal("CreateCard returned a card without an ID")
+	}
+
+	got, err := client.GetCard(created.ID)
+	if err != nil {
+		t.Fatalf("GetCard: %v", err)
+	}
+	if got.ID != created.ID || got.DeckID != deck.ID || got.Name != "hola" || got.Content != "hola\n---\nhello" {
+		t.Errorf("GetCard = %+v, want the created card", got)
+	}
+	if !reflect.DeepEqual(got.ManualTags, []string{"greetings"}) {
+		t.Errorf("ManualTags = %v, want [greetings]", got.ManualTags)
+	}
+}
+
+func TestListCardsBookmarks(t *testing.T) {
+	client, srv := newTestClient(t)
+	deck := srv.AddDeck(models.Deck{Name: "Numbers"})
+	other := srv.AddDeck(models.Deck{Name: "Other"})
+	srv.AddCard(models.Card{DeckID: other.ID, Content: "elsewhere"})
+
+	var want []string
+	for i := 0; i < 25; i++ {
+		want = append(want, srv.AddCard(models.Card{DeckID: deck.ID, Content: fmt.Sprintf("card %d", i)}).ID)
+	}
+
+	var got []string
+	bookmark := ""
+	pages := 0
+	for {
+		page, err := client.ListCards(deck.ID, 10, bookmark)
+		if err != nil {
+			t.Fatalf("ListCards page %d: %v", pages+1, err)
+		}
+		pages++
+		if len(page.Docs) > 10 {
+			t.Fatalf("page %d has %d cards, want at most 10", pages, len(page.Docs))
+		}
+		for _, card := range page.Docs {
+			got = append(got, card.ID)
+		}
+		if page.Bookmark == "" {
+			break
+		}
+		bookmark = page.Bookmark
+		if pages > 10 {
+			t.Fatal("bookmarks never ran out")
+		}
+	}
+
+	if pages != 3 {
+		t.Errorf("fetched %d pages, want 3", pages)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("listed cards %v, want %v", got, want)
+	}
+}
+
+func TestCardsIterator(t *testing.T) {
+	client, srv := newTestClient(t)
+	deck := srv.AddDeck(models.Deck{Name: "Numbers"})
+	for i := 0; i < 25; i++ {
+		srv.AddCard(models.Card{DeckID: deck.ID, Content: fmt.Sprintf("card %d", i)})
+	}
+
+	tests := []struct {
+		name string
+		opts mochi.IterOptions
+		want int
+	}{
+		{"all", mochi.IterOptions{PageSize: 10}, 25},
+		{"max items", mochi.IterOptions{PageSize: 10, MaxItems: 12}, 12},
+		{"max pages", mochi.IterOptions{PageSize: 10, MaxPages: 2}, 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			it := client.Cards(deck.ID, tt.opts)
+			n := 0
+			for it.Next() {
+				n++
+			}
+			if err := it.Err(); err != nil {
+				t.Fatalf("iterator: %v", err)
+			}
+			if n != tt.want {
+				t.Errorf("iterated over %d cards, want %d", n, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetCardNotFound(t *testing.T) {
+	client, _ := newTestClient(t)
+
+	_, err := client.GetCard("missing1")
+	var apiErr *mochi.Error
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("GetCard error = %v (%T), want *mochi.Error", err, err)
+	}
+	if apiErr.StatusCode != http.StatusNotFound || apiErr.Category != mochi.CategoryNotFound {
+		t.Errorf("error status %d category %s, want 404 %s", apiErr.StatusCode, apiErr.Category, mochi.CategoryNotFound)
+	}
+	if apiErr.Method != "GET" || apiErr.Path != "/api/cards/missing1" {
+		t.Errorf("error request %s %s, want GET /api/cards/missing1", apiErr.Method, apiErr.Path)
+	}
+	if !mochi.IsNotFound(err) {
+		t.Error("IsNotFound = false, want true")
+	}
+}
+
+func TestWrongAPIKey(t *testing.T) {
+	_, srv := newTestClient(t)
+	ts := httptest.NewServer(http.StripPrefix("/api", srv))
+	defer ts.Close()
+	client := mochi.NewClient("wrong-key", mochi.WithBaseURL(ts.URL+"/api"))
+
+	_, err := client.ListDecks("")
+	var apiErr *mochi.Error
+	if !errors.As(err, &apiErr) || apiErr.Category != mochi.CategoryAuth {
+		t.Fatalf("ListDecks error = %v, want an %s error", err, mochi.CategoryAuth)
+	}
+	if mochi.IsNotFound(err) {
+		t.Error("IsNotFound = true for an auth error")
+	}
+}
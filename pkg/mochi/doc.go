@@ -0,0 +1,27 @@
+// Package mochi is a Go client for the Mochi.cards API, the same one the
+// mochi CLI is built on.
+//
+// Create a client with an API key and optional settings:
+//
+//	client := mochi.NewClient(apiKey,
+//		mochi.WithTimeout(10*time.Second),
+//		mochi.WithRetryPolicy(mochi.RetryPolicy{MaxRetries: 5, BaseDelay: time.Second}),
+//	)
+//
+//	it := client.CardsContext(ctx, deckID, mochi.IterOptions{})
+//	for it.Next() {
+//		fmt.Println(it.Item().Name)
+//	}
+//	if err := it.Err(); err != nil {
+//		var apiErr *mochi.Error
+//		if errors.As(err, &apiErr) { ... }
+//	}
+//
+// Every method has a Context variant taking a context.Context; the plain
+// variant uses the context set with WithContext, or context.Background.
+// Rate-limited and transient failures are retried according to the
+// client's RetryPolicy.
+//
+// Data types live in the models subpackage, and .mochi file import and
+// export in the importexport subpackage.
+package mochi
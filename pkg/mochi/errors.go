@@ -0,0 +1,54 @@
+package mochi
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Error categories reported for failed API requests
+const (
+	CategoryAuth      = "AUTH_ERROR"
+	CategoryNotFound  = "NOT_FOUND"
+	CategoryRateLimit = "RATE_LIMIT"
+	CategoryAPI       = "API_ERROR"
+)
+
+// Error is returned when the Mochi API responds with a non-2xx status
+type Error struct {
+	StatusCode int         `json:"status"`
+	Category   string      `json:"category"`
+	Errors     interface{} `json:"errors,omitempty"` // raw "errors" payload from Mochi
+	Body       string      `json:"body,omitempty"`   // response body when it wasn't a Mochi error payload
+	Method     string      `json:"method"`
+	Path       string      `json:"path"`
+}
+
+// Error implements the error interface
+func (e *Error) Error() string {
+	detail := e.Body
+	if e.Errors != nil {
+		detail = fmt.Sprintf("%v", e.Errors)
+	}
+	return fmt.Sprintf("API error (%d) on %s %s: %s", e.StatusCode, e.Method, e.Path, detail)
+}
+
+// categorize maps an HTTP status code to an error category
+func categorize(status int) string {
+	switch status {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return CategoryAuth
+	case http.StatusNotFound:
+		return CategoryNotFound
+	case http.StatusTooManyRequests:
+		return CategoryRateLimit
+	default:
+		return CategoryAPI
+	}
+}
+
+// IsNotFound reports whether err is an API error for a missing resource
+func IsNotFound(err error) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.Category == CategoryNotFound
+}
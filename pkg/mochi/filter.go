@@ -0,0 +1,132 @@
+package mochi
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nerveband/mochi-cli/pkg/mochi/models"
+)
+
+// CardFilter selects cards by the properties the API returns for them. Zero
+// values don't filter, and all set conditions must hold. Use it with
+// Iterator.Where or FilterCards:
+//
+//	filter := mochi.CardFilter{Tags: []string{"verbs"}, Archived: mochi.Bool(false)}
+//	it := client.Cards(deckID, mochi.IterOptions{PageSize: 100}).Where(filter.Match)
+type CardFilter struct {
+	// Tags keeps cards with every one of these manual tags (case-insensitive)
+	Tags []string
+	// Archived, Trashed and New keep cards whose flag has the given value
+	Archived *bool
+	Trashed  *bool
+	New      *bool
+	// TemplateID keeps cards using this template
+	TemplateID string
+	// CreatedAfter and the other times are exclusive bounds
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	UpdatedAfter  time.Time
+	UpdatedBefore time.Time
+	// HasReviews keeps cards that have (or haven't) been reviewed
+	HasReviews *bool
+	// MinLapses keeps cards forgotten at least this many times
+	MinLapses int
+}
+
+// Bool returns a pointer to b, for the optional CardFilter conditions
+func Bool(b bool) *bool {
+	return &b
+}
+
+// IsZero reports whether the filter keeps every card
+func (f CardFilter) IsZero() bool {
+	return len(f.Tags) == 0 && f.Archived == nil && f.Trashed == nil && f.New == nil &&
+		f.TemplateID == "" && f.CreatedAfter.IsZero() && f.CreatedBefore.IsZero() &&
+		f.UpdatedAfter.IsZero() && f.UpdatedBefore.IsZero() && f.HasReviews == nil &&
+		f.MinLapses == 0
+}
+
+// Match reports whether a card meets every condition of the filter
+func (f CardFilter) Match(card *models.Card) bool {
+	for _, tag := range f.Tags {
+		if !HasTag(card, tag) {
+			return false
+		}
+	}
+	if f.Archived != nil && card.Archived != *f.Archived {
+		return false
+	}
+	if f.Trashed != nil && IsTrashed(card) != *f.Trashed {
+		return false
+	}
+	if f.New != nil && card.New != *f.New {
+		return false
+	}
+	if f.TemplateID != "" && card.TemplateID != f.TemplateID {
+		return false
+	}
+	if !inRange(card.CreatedAt, f.CreatedAfter, f.CreatedBefore) ||
+		!inRange(card.UpdatedAt, f.UpdatedAfter, f.UpdatedBefore) {
+		return false
+	}
+	if f.HasReviews != nil && (len(card.Reviews) > 0) != *f.HasReviews {
+		return false
+	}
+	if f.MinLapses > 0 && Lapses(card) < f.MinLapses {
+		return false
+	}
+	return true
+}
+
+// HasTag reports whether a card has a manual tag, ignoring case and a
+// leading #
+func HasTag(card *models.Card, tag string) bool {
+	tag = strings.TrimPrefix(tag, "#")
+	for _, t := range card.ManualTags {
+		if strings.EqualFold(strings.TrimPrefix(t, "#"), tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsTrashed reports whether a card is in the trash
+func IsTrashed(card *models.Card) bool {
+	return card.Trashed != nil && !card.Trashed.IsZero()
+}
+
+// Lapses counts the reviews in which a card was forgotten
+func Lapses(card *models.Card) int {
+	n := 0
+	for _, r := range card.Reviews {
+		if !r.Remembered {
+			n++
+		}
+	}
+	return n
+}
+
+// inRange reports whether t lies strictly between the bounds that are set.
+// A missing time is out of range of any bound.
+func inRange(t *models.MochiTime, after, before time.Time) bool {
+	if after.IsZero() && before.IsZero() {
+		return true
+	}
+	if t == nil || t.IsZero() {
+		return false
+	}
+	return (after.IsZero() || t.After(after)) && (before.IsZero() || t.Before(before))
+}
+
+// ParseFilterTime parses a filter bound: a date such as 2025-03-01, taken
+// as midnight local time, or an RFC 3339 timestamp
+func ParseFilterTime(s string) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q (expected YYYY-MM-DD or an RFC 3339 timestamp)", s)
+}
@@ -2,11 +2,13 @@ package mochiimport
 
 import (
 	"archive/zip"
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
 
-	"github.com/nerveband/mochi-cli/internal/models"
+	"github.com/nerveband/mochi-cli/pkg/mochi"
+	"github.com/nerveband/mochi-cli/pkg/mochi/models"
 )
 
 // Exporter handles exporting data to .mochi format
@@ -16,9 +18,9 @@ type Exporter struct {
 
 // APIClient interface for fetching data
 type APIClient interface {
-	ListCards(deckID string, limit int, bookmark string) (*models.PaginatedResponse, error)
+	ListCards(deckID string, limit int, bookmark string) (*models.CardPage, error)
 	GetCard(cardID string) (*models.Card, error)
-	ListDecks(bookmark string) (*models.PaginatedResponse, error)
+	ListDecks(bookmark string) (*models.DeckPage, error)
 	GetDeck(deckID string) (*models.Deck, error)
 	CreateDeck(deck *models.Deck) (*models.Deck, error)
 	CreateCard(card *models.Card) (*models.Card, error)
@@ -29,79 +31,76 @@ func NewExporter(client APIClient) *Exporter {
 	return &Exporter{client: client}
 }
 
-// ExportDeck exports a single deck with all its cards
+// ExportDeck exports a single deck with all its cards. If fetching cards
+// fails part way, the cards fetched so far are returned along with the error.
 func (e *Exporter) ExportDeck(deckID string, opts ExportOptions) (*MochiData, error) {
 	deck, err := e.client.GetDeck(deckID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get deck: %w", err)
 	}
 
-	mochiDeck := MochiDeck{
-		ID:   deck.ID,
-		Name: deck.Name,
-	}
-
-	if deck.ParentID != "" {
-		mochiDeck.ParentID = deck.ParentID
-	}
-
-	// Fetch all cards for this deck
-	cards, err := e.fetchAllCards(deckID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch cards: %w", err)
-	}
-
-	mochiDeck.Cards = make([]MochiCard, len(cards))
-	for i, card := range cards {
-		mochiDeck.Cards[i] = convertCardToMochi(card, opts.IncludeReviews)
-	}
+	mochiDeck, err := e.exportDeck(*deck, opts)
 
 	data := &MochiData{
 		Version: 2,
 		Decks:   []MochiDeck{mochiDeck},
 	}
 
+	if err != nil {
+		return data, fmt.Errorf("failed to fetch cards: %w", err)
+	}
+
 	return data, nil
 }
 
-// ExportAllDecks exports all decks
+// ExportAllDecks exports all decks. If an error occurs part way, for example
+// because the request context was cancelled, the decks exported so far are
+// returned along with the error.
 func (e *Exporter) ExportAllDecks(opts ExportOptions) (*MochiData, error) {
 	decks, err := e.fetchAllDecks()
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch decks: %w", err)
 	}
 
-	mochiDecks := make([]MochiDeck, len(decks))
-	for i, deck := range decks {
-		mochiDeck := MochiDeck{
-			ID:   deck.ID,
-			Name: deck.Name,
-		}
+	data := &MochiData{
+		Version: 2,
+		Decks:   make([]MochiDeck, 0, len(decks)),
+	}
 
-		if deck.ParentID != "" {
-			mochiDeck.ParentID = deck.ParentID
+	for _, deck := range decks {
+		mochiDeck, err := e.exportDeck(deck, opts)
+		if len(mochiDeck.Cards) > 0 || err == nil {
+			data.Decks = append(data.Decks, mochiDeck)
 		}
-
-		// Fetch cards for this deck
-		cards, err := e.fetchAllCards(deck.ID)
 		if err != nil {
-			return nil, fmt.Errorf("failed to fetch cards for deck %s: %w", deck.ID, err)
+			return data, fmt.Errorf("failed to fetch cards for deck %s: %w", deck.ID, err)
 		}
+	}
 
-		mochiDeck.Cards = make([]MochiCard, len(cards))
-		for j, card := range cards {
-			mochiDeck.Cards[j] = convertCardToMochi(card, opts.IncludeReviews)
-		}
+	return data, nil
+}
 
-		mochiDecks[i] = mochiDeck
+// exportDeck converts a deck and fetches its cards. On error the deck holds
+// the cards fetched before the failure.
+func (e *Exporter) exportDeck(deck models.Deck, opts ExportOptions) (MochiDeck, error) {
+	mochiDeck := MochiDeck{
+		ID:   deck.ID,
+		Name: deck.Name,
 	}
 
-	data := &MochiData{
-		Version: 2,
-		Decks:   mochiDecks,
+	if deck.ParentID != "" {
+		mochiDeck.ParentID = deck.ParentID
 	}
 
-	return data, nil
+	// Fetch all cards for this deck
+	cards, err := e.fetchAllCards(deck.ID)
+
+	mochiDeck.Cards = make([]MochiCard, len(cards))
+	for i, card := range cards {
+		mochiDeck.Cards[i] = convertCardToMochi(card, opts.IncludeReviews)
+	}
+
+	return mochiDeck, err
 }
 
 // ExportCards exports specific cards
@@ -179,70 +178,21 @@ func (e *Exporter) ExportToFile(data *MochiData, filepath string, opts ExportOpt
 	return nil
 }
 
-// fetchAllCards fetches all cards for a deck with pagination
+// fetchAllCards fetches all cards for a deck with pagination. On error the
+// cards fetched so far are returned with it.
 func (e *Exporter) fetchAllCards(deckID string) ([]models.Card, error) {
-	var allCards []models.Card
-	var bookmark string
-
-	for {
-		resp, err := e.client.ListCards(deckID, 100, bookmark)
-		if err != nil {
-			return nil, err
-		}
-
-		docs, ok := resp.Docs.([]interface{})
-		if !ok {
-			break
-		}
-
-		for _, doc := range docs {
-			data, _ := json.Marshal(doc)
-			var card models.Card
-			if err := json.Unmarshal(data, &card); err == nil {
-				allCards = append(allCards, card)
-			}
-		}
-
-		if resp.Bookmark == "" {
-			break
-		}
-		bookmark = resp.Bookmark
-	}
-
-	return allCards, nil
+	return mochi.Collect(mochi.NewIterator(context.Background(),
+		func(_ context.Context, bookmark string) (*models.Page[models.Card], error) {
+			return e.client.ListCards(deckID, 100, bookmark)
+		}, mochi.IterOptions{}))
 }
 
 // fetchAllDecks fetches all decks with pagination
 func (e *Exporter) fetchAllDecks() ([]models.Deck, error) {
-	var allDecks []models.Deck
-	var bookmark string
-
-	for {
-		resp, err := e.client.ListDecks(bookmark)
-		if err != nil {
-			return nil, err
-		}
-
-		docs, ok := resp.Docs.([]interface{})
-		if !ok {
-			break
-		}
-
-		for _, doc := range docs {
-			data, _ := json.Marshal(doc)
-			var deck models.Deck
-			if err := json.Unmarshal(data, &deck); err == nil {
-				allDecks = append(allDecks, deck)
-			}
-		}
-
-		if resp.Bookmark == "" {
-			break
-		}
-		bookmark = resp.Bookmark
-	}
-
-	return allDecks, nil
+	return mochi.Collect(mochi.NewIterator(context.Background(),
+		func(_ context.Context, bookmark string) (*models.Page[models.Deck], error) {
+			return e.client.ListDecks(bookmark)
+		}, mochi.IterOptions{}))
 }
 
 // convertCardToMochi converts an API card to Mochi format
@@ -2,23 +2,25 @@ package mochiimport
 
 import (
 	"archive/zip"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 
-	"github.com/nerveband/mochi-cli/internal/api"
-	"github.com/nerveband/mochi-cli/internal/models"
+	"github.com/nerveband/mochi-cli/pkg/mochi"
+	"github.com/nerveband/mochi-cli/pkg/mochi/models"
 )
 
 // Importer handles importing data from .mochi format
 type Importer struct {
-	client *api.Client
+	client *mochi.Client
 }
 
 // NewImporter creates a new importer
-func NewImporter(client *api.Client) *Importer {
+func NewImporter(client *mochi.Client) *Importer {
 	return &Importer{client: client}
 }
 
@@ -75,7 +77,9 @@ type ImportResult struct {
 	MediaFiles       []string
 }
 
-// ImportData imports MochiData into the API
+// ImportData imports MochiData into the API. Failures of individual decks and
+// cards are collected in the result; if the client's context is cancelled the
+// import stops and the partial result is returned with the context error.
 func (i *Importer) ImportData(data *MochiData, opts ImportOptions) (*ImportResult, error) {
 	result := &ImportResult{
 		Errors: make([]string, 0),
@@ -91,6 +95,9 @@ func (i *Importer) ImportData(data *MochiData, opts ImportOptions) (*ImportResul
 	// First pass: Create decks
 	for _, mochiDeck := range data.Decks {
 		newDeck, err := i.createDeck(mochiDeck, opts)
+		if isCancellation(err) {
+			return result, err
+		}
 		if err != nil {
 			result.Errors = append(result.Errors,
 				fmt.Sprintf("Failed to create deck '%s': %v", mochiDeck.Name, err))
@@ -116,7 +123,11 @@ func (i *Importer) ImportData(data *MochiData, opts ImportOptions) (*ImportResul
 				mochiCard.DeckID = newDeck.ID
 			}
 
-			if err := i.createCard(mochiCard, opts); err != nil {
+			err := i.createCard(mochiCard, opts)
+			if isCancellation(err) {
+				return result, err
+			}
+			if err != nil {
 				result.Errors = append(result.Errors,
 					fmt.Sprintf("Failed to create card in deck '%s': %v", mochiDeck.Name, err))
 				continue
@@ -139,7 +150,11 @@ func (i *Importer) ImportData(data *MochiData, opts ImportOptions) (*ImportResul
 			mochiCard.DeckID = opts.DeckID
 		}
 
-		if err := i.createCard(mochiCard, opts); err != nil {
+		err := i.createCard(mochiCard, opts)
+		if isCancellation(err) {
+			return result, err
+		}
+		if err != nil {
 			result.Errors = append(result.Errors,
 				fmt.Sprintf("Failed to create card: %v", err))
 			continue
@@ -150,6 +165,11 @@ func (i *Importer) ImportData(data *MochiData, opts ImportOptions) (*ImportResul
 	return result, nil
 }
 
+// isCancellation reports whether err means the import was interrupted
+func isCancellation(err error) bool {
+	return errors.Is(err, context.Canceled)
+}
+
 // createDeck creates a deck from Mochi format
 func (i *Importer) createDeck(mochiDeck MochiDeck, opts ImportOptions) (*models.Deck, error) {
 	deck := &models.Deck{
@@ -0,0 +1,212 @@
+package mochi
+
+import (
+	"context"
+
+	"github.com/nerveband/mochi-cli/pkg/mochi/models"
+)
+
+// PageFunc fetches the page starting at bookmark (empty for the first page).
+// The returned page carries the bookmark of the following page.
+type PageFunc[T any] func(ctx context.Context, bookmark string) (*models.Page[T], error)
+
+// IterOptions controls how an Iterator walks a bookmark-paginated endpoint
+type IterOptions struct {
+	// Bookmark resumes iteration from a bookmark returned by an earlier listing
+	Bookmark string
+	// MaxItems stops iteration after this many items; 0 means no limit
+	MaxItems int
+	// MaxPages stops iteration after this many pages; 0 means no limit
+	MaxPages int
+	// PageSize is the number of items requested per page where the endpoint
+	// supports it; 0 uses the server default
+	PageSize int
+}
+
+// Iterator walks every item of a bookmark-paginated endpoint, fetching
+// pages lazily. Use it like bufio.Scanner:
+//
+//	it := client.Cards(deckID, mochi.IterOptions{})
+//	for it.Next() {
+//		card := it.Item()
+//	}
+//	if err := it.Err(); err != nil { ... }
+//
+// Stopping early is as simple as no longer calling Next.
+type Iterator[T any] struct {
+	ctx   context.Context
+	fetch PageFunc[T]
+	opts  IterOptions
+	keep  func(item *T) bool
+
+	page      []T
+	pos       int
+	pageStart string // bookmark the current page was fetched with
+	next      string // bookmark of the page after the current one
+	pages     int
+	count     int
+	item      T
+	done      bool
+	err       error
+	warnings  []models.DecodeWarning
+}
+
+// NewIterator creates an iterator over the pages returned by fetch
+func NewIterator[T any](ctx context.Context, fetch PageFunc[T], opts IterOptions) *Iterator[T] {
+	return &Iterator[T]{
+		ctx:       ctx,
+		fetch:     fetch,
+		opts:      opts,
+		pageStart: opts.Bookmark,
+		next:      opts.Bookmark,
+	}
+}
+
+// Where makes the iterator skip items keep rejects. MaxItems then counts
+// only the items kept, so filtering reads as many pages as it takes to
+// fill the limit. Call it before the first Next.
+func (it *Iterator[T]) Where(keep func(item *T) bool) *Iterator[T] {
+	it.keep = keep
+	return it
+}
+
+// Next advances to the next item, fetching another page when needed. It
+// returns false when iteration is finished or an error occurred.
+func (it *Iterator[T]) Next() bool {
+	for it.advance() {
+		if it.keep == nil || it.keep(&it.item) {
+			it.count++
+			return true
+		}
+	}
+	return false
+}
+
+// advance moves to the next item, kept or not
+func (it *Iterator[T]) advance() bool {
+	if it.done {
+		return false
+	}
+	if it.opts.MaxItems > 0 && it.count >= it.opts.MaxItems {
+		it.done = true
+		return false
+	}
+
+	for it.pos >= len(it.page) {
+		// The first page is always fetched; later ones only while the
+		// server hands out a new bookmark
+		if it.pages > 0 && (it.next == "" || it.next == it.pageStart) {
+			it.done = true
+			return false
+		}
+		if it.opts.MaxPages > 0 && it.pages >= it.opts.MaxPages {
+			it.done = true
+			return false
+		}
+		if err := it.ctx.Err(); err != nil {
+			it.err = err
+			it.done = true
+			return false
+		}
+
+		page, err := it.fetch(it.ctx, it.next)
+		if err != nil {
+			it.err = err
+			it.done = true
+			return false
+		}
+		items, next := page.Docs, page.Bookmark
+		it.warnings = append(it.warnings, page.Warnings...)
+
+		it.pageStart = it.next
+		it.next = next
+		it.page = items
+		it.pos = 0
+		it.pages++
+
+		// An empty page means the end, whatever bookmark came with it
+		if len(items) == 0 {
+			it.next = ""
+			it.done = true
+			return false
+		}
+	}
+
+	it.item = it.page[it.pos]
+	it.pos++
+	return true
+}
+
+// Item returns the current item
+func (it *Iterator[T]) Item() T {
+	return it.item
+}
+
+// Err returns the error that stopped iteration, if any
+func (it *Iterator[T]) Err() error {
+	return it.err
+}
+
+// Warnings returns the decode warnings of all pages fetched so far
+func (it *Iterator[T]) Warnings() []models.DecodeWarning {
+	return it.warnings
+}
+
+// Bookmark returns a bookmark from which a new iterator can resume without
+// missing items, or "" when there is nothing left. If iteration stopped
+// part way through a page, resuming repeats that page's earlier items,
+// because Mochi bookmarks address whole pages.
+func (it *Iterator[T]) Bookmark() string {
+	if it.pos < len(it.page) {
+		return it.pageStart
+	}
+	return it.next
+}
+
+// Collect drains the iterator into a slice. On error the items gathered so
+// far are returned with it.
+func Collect[T any](it *Iterator[T]) ([]T, error) {
+	var items []T
+	for it.Next() {
+		items = append(items, it.Item())
+	}
+	return items, it.Err()
+}
+
+// === Resource Iterators ===
+
+// Cards is like CardsContext using the client's default context
+func (c *Client) Cards(deckID string, opts IterOptions) *Iterator[models.Card] {
+	return c.CardsContext(c.context(), deckID, opts)
+}
+
+// CardsContext iterates over all cards, optionally limited to a deck
+func (c *Client) CardsContext(ctx context.Context, deckID string, opts IterOptions) *Iterator[models.Card] {
+	return NewIterator(ctx, func(ctx context.Context, bookmark string) (*models.Page[models.Card], error) {
+		return c.ListCardsContext(ctx, deckID, opts.PageSize, bookmark)
+	}, opts)
+}
+
+// Decks is like DecksContext using the client's default context
+func (c *Client) Decks(opts IterOptions) *Iterator[models.Deck] {
+	return c.DecksContext(c.context(), opts)
+}
+
+// DecksContext iterates over all decks
+func (c *Client) DecksContext(ctx context.Context, opts IterOptions) *Iterator[models.Deck] {
+	return NewIterator(ctx, func(ctx context.Context, bookmark string) (*models.Page[models.Deck], error) {
+		return c.ListDecksContext(ctx, bookmark)
+	}, opts)
+}
+
+// Templates is like TemplatesContext using the client's default context
+func (c *Client) Templates(opts IterOptions) *Iterator[models.Template] {
+	return c.TemplatesContext(c.context(), opts)
+}
+
+// TemplatesContext iterates over all templates
+func (c *Client) TemplatesContext(ctx context.Context, opts IterOptions) *Iterator[models.Template] {
+	return NewIterator(ctx, func(ctx context.Context, bookmark string) (*models.Page[models.Template], error) {
+		return c.ListTemplatesContext(ctx, bookmark)
+	}, opts)
+}
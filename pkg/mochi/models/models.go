@@ -0,0 +1,455 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MochiTime handles Mochi API time formats.
+type MochiTime struct {
+	time.Time
+}
+
+// UnmarshalJSON accepts RFC3339 strings or {"date": "..."} objects.
+func (t *MochiTime) UnmarshalJSON(data []byte) error {
+	if t == nil {
+		return nil
+	}
+	trimmed := strings.TrimSpace(string(data))
+	if trimmed == "" || trimmed == "null" {
+		return nil
+	}
+	if strings.HasPrefix(trimmed, "\"") {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		if s == "" {
+			return nil
+		}
+		parsed, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			parsed, err = time.Parse(time.RFC3339, s)
+			if err != nil {
+				return err
+			}
+		}
+		t.Time = parsed
+		return nil
+	}
+	var obj struct {
+		Date string `json:"date"`
+	}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return err
+	}
+	if obj.Date == "" {
+		return nil
+	}
+	parsed, err := time.Parse(time.RFC3339Nano, obj.Date)
+	if err != nil {
+		parsed, err = time.Parse(time.RFC3339, obj.Date)
+		if err != nil {
+			return err
+		}
+	}
+	t.Time = parsed
+	return nil
+}
+
+// Card represents a flashcard in Mochi
+type Card struct {
+	ID            string                `json:"id"`
+	Content       string                `json:"content"`
+	Name          string                `json:"name,omitempty"`
+	DeckID        string                `json:"deck-id"`
+	TemplateID    string                `json:"template-id,omitempty"`
+	Pos           string                `json:"pos"`
+	Fields        map[string]Field      `json:"fields,omitempty"`
+	ManualTags    []string              `json:"manual-tags,omitempty"`
+	Archived      bool                  `json:"archived?"`
+	ReviewReverse bool                  `json:"review-reverse?"`
+	Trashed       *MochiTime            `json:"trashed?"`
+	New           bool                  `json:"new?"`
+	References    []string              `json:"references"`
+	Reviews       []Review              `json:"reviews"`
+	Attachments   map[string]Attachment `json:"attachments,omitempty"`
+	CreatedAt     *MochiTime            `json:"created-at"`
+	UpdatedAt     *MochiTime            `json:"updated-at"`
+
+	// Extra holds fields the API returned that Card doesn't model yet, so
+	// they survive a decode/encode round trip
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// UnmarshalJSON decodes a card, keeping unknown fields in Extra
+func (c *Card) UnmarshalJSON(data []byte) error {
+	type plain Card
+	if err := json.Unmarshal(data, (*plain)(c)); err != nil {
+		return err
+	}
+	extra, err := unknownFields(data, Card{})
+	c.Extra = extra
+	return err
+}
+
+// MarshalJSON encodes a card, including any unknown fields from Extra
+func (c Card) MarshalJSON() ([]byte, error) {
+	type plain Card
+	return marshalWithExtra(plain(c), c.Extra)
+}
+
+// Field represents a template field value
+type Field struct {
+	ID    string `json:"id"`
+	Value string `json:"value"`
+}
+
+// Attachment describes a file attached to a card, keyed by filename in
+// Card.Attachments
+type Attachment struct {
+	ContentType string `json:"content-type,omitempty"`
+	Size        int64  `json:"size,omitempty"`
+}
+
+// Review represents a card review record
+type Review struct {
+	Date       *MochiTime `json:"date"`
+	Due        *MochiTime `json:"due"`
+	Remembered bool       `json:"remembered?"`
+}
+
+// Deck represents a deck (collection) in Mochi
+type Deck struct {
+	ID              string     `json:"id"`
+	Name            string     `json:"name"`
+	ParentID        string     `json:"parent-id,omitempty"`
+	Sort            int        `json:"sort"`
+	Archived        bool       `json:"archived?"`
+	Trashed         *MochiTime `json:"trashed?"`
+	SortBy          string     `json:"sort-by,omitempty"`
+	CardsView       string     `json:"cards-view,omitempty"`
+	ShowSides       bool       `json:"show-sides?"`
+	SortByDirection bool       `json:"sort-by-direction"`
+	ReviewReverse   bool       `json:"review-reverse?"`
+
+	// Extra holds fields the API returned that Deck doesn't model yet
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// UnmarshalJSON decodes a deck, keeping unknown fields in Extra
+func (d *Deck) UnmarshalJSON(data []byte) error {
+	type plain Deck
+	if err := json.Unmarshal(data, (*plain)(d)); err != nil {
+		return err
+	}
+	extra, err := unknownFields(data, Deck{})
+	d.Extra = extra
+	return err
+}
+
+// MarshalJSON encodes a deck, including any unknown fields from Extra
+func (d Deck) MarshalJSON() ([]byte, error) {
+	type plain Deck
+	return marshalWithExtra(plain(d), d.Extra)
+}
+
+// Template represents a card template in Mochi
+type Template struct {
+	ID      string                   `json:"id"`
+	Name    string                   `json:"name"`
+	Content string                   `json:"content"`
+	Pos     string                   `json:"pos"`
+	Fields  map[string]TemplateField `json:"fields"`
+	Style   map[string]interface{}   `json:"style,omitempty"`
+	Options map[string]interface{}   `json:"options,omitempty"`
+
+	// Extra holds fields the API returned that Template doesn't model yet
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// UnmarshalJSON decodes a template, keeping unknown fields in Extra
+func (t *Template) UnmarshalJSON(data []byte) error {
+	type plain Template
+	if err := json.Unmarshal(data, (*plain)(t)); err != nil {
+		return err
+	}
+	extra, err := unknownFields(data, Template{})
+	t.Extra = extra
+	return err
+}
+
+// MarshalJSON encodes a template, including any unknown fields from Extra
+func (t Template) MarshalJSON() ([]byte, error) {
+	type plain Template
+	return marshalWithExtra(plain(t), t.Extra)
+}
+
+// TemplateField represents a field definition in a template
+type TemplateField struct {
+	ID      string                 `json:"id"`
+	Name    string                 `json:"name,omitempty"`
+	Type    string                 `json:"type,omitempty"`
+	Pos     string                 `json:"pos,omitempty"`
+	Content string                 `json:"content,omitempty"`
+	Options map[string]interface{} `json:"options,omitempty"`
+}
+
+// FieldTypes lists the template field types Mochi supports. An empty type
+// means "text".
+var FieldTypes = []string{
+	"text", "boolean", "number", "draw", "ai", "speech", "image",
+	"translate", "transcription", "dictionary", "pinyin", "furigana",
+}
+
+// ValidationError lists the problems found in a template definition
+type ValidationError struct {
+	Problems []string
+}
+
+// Error implements the error interface
+func (e *ValidationError) Error() string {
+	return "invalid template: " + strings.Join(e.Problems, "; ")
+}
+
+// Validate checks a template definition before it is sent: the template
+// needs a name, and every field needs an ID matching its key, a unique name,
+// a supported type and a unique position.
+func (t *Template) Validate() error {
+	var problems []string
+	if strings.TrimSpace(t.Name) == "" {
+		problems = append(problems, "name is required")
+	}
+
+	ids := make([]string, 0, len(t.Fields))
+	for id := range t.Fields {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	names := map[string]string{}
+	positions := map[string]string{}
+	for _, key := range ids {
+		field := t.Fields[key]
+		if key == "" || field.ID == "" {
+			problems = append(problems, fmt.Sprintf("field %q: id is required", key))
+		} else if field.ID != key {
+			problems = append(problems, fmt.Sprintf("field %q: id %q does not match its key", key, field.ID))
+		}
+
+		if strings.TrimSpace(field.Name) == "" {
+			problems = append(problems, fmt.Sprintf("field %q: name is required", key))
+		} else if other, ok := names[strings.ToLower(field.Name)]; ok {
+			problems = append(problems, fmt.Sprintf("field %q: name %q is already used by field %q", key, field.Name, other))
+		} else {
+			names[strings.ToLower(field.Name)] = key
+		}
+
+		if field.Type != "" && !validFieldType(field.Type) {
+			problems = append(problems, fmt.Sprintf("field %q: unknown type %q (expected one of %s)", key, field.Type, strings.Join(FieldTypes, ", ")))
+		}
+
+		if field.Pos != "" {
+			if !validPos(field.Pos) {
+				problems = append(problems, fmt.Sprintf("field %q: position %q must contain only letters and digits", key, field.Pos))
+			} else if other, ok := positions[field.Pos]; ok {
+				problems = append(problems, fmt.Sprintf("field %q: position %q is already used by field %q", key, field.Pos, other))
+			} else {
+				positions[field.Pos] = key
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// validFieldType reports whether typ is one of FieldTypes
+func validFieldType(typ string) bool {
+	for _, t := range FieldTypes {
+		if t == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// validPos reports whether pos is a Mochi sort position: letters and digits
+func validPos(pos string) bool {
+	for _, r := range pos {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// Page is one page of a bookmark-paginated listing. Documents are decoded
+// individually, so one malformed document is reported in Warnings instead of
+// failing or silently shrinking the whole page.
+type Page[T any] struct {
+	Bookmark string          `json:"bookmark,omitempty"`
+	Docs     []T             `json:"docs"`
+	Warnings []DecodeWarning `json:"-"`
+}
+
+// CardPage is a page of cards
+type CardPage = Page[Card]
+
+// DeckPage is a page of decks
+type DeckPage = Page[Deck]
+
+// TemplatePage is a page of templates
+type TemplatePage = Page[Template]
+
+// DecodeWarning describes a listed document that could not be decoded
+type DecodeWarning struct {
+	ID  string
+	Err error
+}
+
+// String formats the warning for display
+func (w DecodeWarning) String() string {
+	id := w.ID
+	if id == "" {
+		id = "(unknown id)"
+	}
+	return fmt.Sprintf("skipped document %s: %v", id, w.Err)
+}
+
+// UnmarshalJSON decodes a page from either a "docs" or a "cards" array
+func (p *Page[T]) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Bookmark string            `json:"bookmark"`
+		Docs     []json.RawMessage `json:"docs"`
+		Cards    []json.RawMessage `json:"cards"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	docs := raw.Docs
+	if docs == nil {
+		docs = raw.Cards
+	}
+
+	p.Bookmark = raw.Bookmark
+	p.Docs = make([]T, 0, len(docs))
+	p.Warnings = nil
+	for _, doc := range docs {
+		var item T
+		if err := json.Unmarshal(doc, &item); err != nil {
+			var ident struct {
+				ID string `json:"id"`
+			}
+			_ = json.Unmarshal(doc, &ident)
+			p.Warnings = append(p.Warnings, DecodeWarning{ID: ident.ID, Err: err})
+			continue
+		}
+		p.Docs = append(p.Docs, item)
+	}
+
+	return nil
+}
+
+// DueResponse represents the response from the due cards endpoint
+type DueResponse struct {
+	Cards []Card `json:"cards"`
+}
+
+// ErrorResponse represents an API error response
+type ErrorResponse struct {
+	Errors interface{} `json:"errors"`
+}
+
+// knownFieldCache maps a struct type to the JSON names of its fields
+var knownFieldCache sync.Map
+
+// knownFields returns the set of JSON field names declared by a struct type
+func knownFields(t reflect.Type) map[string]bool {
+	if cached, ok := knownFieldCache.Load(t); ok {
+		return cached.(map[string]bool)
+	}
+
+	fields := make(map[string]bool)
+	for i := 0; i < t.NumField(); i++ {
+		tag := t.Field(i).Tag.Get("json")
+		name := strings.Split(tag, ",")[0]
+		if name != "" && name != "-" {
+			fields[name] = true
+		}
+	}
+
+	knownFieldCache.Store(t, fields)
+	return fields
+}
+
+// unknownFields returns the members of a JSON object that model doesn't declare
+func unknownFields(data []byte, model interface{}) (map[string]json.RawMessage, error) {
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+
+	known := knownFields(reflect.TypeOf(model))
+	var extra map[string]json.RawMessage
+	for k, v := range all {
+		if known[k] {
+			continue
+		}
+		if extra == nil {
+			extra = make(map[string]json.RawMessage)
+		}
+		extra[k] = v
+	}
+	return extra, nil
+}
+
+// marshalWithExtra encodes v and appends the extra members that v doesn't
+// already contain, keeping v's own field order
+func marshalWithExtra(v interface{}, extra map[string]json.RawMessage) ([]byte, error) {
+	// Leave HTML escaping to the outer encoder, so callers that disable it
+	// get readable "<< Field >>" placeholders
+	var out bytes.Buffer
+	enc := json.NewEncoder(&out)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	data := bytes.TrimRight(out.Bytes(), "\n")
+	if len(extra) == 0 {
+		return data, nil
+	}
+
+	known := knownFields(reflect.TypeOf(v))
+	keys := make([]string, 0, len(extra))
+	for k := range extra {
+		if !known[k] {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	buf := data[:len(data)-1] // drop the closing brace
+	for _, k := range keys {
+		name, err := json.Marshal(k)
+		if err != nil {
+			return nil, err
+		}
+		if len(buf) > 1 {
+			buf = append(buf, ',')
+		}
+		buf = append(buf, name...)
+		buf = append(buf, ':')
+		buf = append(buf, extra[k]...)
+	}
+	return append(buf, '}'), nil
+}
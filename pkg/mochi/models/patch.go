@@ -0,0 +1,114 @@
+package models
+
+// Optional is a patch field that is either left unset, set to a value or
+// set to null. Unset fields are omitted from the update payload, so the
+// server keeps their current value; null clears them.
+type Optional[T any] struct {
+	value T
+	set   bool
+	null  bool
+}
+
+// Set returns an Optional holding v
+func Set[T any](v T) Optional[T] {
+	return Optional[T]{value: v, set: true}
+}
+
+// Null returns an Optional that clears the field
+func Null[T any]() Optional[T] {
+	return Optional[T]{set: true, null: true}
+}
+
+// IsSet reports whether the field is part of the patch, as a value or null
+func (o Optional[T]) IsSet() bool {
+	return o.set
+}
+
+// IsNull reports whether the field is cleared by the patch
+func (o Optional[T]) IsNull() bool {
+	return o.set && o.null
+}
+
+// Get returns the value and whether one was set. A null field returns false.
+func (o Optional[T]) Get() (T, bool) {
+	return o.value, o.set && !o.null
+}
+
+// addTo writes the field to payload under key if it is set
+func (o Optional[T]) addTo(payload map[string]interface{}, key string) {
+	switch {
+	case !o.set:
+	case o.null:
+		payload[key] = nil
+	default:
+		payload[key] = o.value
+	}
+}
+
+// CardPatch describes a partial card update. Only fields that are set are
+// sent, so an empty value (e.g. no tags) is distinct from leaving the field
+// alone.
+type CardPatch struct {
+	Content       Optional[string]
+	Name          Optional[string]
+	DeckID        Optional[string]
+	TemplateID    Optional[string]
+	Pos           Optional[string]
+	Fields        Optional[map[string]Field]
+	ManualTags    Optional[[]string]
+	Archived      Optional[bool]
+	ReviewReverse Optional[bool]
+}
+
+// Payload returns the JSON payload for the update endpoint
+func (p CardPatch) Payload() map[string]interface{} {
+	payload := map[string]interface{}{}
+	p.Content.addTo(payload, "content")
+	p.Name.addTo(payload, "name")
+	p.DeckID.addTo(payload, "deck-id")
+	p.TemplateID.addTo(payload, "template-id")
+	p.Pos.addTo(payload, "pos")
+	p.Fields.addTo(payload, "fields")
+	p.ManualTags.addTo(payload, "manual-tags")
+	p.Archived.addTo(payload, "archived?")
+	p.ReviewReverse.addTo(payload, "review-reverse?")
+	return payload
+}
+
+// IsEmpty reports whether the patch changes nothing
+func (p CardPatch) IsEmpty() bool {
+	return len(p.Payload()) == 0
+}
+
+// DeckPatch describes a partial deck update, like CardPatch
+type DeckPatch struct {
+	Name            Optional[string]
+	ParentID        Optional[string]
+	Sort            Optional[int]
+	Archived        Optional[bool]
+	SortBy          Optional[string]
+	CardsView       Optional[string]
+	ShowSides       Optional[bool]
+	SortByDirection Optional[bool]
+	ReviewReverse   Optional[bool]
+}
+
+// Payload returns the JSON payload for the update endpoint
+func (p DeckPatch) Payload() map[string]interface{} {
+	payload := map[string]interface{}{}
+	p.Name.addTo(payload, "name")
+	p.ParentID.addTo(payload, "parent-id")
+	p.Sort.addTo(payload, "sort")
+	p.Archived.addTo(payload, "archived?")
+	p.SortBy.addTo(payload, "sort-by")
+	p.CardsView.addTo(payload, "cards-view")
+	p.ShowSides.addTo(payload, "show-sides?")
+	p.SortByDirection.addTo(payload, "sort-by-direction")
+	p.ReviewReverse.addTo(payload, "review-reverse?")
+	return payload
+}
+
+// IsEmpty reports whether the patch changes nothing
+func (p DeckPatch) IsEmpty() bool {
+	return len(p.Payload()) == 0
+}
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCardPatchPayload(t *testing.T) {
+	tests := []struct {
+		name  string
+		patch CardPatch
+		want  string
+	}{
+		{"empty", CardPatch{}, `{}`},
+		{"one field", CardPatch{Name: Set("hola")}, `{"name":"hola"}`},
+		{"null", CardPatch{TemplateID: Null[string](), Fields: Null[map[string]Field]()}, `{"fields":null,"template-id":null}`},
+		{"zero values are sent", CardPatch{Content: Set(""), Archived: Set(false), ManualTags: Set([]string{})},
+			`{"archived?":false,"content":"","manual-tags":[]}`},
+		{
+			"all fields",
+			CardPatch{
+				Content:       Set("c"),
+				Name:          Set("n"),
+				DeckID:        Set("d"),
+				TemplateID:    Set("t"),
+				Pos:           Set("p"),
+				Fields:        Set(map[string]Field{"f": {ID: "f", Value: "v"}}),
+				ManualTags:    Set([]string{"a"}),
+				Archived:      Set(true),
+				ReviewReverse: Set(false),
+			},
+			`{"archived?":true,"content":"c","deck-id":"d","fields":{"f":{"id":"f","value":"v"}},` +
+				`"manual-tags":["a"],"name":"n","pos":"p","review-reverse?":false,"template-id":"t"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(tt.patch.Payload())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("payload = %s, want %s", got, tt.want)
+			}
+			if tt.patch.IsEmpty() != (tt.want == `{}`) {
+				t.Errorf("IsEmpty = %v for payload %s", tt.patch.IsEmpty(), got)
+			}
+		})
+	}
+}
+
+func TestDeckPatchPayload(t *testing.T) {
+	tests := []struct {
+		patch DeckPatch
+		want  string
+	}{
+		{DeckPatch{}, `{}`},
+		{DeckPatch{ParentID: Null[string]()}, `{"parent-id":null}`},
+		{DeckPatch{Sort: Set(0), ShowSides: Set(false), SortBy: Set("")}, `{"show-sides?":false,"sort":0,"sort-by":""}`},
+		{DeckPatch{Name: Set("Verbs"), SortByDirection: Set(true)}, `{"name":"Verbs","sort-by-direction":true}`},
+	}
+	for _, tt := range tests {
+		got, err := json.Marshal(tt.patch.Payload())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("payload = %s, want %s", got, tt.want)
+		}
+	}
+}
+
+func TestOptional(t *testing.T) {
+	var unset Optional[string]
+	if unset.IsSet() || unset.IsNull() {
+		t.Error("zero Optional is set")
+	}
+	if _, ok := unset.Get(); ok {
+		t.Error("zero Optional has a value")
+	}
+
+	empty := Set("")
+	if v, ok := empty.Get(); !ok || v != "" || !empty.IsSet() || empty.IsNull() {
+		t.Errorf("Set(\"\") = %q, %v, want an empty value that is set", v, ok)
+	}
+
+	null := Null[bool]()
+	if _, ok := null.Get(); ok || !null.IsSet() || !null.IsNull() {
+		t.Error("Null isn't set to null")
+	}
+}
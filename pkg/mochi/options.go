@@ -0,0 +1,74 @@
+package mochi
+
+import (
+	"net/http"
+	"time"
+)
+
+// Option configures a Client created by NewClient
+type Option func(*Client)
+
+// WithBaseURL points the client at a different API host; see SetBaseURL
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) {
+		c.SetBaseURL(baseURL)
+	}
+}
+
+// WithHTTPClient sends requests through a copy of hc, so its transport,
+// timeout and cookie jar are used. Later options such as WithTimeout modify
+// the copy, never hc itself.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		if hc == nil {
+			return
+		}
+		copied := *hc
+		c.httpClient = &copied
+	}
+}
+
+// WithTransport sends requests through rt; see SetTransport
+func WithTransport(rt http.RoundTripper) Option {
+	return func(c *Client) {
+		c.SetTransport(rt)
+	}
+}
+
+// WithTimeout bounds each HTTP request; 0 disables the timeout
+func WithTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		c.httpClient.Timeout = d
+	}
+}
+
+// WithUserAgent sets the User-Agent header sent with every request
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) {
+		if userAgent != "" {
+			c.userAgent = userAgent
+		}
+	}
+}
+
+// WithRetryPolicy replaces the default retry policy
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *Client) {
+		c.SetRetryPolicy(policy)
+	}
+}
+
+// WithRateLimit limits the client to rate requests per second; see
+// SetRateLimit
+func WithRateLimit(rate float64, burst int) Option {
+	return func(c *Client) {
+		c.SetRateLimit(rate, burst)
+	}
+}
+
+// WithLogger receives diagnostic messages about retries and rate limiting
+func WithLogger(logf func(format string, args ...interface{})) Option {
+	return func(c *Client) {
+		c.SetLogger(logf)
+	}
+}
@@ -0,0 +1,65 @@
+package mochi
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket that spaces out requests on the client side
+// so bulk operations stay under the API's rate limit
+type RateLimiter struct {
+	mu     sync.Mutex
+	rate   float64 // tokens added per second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter creates a limiter allowing rate requests per second with
+// bursts of up to burst requests. A burst below 1 is treated as 1.
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Wait blocks until a request may be sent or ctx is done. It returns how long
+// the caller was held back.
+func (l *RateLimiter) Wait(ctx context.Context) (time.Duration, error) {
+	if l == nil || l.rate <= 0 {
+		return 0, nil
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+
+	// Reserve a token now; a negative balance is paid off by waiting
+	l.tokens--
+	var wait time.Duration
+	if l.tokens < 0 {
+		wait = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	if err := sleep(ctx, wait); err != nil {
+		// Give the reservation back so cancelled callers don't slow others down
+		l.mu.Lock()
+		l.tokens++
+		l.mu.Unlock()
+		return 0, err
+	}
+
+	return wait, nil
+}
@@ -0,0 +1,154 @@
+package mochi
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how the client retries failed requests.
+//
+// Rate-limited responses (429) are retried for every method because the
+// server rejected them without processing. Network errors and transient 5xx
+// responses are only retried for idempotent methods unless
+// RetryNonIdempotent is set, since a POST that reached the server may have
+// taken effect.
+type RetryPolicy struct {
+	// MaxRetries is the number of retries after the first attempt; 0 disables retries
+	MaxRetries int
+	// BaseDelay is the backoff ceiling for the first retry, doubled on each attempt
+	BaseDelay time.Duration
+	// MaxDelay caps a single wait, including waits requested via Retry-After
+	MaxDelay time.Duration
+	// RetryNonIdempotent also retries POST requests on network errors and 5xx
+	RetryNonIdempotent bool
+}
+
+// DefaultRetryPolicy returns the policy used by new clients
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxRetries: 3,
+		BaseDelay:  500 * time.Millisecond,
+		MaxDelay:   30 * time.Second,
+	}
+}
+
+// retryableStatus reports whether a response status is worth retrying
+func retryableStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// idempotent reports whether repeating a request with this method is safe
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// shouldRetry decides whether a request should be retried after the given
+// attempt (0-based) and how long to wait first
+func (p RetryPolicy) shouldRetry(req *http.Request, resp *http.Response, err error, attempt int) (time.Duration, bool) {
+	if attempt >= p.MaxRetries {
+		return 0, false
+	}
+
+	// A consumed body can only be resent if it can be recreated
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return 0, false
+	}
+
+	if err != nil {
+		if errors.Is(err, context.Canceled) || req.Context().Err() != nil {
+			return 0, false
+		}
+		if !idempotent(req.Method) && !p.RetryNonIdempotent {
+			return 0, false
+		}
+		return p.backoff(attempt), true
+	}
+
+	if !retryableStatus(resp.StatusCode) {
+		return 0, false
+	}
+	if resp.StatusCode != http.StatusTooManyRequests && !idempotent(req.Method) && !p.RetryNonIdempotent {
+		return 0, false
+	}
+
+	if wait, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+		if p.MaxDelay > 0 && wait > p.MaxDelay {
+			wait = p.MaxDelay
+		}
+		return wait, true
+	}
+
+	return p.backoff(attempt), true
+}
+
+// backoff returns an exponential delay with full jitter for the given attempt
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	ceiling := p.BaseDelay << attempt
+	if ceiling <= 0 || (p.MaxDelay > 0 && ceiling > p.MaxDelay) {
+		ceiling = p.MaxDelay
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(ceiling) + 1))
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds or as an HTTP date
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(value); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		wait := time.Until(at)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+	return 0, false
+}
+
+// sleep waits for d or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// discard drains and closes a response body so its connection can be reused
+func discard(resp *http.Response) {
+	if resp == nil {
+		return
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+}
@@ -0,0 +1,235 @@
+package mochi
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nerveband/mochi-cli/pkg/mochi/models"
+)
+
+// fastRetries retries quickly so tests don't wait
+var fastRetries = RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}
+
+// scripted is a server answering each request with the next of a list of
+// statuses, repeating the last one, and recording the request bodies
+type scripted struct {
+	mu       sync.Mutex
+	statuses []int
+	header   http.Header
+	bodies   []string
+	onServe  func()
+}
+
+func (s *scripted) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	s.mu.Lock()
+	s.bodies = append(s.bodies, string(body))
+	status := s.statuses[min(len(s.bodies), len(s.statuses))-1]
+	onServe := s.onServe
+	s.mu.Unlock()
+
+	if onServe != nil {
+		onServe()
+	}
+	for name, values := range s.header {
+		w.Header()[name] = values
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if status == http.StatusOK {
+		io.WriteString(w, `{"id":"card0001","content":"hola","deck-id":"deck0001"}`)
+	} else {
+		io.WriteString(w, `{"errors":["try again"]}`)
+	}
+}
+
+// attempts returns the number of requests served
+func (s *scripted) attempts() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.bodies)
+}
+
+// newScriptedClient starts a scripted server and returns a client for it
+func newScriptedClient(t *testing.T, s *scripted, policy RetryPolicy) *Client {
+	t.Helper()
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+	return NewClient("key", WithBaseURL(ts.URL), WithRetryPolicy(policy))
+}
+
+func TestRetryStatuses(t *testing.T) {
+	nonIdempotent := fastRetries
+	nonIdempotent.RetryNonIdempotent = true
+
+	tests := []struct {
+		name     string
+		policy   RetryPolicy
+		post     bool
+		statuses []int
+		attempts int
+		wantErr  bool
+	}{
+		{"429 on POST is retried", fastRetries, true, []int{429, 200}, 2, false},
+		{"500 on POST is not retried", fastRetries, true, []int{500, 200}, 1, true},
+		{"500 on POST with RetryNonIdempotent", nonIdempotent, true, []int{500, 200}, 2, false},
+		{"503 on GET is retried", fastRetries, false, []int{503, 502, 200}, 3, false},
+		{"404 is not retried", fastRetries, false, []int{404, 200}, 1, true},
+		{"retries run out", fastRetries, false, []int{503}, 4, true},
+		{"retries disabled", RetryPolicy{}, false, []int{429, 200}, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &scripted{statuses: tt.statuses}
+			client := newScriptedClient(t, s, tt.policy)
+
+			var err error
+			if tt.post {
+				_, err = client.CreateCard(&models.Card{Content: "hola", DeckID: "deck0001"})
+			} else {
+				_, err = client.GetCard("card0001")
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if got := s.attempts(); got != tt.attempts {
+				t.Errorf("%d attempts, want %d", got, tt.attempts)
+			}
+			if tt.post && s.attempts() > 1 && s.bodies[1] != s.bodies[0] {
+				t.Errorf("retried with body %q, want %q", s.bodies[1], s.bodies[0])
+			}
+		})
+	}
+}
+
+func TestRetryAfterCappedAtMaxDelay(t *testing.T) {
+	policy := RetryPolicy{MaxRetries: 1, MaxDelay: 20 * time.Millisecond}
+	resp := &http.Response{StatusCode: 429, Header: http.Header{"Retry-After": {"120"}}}
+	req, _ := http.NewRequest("GET", "http://example.com", nil)
+	if wait, ok := policy.shouldRetry(req, resp, nil, 0); !ok || wait != policy.MaxDelay {
+		t.Errorf("shouldRetry = %s, %v, want %s, true", wait, ok, policy.MaxDelay)
+	}
+
+	// And end to end, the request doesn't wait two minutes
+	s := &scripted{statuses: []int{429, 200}, header: http.Header{"Retry-After": {"120"}}}
+	client := newScriptedClient(t, s, policy)
+	start := time.Now()
+	if _, err := client.GetCard("card0001"); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("request took %s, want Retry-After capped at %s", elapsed, policy.MaxDelay)
+	}
+}
+
+func TestRetryWithoutGetBody(t *testing.T) {
+	req, _ := http.NewRequest("PUT", "http://example.com", nil)
+	req.Body = io.NopCloser(strings.NewReader("data"))
+	resp := &http.Response{StatusCode: 503, Header: http.Header{}}
+	if _, ok := fastRetries.shouldRetry(req, resp, nil, 0); ok {
+		t.Error("a request whose body can't be recreated would be retried")
+	}
+	if _, ok := fastRetries.shouldRetry(req, nil, errors.New("connection reset"), 0); ok {
+		t.Error("a request whose body can't be recreated would be retried after a network error")
+	}
+
+	// Uploads from a stream are sent once; seekable ones are retried in full
+	for _, seekable := range []bool{false, true} {
+		s := &scripted{statuses: []int{429, 200}}
+		client := newScriptedClient(t, s, fastRetries)
+		var body io.Reader = strings.NewReader("file content")
+		if !seekable {
+			body = struct{ io.Reader }{body}
+		}
+		err := client.UploadAttachment("card0001", AttachmentUpload{Filename: "notes.txt", Body: body})
+
+		want := 1
+		if seekable {
+			want = 2
+		}
+		if s.attempts() != want {
+			t.Errorf("seekable %v: %d attempts, want %d", seekable, s.attempts(), want)
+		}
+		if seekable {
+			if err != nil {
+				t.Errorf("seekable upload: %v", err)
+			} else if !strings.Contains(s.bodies[1], "file content") {
+				t.Errorf("retried upload body %q lacks the file", s.bodies[1])
+			}
+		} else if err == nil {
+			t.Error("stream upload answered 429 succeeded")
+		}
+	}
+}
+
+func TestRetryStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s := &scripted{statuses: []int{503}, onServe: cancel}
+	client := newScriptedClient(t, s, RetryPolicy{MaxRetries: 5, BaseDelay: time.Hour, MaxDelay: time.Hour})
+
+	start := time.Now()
+	_, err := client.GetCardContext(ctx, "card0001")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("error = %v, want context.Canceled", err)
+	}
+	if s.attempts() != 1 {
+		t.Errorf("%d attempts, want 1", s.attempts())
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("cancelled request took %s", elapsed)
+	}
+
+	// Nor is a request retried whose context ended before the response
+	req, _ := http.NewRequestWithContext(ctx, "GET", "http://example.com", nil)
+	if _, ok := fastRetries.shouldRetry(req, nil, context.Canceled, 0); ok {
+		t.Error("a cancelled request would be retried")
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		min   time.Duration
+		max   time.Duration
+		ok    bool
+	}{
+		{"", 0, 0, false},
+		{"0", 0, 0, true},
+		{"7", 7 * time.Second, 7 * time.Second, true},
+		{"-3", 0, 0, false},
+		{"soon", 0, 0, false},
+		{time.Now().Add(time.Minute).UTC().Format(http.TimeFormat), 50 * time.Second, time.Minute, true},
+		{time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0, 0, true},
+	}
+	for _, tt := range tests {
+		wait, ok := parseRetryAfter(tt.value)
+		if ok != tt.ok || wait < tt.min || wait > tt.max {
+			t.Errorf("parseRetryAfter(%q) = %s, %v, want %s-%s, %v", tt.value, wait, ok, tt.min, tt.max, tt.ok)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	p := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	for attempt, ceiling := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second} {
+		for i := 0; i < 50; i++ {
+			if d := p.backoff(attempt); d < 0 || d > ceiling {
+				t.Fatalf("backoff(%d) = %s, want at most %s", attempt, d, ceiling)
+			}
+		}
+	}
+	// Shifting far enough overflows; the cap still applies
+	if d := p.backoff(80); d < 0 || d > time.Second {
+		t.Errorf("backoff(80) = %s, want at most 1s", d)
+	}
+}
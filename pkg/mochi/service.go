@@ -0,0 +1,68 @@
+package mochi
+
+import (
+	"context"
+	"io"
+
+	"github.com/nerveband/mochi-cli/pkg/mochi/models"
+)
+
+// CardService manages cards
+type CardService interface {
+	ListCardsContext(ctx context.Context, deckID string, limit int, bookmark string) (*models.CardPage, error)
+	GetCardContext(ctx context.Context, cardID string) (*models.Card, error)
+	CreateCardContext(ctx context.Context, card *models.Card) (*models.Card, error)
+	UpdateCardContext(ctx context.Context, cardID string, patch models.CardPatch) (*models.Card, error)
+	UpdateCardFieldsContext(ctx context.Context, cardID string, payload map[string]interface{}) (*models.Card, error)
+	DeleteCardContext(ctx context.Context, cardID string) error
+	SearchCardsContext(ctx context.Context, query string, deckID string) ([]models.Card, error)
+	FilterCardsContext(ctx context.Context, deckID string, keep func(card *models.Card) bool) ([]models.Card, error)
+	CardsContext(ctx context.Context, deckID string, opts IterOptions) *Iterator[models.Card]
+}
+
+// DeckService manages decks
+type DeckService interface {
+	ListDecksContext(ctx context.Context, bookmark string) (*models.DeckPage, error)
+	GetDeckContext(ctx context.Context, deckID string) (*models.Deck, error)
+	CreateDeckContext(ctx context.Context, deck *models.Deck) (*models.Deck, error)
+	UpdateDeckContext(ctx context.Context, deckID string, patch models.DeckPatch) (*models.Deck, error)
+	DeleteDeckContext(ctx context.Context, deckID string) error
+	DecksContext(ctx context.Context, opts IterOptions) *Iterator[models.Deck]
+}
+
+// TemplateService manages card templates
+type TemplateService interface {
+	ListTemplatesContext(ctx context.Context, bookmark string) (*models.TemplatePage, error)
+	GetTemplateContext(ctx context.Context, templateID string) (*models.Template, error)
+	CreateTemplateContext(ctx context.Context, template *models.Template) (*models.Template, error)
+	UpdateTemplateContext(ctx context.Context, templateID string, template *models.Template) (*models.Template, error)
+	DeleteTemplateContext(ctx context.Context, templateID string) error
+	TemplatesContext(ctx context.Context, opts IterOptions) *Iterator[models.Template]
+}
+
+// DueService queries cards due for review
+type DueService interface {
+	GetDueCardsContext(ctx context.Context, date string, deckID string) (*models.DueResponse, error)
+	GetAllDueCardsContext(ctx context.Context, date string) (*models.DueResponse, error)
+}
+
+// AttachmentService manages card attachments
+type AttachmentService interface {
+	AddAttachmentContext(ctx context.Context, cardID string, filename string, fileData []byte) error
+	AddAttachmentFromFileContext(ctx context.Context, cardID string, filePath string) error
+	UploadAttachmentContext(ctx context.Context, cardID string, upload AttachmentUpload) error
+	GetAttachmentContext(ctx context.Context, cardID string, filename string) (io.ReadCloser, models.Attachment, error)
+	DeleteAttachmentContext(ctx context.Context, cardID string, filename string) error
+}
+
+// Service is the full Mochi API. Client implements it; depend on Service (or
+// one of its parts) to substitute a fake in tests.
+type Service interface {
+	CardService
+	DeckService
+	TemplateService
+	DueService
+	AttachmentService
+}
+
+var _ Service = (*Client)(nil)